@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
 	goflag "flag"
+	"fmt"
 	"k8s.io/klog/v2"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/openshift/must-gather-clean/pkg/cli"
+	"github.com/openshift/must-gather-clean/pkg/fsutil"
+	"github.com/openshift/must-gather-clean/pkg/layout"
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +24,16 @@ var (
 	OutputFolder       string
 	ReportingFolder    string
 	WorkerCount        int
+	OmissionStubs      bool
+	Deterministic      bool
+	Progress           string
+	Layout             string
+	LayoutDefaults     bool
+	Resume             bool
+	PreviousInput      string
+	PreviousOutput     string
+	PreviousReport     string
+	StateFile          string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -28,13 +44,42 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		defer klog.Flush()
 
+		options := cli.RunOptions{
+			ReportingFolder:    ReportingFolder,
+			DeleteOutputFolder: DeleteOutputFolder,
+			WorkerCount:        WorkerCount,
+			OmissionStubs:      OmissionStubs,
+			Deterministic:      Deterministic,
+			ProgressMode:       progress.Mode(Progress),
+			Layout:             Layout,
+			LayoutDefaults:     LayoutDefaults,
+			Resume:             Resume,
+			Previous: cli.Previous{
+				InputPath:  PreviousInput,
+				OutputPath: PreviousOutput,
+				ReportPath: PreviousReport,
+			},
+		}
 		if PipeModeEnabled {
-			err := cli.RunPipe(ConfigFile, os.Stdin, os.Stdout)
+			stdin := bufio.NewReader(os.Stdin)
+			var err error
+			if fsutil.IsTarStream(stdin) {
+				if StateFile != "" {
+					klog.Exitf("--state-file is not supported for tar streams\n")
+				}
+				// the report is only written to a folder that was given explicitly, stdout is reserved for the tar stream
+				if !cmd.Flags().Changed("report") {
+					options.ReportingFolder = ""
+				}
+				err = cli.RunPipeTar(ConfigFile, stdin, os.Stdout, options)
+			} else {
+				err = cli.RunPipe(ConfigFile, stdin, os.Stdout, StateFile)
+			}
 			if err != nil {
 				klog.Exitf("%v\n", err)
 			}
 		} else {
-			err := cli.Run(ConfigFile, InputFolder, OutputFolder, DeleteOutputFolder, ReportingFolder, WorkerCount)
+			err := cli.Run(ConfigFile, InputFolder, OutputFolder, options)
 			if err != nil {
 				klog.Exitf("%v\n", err)
 			}
@@ -50,6 +95,16 @@ func initFlags() {
 	flags.BoolVarP(&DeleteOutputFolder, "overwrite", "d", false, "If the output directory exists, setting this flag will delete the folder and all its contents before cleaning.")
 	flags.IntVarP(&WorkerCount, "worker-count", "w", runtime.NumCPU(), "The number of workers for processing")
 	flags.StringVarP(&ReportingFolder, "report", "r", ".", "The directory of the reporting output folder, default is the current working directory")
+	flags.BoolVar(&Deterministic, "deterministic", false, "Produce byte-identical output for the same input and config regardless of the number of workers, this takes an additional single-threaded pass over the input")
+	flags.StringVar(&Progress, "progress", string(progress.ModeText), "How to report the progress on stderr: text, json for a JSON object per line, or none")
+	flags.StringVar(&Layout, "layout", layout.Auto, fmt.Sprintf("The layout of the input, one of %s, or %s to detect it", strings.Join(layout.Names(), ", "), layout.Auto))
+	flags.BoolVar(&LayoutDefaults, "layout-defaults", true, "Add the default omissions of the layout to the config, like the private keys of the host in a sosreport")
+	flags.BoolVar(&Resume, "resume", false, "Resume an interrupted run from the checkpoint in the reporting folder into the same output directory, the config must not have changed")
+	flags.StringVar(&PreviousInput, "previous-input", "", "The directory of a must-gather that was cleaned before with the same config, the output of the files that did not change is reused")
+	flags.StringVar(&PreviousOutput, "previous-output", "", "The directory of the obfuscated output of the previous must-gather")
+	flags.StringVar(&PreviousReport, "previous-report", "", "The report of the previous must-gather, its replacements are kept")
+	flags.StringVar(&StateFile, "state-file", "", "In pipe mode, keep the replacements in this file, so that all invocations with the same file obfuscate the same originals the same way")
+	flags.BoolVar(&OmissionStubs, "omission-stubs", false, "Write a placeholder in place of each omitted file, kubernetes resources are replaced by a skeleton with an omitted annotation")
 
 	if !PipeModeEnabled {
 		_ = rootCmd.MarkFlagRequired("config")
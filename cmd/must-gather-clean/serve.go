@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/cli"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var (
+	serveConfigFile       string
+	serveAddress          string
+	serveWorkerCount      int
+	serveMaxRequestSize   string
+	serveMaxExtractedSize string
+	serveRequestTimeout   time.Duration
+	serveLayoutDefaults   bool
+	serveOmissionStubs    bool
+	serveSessionTTL       time.Duration
+	serveMaxSessions      int
+	serveTokenFile        string
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to clean uploads",
+	Long:  "Serves an HTTP API that obfuscates text streams and cleans uploaded must-gather archives, the uploads of a session share their replacements and report",
+	Run: func(_ *cobra.Command, _ []string) {
+		defer klog.Flush()
+
+		maxRequestSize, err := omitter.ParseSize(serveMaxRequestSize)
+		if err != nil {
+			klog.Exitf("invalid --max-request-size: %v\n", err)
+		}
+		maxExtractedSize, err := omitter.ParseSize(serveMaxExtractedSize)
+		if err != nil {
+			klog.Exitf("invalid --max-extracted-size: %v\n", err)
+		}
+
+		var token string
+		if serveTokenFile != "" {
+			contents, err := os.ReadFile(serveTokenFile)
+			if err != nil {
+				klog.Exitf("failed to read --token-file: %v\n", err)
+			}
+			token = strings.TrimSpace(string(contents))
+			if token == "" {
+				klog.Exitf("the --token-file %s is empty\n", serveTokenFile)
+			}
+		}
+
+		err = cli.RunServe(serveConfigFile, serveAddress, cli.ServeOptions{
+			WorkerCount:      serveWorkerCount,
+			MaxRequestSize:   maxRequestSize,
+			MaxExtractedSize: maxExtractedSize,
+			RequestTimeout:   serveRequestTimeout,
+			LayoutDefaults:   serveLayoutDefaults,
+			OmissionStubs:    serveOmissionStubs,
+			SessionTTL:       serveSessionTTL,
+			MaxSessions:      serveMaxSessions,
+			Token:            token,
+		})
+		if err != nil {
+			klog.Exitf("%v\n", err)
+		}
+	},
+}
+
+func init() {
+	flags := serveCmd.Flags()
+	flags.StringVarP(&serveConfigFile, "config", "c", "", "The path to the obfuscation configuration")
+	flags.StringVar(&serveAddress, "address", "127.0.0.1:8080", "The address to listen on, only the local host can connect by default")
+	flags.IntVarP(&serveWorkerCount, "worker-count", "w", runtime.NumCPU(), "The number of workers for processing an upload")
+	flags.StringVar(&serveMaxRequestSize, "max-request-size", "1GiB", "The maximum size of a request body, for a compressed upload this is the compressed size")
+	flags.StringVar(&serveMaxExtractedSize, "max-extracted-size", "4GiB", "The maximum size of an upload after its decompression")
+	flags.DurationVar(&serveRequestTimeout, "request-timeout", 30*time.Minute, "The time to receive an upload, clean it and send it back")
+	flags.BoolVar(&serveLayoutDefaults, "layout-defaults", true, "Add the default omissions of the layout of each upload to the config")
+	flags.BoolVar(&serveOmissionStubs, "omission-stubs", false, "Write a placeholder in place of each omitted file")
+	flags.DurationVar(&serveSessionTTL, "session-ttl", 24*time.Hour, "The time after the last request of a session after which it is forgotten, 0 keeps the sessions until they are deleted")
+	flags.IntVar(&serveMaxSessions, "max-sessions", 100, "The maximum number of sessions, requests that would create another one are answered with 429, 0 for no limit")
+	flags.StringVar(&serveTokenFile, "token-file", "", "A file with the bearer token that every request must send in its Authorization header")
+	_ = serveCmd.MarkFlagRequired("config")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/openshift/must-gather-clean/pkg/cli"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var (
+	suggestInputFolder string
+	suggestOutputFile  string
+)
+
+// suggestConfigCmd represents the suggest-config command
+var suggestConfigCmd = &cobra.Command{
+	Use:   "suggest-config",
+	Short: "Suggest a configuration for a must-gather",
+	Long:  "Discovers the cluster's domains, proxies, registry mirrors, cluster name and infrastructure ID in a must-gather and emits a starter configuration to review",
+	Run: func(_ *cobra.Command, _ []string) {
+		defer klog.Flush()
+
+		var out io.Writer = os.Stdout
+		if suggestOutputFile != "" {
+			f, err := os.Create(suggestOutputFile)
+			if err != nil {
+				klog.Exitf("failed to create %s: %v\n", suggestOutputFile, err)
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			out = f
+		}
+
+		err := cli.RunSuggestConfig(suggestInputFolder, out)
+		if err != nil {
+			klog.Exitf("%v\n", err)
+		}
+	},
+}
+
+func init() {
+	suggestConfigCmd.Flags().StringVarP(&suggestInputFolder, "input", "i", "", "The directory of the must-gather dump")
+	suggestConfigCmd.Flags().StringVarP(&suggestOutputFile, "output", "o", "", "The file to write the suggested configuration to, default is stdout")
+	_ = suggestConfigCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(suggestConfigCmd)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"github.com/openshift/must-gather-clean/pkg/cli"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+var validateConfigPath string
+
+// validateConfigCmd represents the validate-config command
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config",
+	Short: "Validate a configuration file",
+	Long:  "Validates the configuration semantically, reporting errors and warnings with their line and column in the file",
+	Run: func(_ *cobra.Command, _ []string) {
+		defer klog.Flush()
+
+		err := cli.RunValidateConfig(validateConfigPath, os.Stdout)
+		if err != nil {
+			klog.Exitf("%v\n", err)
+		}
+	},
+}
+
+func init() {
+	validateConfigCmd.Flags().StringVarP(&validateConfigPath, "config", "c", "", "The path to the obfuscation configuration")
+	_ = validateConfigCmd.MarkFlagRequired("config")
+	rootCmd.AddCommand(validateConfigCmd)
+}
@@ -0,0 +1,285 @@
+// Package checkpoint persists the progress of a cleaning run, so that an interrupted run can be resumed with the same replacements.
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/cleaner"
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"k8s.io/klog/v2"
+)
+
+// FileName is the name of the checkpoint in the reporting folder. It contains the original values of all replacements, so it must never
+// be written to the output folder.
+const FileName = "checkpoint.json"
+
+// Checkpoint is the progress of a cleaning run.
+type Checkpoint struct {
+	// ConfigDigest is the digest of the configuration the run was started with, a run can only be resumed with the same configuration.
+	ConfigDigest  string `json:"configDigest"`
+	Deterministic bool   `json:"deterministic"`
+	// Layout is the name of the layout of the input, LayoutDefaults is whether its default omissions were added to the configuration.
+	Layout         string `json:"layout"`
+	LayoutDefaults bool   `json:"layoutDefaults"`
+	// Files maps each completed input file to the output files that were written for it, relative to the input and output folder.
+	Files map[string][]string `json:"files"`
+	// Obfuscators are the states of the obfuscators in the order of the configuration.
+	Obfuscators []obfuscator.State `json:"obfuscators"`
+	// Omissions are the omissions of the completed files.
+	Omissions []omitter.Omission `json:"omissions,omitempty"`
+}
+
+// New returns an empty checkpoint for a run with the given configuration.
+func New(configPath string, deterministic bool, layout string, layoutDefaults bool) (*Checkpoint, error) {
+	digest, err := ConfigDigest(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{
+		ConfigDigest:   digest,
+		Deterministic:  deterministic,
+		Layout:         layout,
+		LayoutDefaults: layoutDefaults,
+		Files:          map[string][]string{},
+	}, nil
+}
+
+// ConfigDigest returns the sha256 digest of the configuration file.
+func ConfigDigest(configPath string) (string, error) {
+	contents, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config at %s: %w", configPath, err)
+	}
+	digest := sha256.Sum256(contents)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// Load reads the checkpoint at the given path.
+func Load(path string) (*Checkpoint, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	c := &Checkpoint{}
+	err = json.Unmarshal(contents, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint at %s: %w", path, err)
+	}
+	if c.Files == nil {
+		c.Files = map[string][]string{}
+	}
+	return c, nil
+}
+
+// Save writes the checkpoint to the given path. The previous checkpoint is replaced only once the new one was written completely.
+func (c *Checkpoint) Save(path string) error {
+	contents, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	err = os.WriteFile(tmpPath, contents, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Verify returns an error when a run with the given configuration can't be resumed from the checkpoint.
+func (c *Checkpoint) Verify(configPath string, deterministic bool, layout string, layoutDefaults bool) error {
+	digest, err := ConfigDigest(configPath)
+	if err != nil {
+		return err
+	}
+	if digest != c.ConfigDigest {
+		return fmt.Errorf("the config at %s was changed since the checkpoint was written", configPath)
+	}
+	if deterministic != c.Deterministic {
+		return fmt.Errorf("the checkpoint was written with deterministic=%t, but the run was resumed with deterministic=%t", c.Deterministic, deterministic)
+	}
+	if layout != c.Layout || layoutDefaults != c.LayoutDefaults {
+		return fmt.Errorf("the checkpoint was written for the layout %s with defaults=%t, but the run was resumed for the layout %s with defaults=%t",
+			c.Layout, c.LayoutDefaults, layout, layoutDefaults)
+	}
+	return nil
+}
+
+// RemoveIncompleteOutput removes all files of the output folder that were not written for a completed file, like the partial output of
+// the files that were processed while the run was interrupted.
+func (c *Checkpoint) RemoveIncompleteOutput(outputPath string) error {
+	complete := map[string]struct{}{}
+	for _, outputs := range c.Files {
+		for _, o := range outputs {
+			complete[o] = struct{}{}
+		}
+	}
+	return filepath.WalkDir(outputPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(outputPath, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := complete[relativePath]; ok {
+			return nil
+		}
+		klog.V(2).Infof("removing incomplete output %s", relativePath)
+		return os.Remove(path)
+	})
+}
+
+// Store records the progress of a run and periodically saves it as a checkpoint. It implements cleaner.OutputRecorder.
+type Store struct {
+	path       string
+	obfuscator *obfuscator.MultiObfuscator
+	omitter    omitter.ReportingOmitter
+
+	// processing is held for reading while a file is processed and for writing while Save takes its snapshot, so that the snapshot is
+	// taken between files and its counts only contain the occurrences of the completed files
+	processing sync.RWMutex
+	lock       sync.Mutex
+	checkpoint *Checkpoint
+	// pending are the output files of the files that are not completed yet
+	pending map[string][]string
+}
+
+// NewStore creates a store that continues the given checkpoint and saves it to path.
+func NewStore(path string, checkpoint *Checkpoint, obfuscator *obfuscator.MultiObfuscator, omitter omitter.ReportingOmitter) *Store {
+	return &Store{
+		path:       path,
+		obfuscator: obfuscator,
+		omitter:    omitter,
+		checkpoint: checkpoint,
+		pending:    map[string][]string{},
+	}
+}
+
+func (s *Store) RecordOutput(inputFile string, outputFile string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pending[inputFile] = append(s.pending[inputFile], outputFile)
+}
+
+// Processor returns a processor that skips the completed files and records the files that the given processor completes.
+func (s *Store) Processor(processor cleaner.Processor) cleaner.Processor {
+	return &checkpointProcessor{store: s, processor: processor}
+}
+
+func (s *Store) completed(inputFile string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.checkpoint.Files[inputFile]
+	return ok
+}
+
+func (s *Store) complete(inputFile string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	outputs := s.pending[inputFile]
+	if outputs == nil {
+		// omitted files are completed without any output
+		outputs = []string{}
+	}
+	s.checkpoint.Files[inputFile] = outputs
+	delete(s.pending, inputFile)
+}
+
+// Save writes the current progress. It waits for the files that are currently processed to complete and holds back new ones until the
+// files, the states of the obfuscators and the omissions were taken, the checkpoint is written afterwards.
+func (s *Store) Save() error {
+	s.processing.Lock()
+	s.lock.Lock()
+	checkpoint := Checkpoint{
+		ConfigDigest:   s.checkpoint.ConfigDigest,
+		Deterministic:  s.checkpoint.Deterministic,
+		Layout:         s.checkpoint.Layout,
+		LayoutDefaults: s.checkpoint.LayoutDefaults,
+		Files:          make(map[string][]string, len(s.checkpoint.Files)),
+	}
+	for inputFile, outputs := range s.checkpoint.Files {
+		checkpoint.Files[inputFile] = outputs
+	}
+	s.lock.Unlock()
+
+	checkpoint.Obfuscators = s.obfuscator.State()
+	for _, o := range s.omitter.Report() {
+		if _, ok := checkpoint.Files[o.Path]; ok {
+			checkpoint.Omissions = append(checkpoint.Omissions, o)
+		}
+	}
+	s.processing.Unlock()
+	return checkpoint.Save(s.path)
+}
+
+// SaveEvery saves the progress in the given interval until the returned function is called.
+func (s *Store) SaveEvery(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := s.Save()
+				if err != nil {
+					klog.Errorf("failed to save checkpoint: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// Remove removes the checkpoint, for example after the run completed successfully.
+func (s *Store) Remove() error {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+type checkpointProcessor struct {
+	store     *Store
+	processor cleaner.Processor
+}
+
+func (c *checkpointProcessor) Process(inputFile string) error {
+	c.store.processing.RLock()
+	defer c.store.processing.RUnlock()
+	if c.store.completed(inputFile) {
+		klog.V(3).Infof("skipping %s, it was completed before the run was resumed", inputFile)
+		return nil
+	}
+	err := c.processor.Process(inputFile)
+	if err != nil {
+		return err
+	}
+	c.store.complete(inputFile)
+	return nil
+}
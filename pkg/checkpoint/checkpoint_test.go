@@ -0,0 +1,131 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	store     *Store
+	processed []string
+	outputs   map[string][]string
+	fail      map[string]bool
+}
+
+func (r *recordingProcessor) Process(inputFile string) error {
+	r.processed = append(r.processed, inputFile)
+	for _, o := range r.outputs[inputFile] {
+		r.store.RecordOutput(inputFile, o)
+	}
+	if r.fail[inputFile] {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func newStore(t *testing.T, path string, cp *Checkpoint) (*Store, *obfuscator.MultiObfuscator, *omitter.NoopOmitter) {
+	ip, err := obfuscator.NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, obfuscator.NewSimpleTracker())
+	require.NoError(t, err)
+	mo := obfuscator.NewMultiObfuscator([]obfuscator.ReportingObfuscator{ip})
+	om := &omitter.NoopOmitter{}
+	return NewStore(path, cp, mo, om), mo, om
+}
+
+func TestStoreSaveAndLoad(t *testing.T) {
+	configPath := writeConfig(t, "config: {}\n")
+	path := filepath.Join(t.TempDir(), FileName)
+	cp, err := New(configPath, true, "must-gather", true)
+	require.NoError(t, err)
+
+	store, mo, om := newStore(t, path, cp)
+	processor := &recordingProcessor{
+		store:   store,
+		outputs: map[string][]string{"a.log": {"a.log", "a.log.1"}, "b.log": {"b.log"}},
+		fail:    map[string]bool{"b.log": true},
+	}
+	checkpointProcessor := store.Processor(processor)
+	assert.Equal(t, "x-ipv4-0000000001-x", mo.Contents("10.0.0.1"))
+	assert.NoError(t, checkpointProcessor.Process("a.log"))
+	assert.Error(t, checkpointProcessor.Process("b.log"))
+	assert.NoError(t, checkpointProcessor.Process("omitted.log"))
+	om.Paths = []string{"omitted.log", "b.log"}
+	require.NoError(t, store.Save())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.NoError(t, loaded.Verify(configPath, true, "must-gather", true))
+	assert.Equal(t, cp.ConfigDigest, loaded.ConfigDigest)
+	assert.True(t, loaded.Deterministic)
+	assert.Equal(t, "must-gather", loaded.Layout)
+	assert.True(t, loaded.LayoutDefaults)
+	assert.Equal(t, map[string][]string{"a.log": {"a.log", "a.log.1"}, "omitted.log": {}}, loaded.Files)
+	assert.Equal(t, []omitter.Omission{{Path: "omitted.log"}}, loaded.Omissions)
+	require.Len(t, loaded.Obfuscators, 1)
+	assert.Equal(t, "x-ipv4-0000000001-x", loaded.Obfuscators[0].Replacements[0].ReplacedWith)
+
+	// the completed files are skipped when the run is resumed
+	resumedStore, _, _ := newStore(t, path, loaded)
+	resumedProcessor := &recordingProcessor{store: resumedStore}
+	for _, f := range []string{"a.log", "b.log", "omitted.log", "c.log"} {
+		assert.NoError(t, resumedStore.Processor(resumedProcessor).Process(f))
+	}
+	assert.Equal(t, []string{"b.log", "c.log"}, resumedProcessor.processed)
+
+	require.NoError(t, resumedStore.Remove())
+	assert.NoFileExists(t, path)
+	assert.NoError(t, resumedStore.Remove())
+}
+
+func TestVerify(t *testing.T) {
+	configPath := writeConfig(t, "config: {}\n")
+	cp, err := New(configPath, false, "sosreport", true)
+	require.NoError(t, err)
+
+	assert.NoError(t, cp.Verify(configPath, false, "sosreport", true))
+	assert.EqualError(t, cp.Verify(configPath, true, "sosreport", true), "the checkpoint was written with deterministic=false, but the run was resumed with deterministic=true")
+	assert.EqualError(t, cp.Verify(configPath, false, "must-gather", true),
+		"the checkpoint was written for the layout sosreport with defaults=true, but the run was resumed for the layout must-gather with defaults=true")
+	assert.EqualError(t, cp.Verify(configPath, false, "sosreport", false),
+		"the checkpoint was written for the layout sosreport with defaults=true, but the run was resumed for the layout sosreport with defaults=false")
+	require.NoError(t, os.WriteFile(configPath, []byte("config:\n  obfuscate: []\n"), 0600))
+	assert.EqualError(t, cp.Verify(configPath, false, "sosreport", true), "the config at "+configPath+" was changed since the checkpoint was written")
+}
+
+func TestLoadFailsOnMissingCheckpoint(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), FileName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRemoveIncompleteOutput(t *testing.T) {
+	outputPath := t.TempDir()
+	for _, f := range []string{"a.log", "a.log.1", filepath.Join("dir", "b.log"), filepath.Join("dir", "partial.log"), "partial.log"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(outputPath, f)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(outputPath, f), []byte("content"), 0600))
+	}
+
+	cp := &Checkpoint{Files: map[string][]string{
+		"a.log":                   {"a.log", "a.log.1"},
+		filepath.Join("dir", "b"): {filepath.Join("dir", "b.log")},
+	}}
+	require.NoError(t, cp.RemoveIncompleteOutput(outputPath))
+
+	assert.FileExists(t, filepath.Join(outputPath, "a.log"))
+	assert.FileExists(t, filepath.Join(outputPath, "a.log.1"))
+	assert.FileExists(t, filepath.Join(outputPath, "dir", "b.log"))
+	assert.NoFileExists(t, filepath.Join(outputPath, "dir", "partial.log"))
+	assert.NoFileExists(t, filepath.Join(outputPath, "partial.log"))
+}
@@ -0,0 +1,152 @@
+package cleaner
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+)
+
+// chunkSize is the number of bytes after which the lines that were read are handed to a worker. An input that is smaller than a single
+// chunk is obfuscated without starting any goroutines.
+const chunkSize = 256 * 1024
+
+// lineReader reads the lines of an input in chunks and skips the lines that are omitted by the filter. The filter is always called in the
+// order of the lines, as it may keep state between them.
+type lineReader struct {
+	reader       *bufio.Reader
+	filter       omitter.LineFilter
+	omittedLines int
+}
+
+// readChunk returns the next lines that are not omitted, at least size bytes unless the input ends. The error is io.EOF after the last line.
+func (r *lineReader) readChunk(size int) ([]string, error) {
+	var lines []string
+	n := 0
+	for n < size {
+		line, err := r.reader.ReadString('\n')
+		if line != "" {
+			if r.filter != nil && r.filter.OmitLine(line) {
+				r.omittedLines++
+			} else {
+				lines = append(lines, line)
+				n += len(line)
+			}
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+	return lines, nil
+}
+
+// chunk is a part of the input, its obfuscated lines are sent to the result once a worker is done with it.
+type chunk struct {
+	result chan string
+}
+
+// ChunkPool bounds the number of chunks in flight across all files that are obfuscated at the same time. A single pool is shared by the
+// file workers of a traversal, so that the goroutines and the memory of the chunks grow with the number of workers and not with its square.
+type ChunkPool struct {
+	slots chan struct{}
+}
+
+// NewChunkPool creates a pool for the given number of workers. It returns nil for less than 2 workers, the chunks are then obfuscated
+// sequentially.
+func NewChunkPool(workers int) *ChunkPool {
+	if workers < 2 {
+		return nil
+	}
+	return &ChunkPool{slots: make(chan struct{}, workers)}
+}
+
+// start waits for a free slot and obfuscates the lines in a new goroutine. The slot is held until the result is taken by wait, so that
+// obfuscated chunks that wait for their turn to be written count against the bound as well.
+func (p *ChunkPool) start(lines []string, obfuscate func([]string) string) *chunk {
+	p.slots <- struct{}{}
+	ch := &chunk{result: make(chan string, 1)}
+	go func() {
+		ch.result <- obfuscate(lines)
+	}()
+	return ch
+}
+
+// wait returns the obfuscated lines of the chunk and releases its slot.
+func (p *ChunkPool) wait(ch *chunk) string {
+	result := <-ch.result
+	<-p.slots
+	return result
+}
+
+// obfuscateChunks reads the input in a single goroutine, which also decompresses it if needed, and hands the chunks to the pool.
+// The obfuscated chunks are written in the order of the input.
+func (c *ContentObfuscator) obfuscateChunks(lines *lineReader, writer *bufio.Writer) (int, error) {
+	first, err := lines.readChunk(chunkSize)
+	if errors.Is(err, io.EOF) {
+		if _, err := writer.WriteString(c.obfuscateLines(first)); err != nil {
+			return 0, err
+		}
+		return lines.omittedLines, writer.Flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	ordered := make(chan *chunk, cap(c.Chunks.slots))
+	done := make(chan struct{})
+	defer func() {
+		// the slots of the chunks that are not written anymore are released for the other files
+		close(done)
+		for ch := range ordered {
+			c.Chunks.wait(ch)
+		}
+	}()
+
+	var readErr error
+	go func() {
+		defer close(ordered)
+		next := first
+		var err error
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ch := c.Chunks.start(next, c.obfuscateLines)
+			select {
+			case ordered <- ch:
+			case <-done:
+				c.Chunks.wait(ch)
+				return
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr = err
+				}
+				return
+			}
+			next, err = lines.readChunk(chunkSize)
+		}
+	}()
+
+	for ch := range ordered {
+		if _, err := writer.WriteString(c.Chunks.wait(ch)); err != nil {
+			return 0, err
+		}
+	}
+	if readErr != nil {
+		return 0, readErr
+	}
+	return lines.omittedLines, writer.Flush()
+}
+
+func (c *ContentObfuscator) obfuscateLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(c.obfuscateLine(line))
+	}
+	return b.String()
+}
@@ -3,6 +3,7 @@ package cleaner
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"errors"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/openshift/must-gather-clean/pkg/fsutil"
 	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/layout"
 	"github.com/openshift/must-gather-clean/pkg/obfuscator"
 	"github.com/openshift/must-gather-clean/pkg/omitter"
 )
@@ -41,6 +43,14 @@ type FileObfuscator interface {
 // ContentObfuscator wraps any obfuscator and implements ReadWriteObfuscator
 type ContentObfuscator struct {
 	Obfuscator obfuscator.Obfuscator
+	// Chunks obfuscates the chunks of a large input in parallel, the input is obfuscated sequentially without it.
+	Chunks *ChunkPool
+}
+
+// OutputRecorder is told about every file that is written to the output.
+type OutputRecorder interface {
+	// RecordOutput is called with the paths of an input file and of an output file written for it, relative to the input and output folder.
+	RecordOutput(inputFile string, outputFile string)
 }
 
 // FileContentObfuscator obfuscates a file by implementing FileObfuscator and ReadWriteObfuscator.
@@ -49,6 +59,8 @@ type FileContentObfuscator struct {
 
 	inputFolder  string
 	outputFolder string
+	// recorder is optional, it is told about every output file
+	recorder OutputRecorder
 	// defining a lock to avoid collisions while creating the files in a multi-threaded environment
 	pathCollisionMutex sync.Mutex
 }
@@ -58,6 +70,12 @@ type FileProcessor struct {
 	FileContentObfuscator
 
 	omitter omitter.Omitter
+	// classifier is optional, it decides which of the yaml and json files are read as kubernetes resources
+	classifier layout.Classifier
+	// omissionStubs writes a placeholder in place of each file that is omitted entirely
+	omissionStubs bool
+	// scanners are fed with every kubernetes resource of the input, only used for the prescan
+	scanners []obfuscator.KubernetesResourceScanner
 }
 
 func (c *FileProcessor) Process(path string) error {
@@ -67,35 +85,152 @@ func (c *FileProcessor) Process(path string) error {
 	}
 
 	if omit {
-		return nil
+		return c.writeOmissionStub(path, nil)
+	}
+
+	if c.isResourceFile(path) {
+		return c.processResourceFile(path)
+	}
+
+	// obfuscate the text file with updated path name, which can also contain confidential information
+	omittedLines, err := c.obfuscateFile(path, c.FileContentObfuscator.Obfuscator.Path(path), c.omitter.LineFilter(path))
+	if err != nil {
+		return err
 	}
+	c.omitter.ReportOmittedLines(path, omittedLines)
+	return nil
+}
+
+// isResourceFile returns whether the file is read as kubernetes resources. All yaml and json files are, unless the classifier tells apart
+// the files of other classes, like the configuration files of a host.
+func (c *FileProcessor) isResourceFile(path string) bool {
+	return kube.IsResourceFile(path) && (c.classifier == nil || c.classifier.Classify(path) == layout.ClassResource)
+}
+
+// processResourceFile omits and obfuscates a yaml or json file while reading it only once. The file is split into its objects (the items
+// of a List, the documents of a multi-document yaml or the whole file otherwise), only the objects that are not omitted are obfuscated and
+// written. The whole file is omitted when all of its objects are omitted.
+func (c *FileProcessor) processResourceFile(path string) error {
+	readPath := filepath.Join(c.inputFolder, path)
+	outputFile := c.FileContentObfuscator.Obfuscator.Path(path)
+	writePath := filepath.Join(c.outputFolder, outputFile)
 
-	isKubernetesResource := true
-	kubeResource, err := kube.ReadKubernetesResourceFromPath(filepath.Join(c.inputFolder, path))
+	readPathStat, err := os.Lstat(readPath)
 	if err != nil {
-		if errors.Is(err, kube.NoKubernetesResourceError) {
-			isKubernetesResource = false
+		return fmt.Errorf("failed to lstat input file %s: %w", readPath, err)
+	}
+	// symbolic links are only read to decide on their omission, they are relinked instead of obfuscated
+	isSymbolicLink := fsutil.IsSymbolicLink(readPathStat)
+
+	inputOsFile, err := os.Open(readPath)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", readPath, err)
+	}
+	defer inputOsFile.Close()
+
+	decoder, err := kube.NewResourceDecoder(readPath, inputOsFile)
+	if err != nil {
+		return err
+	}
+
+	writer := &resourceWriter{open: func() (io.WriteCloser, error) {
+		if isSymbolicLink {
+			return nopCloser{io.Discard}, nil
+		}
+		if len(c.outputFolder) != 0 {
+			err := fsutil.MkdirAllWithChown(filepath.Dir(writePath), filepath.Dir(readPath))
+			if err != nil {
+				return nil, err
+			}
+		}
+		outputOsFile, err := c.createOutputFile(readPath, writePath, readPathStat)
+		if err != nil {
+			return nil, err
+		}
+		return &obfuscatingWriter{obfuscator: &c.ContentObfuscator, writer: bufio.NewWriter(outputOsFile), closer: outputOsFile}, nil
+	}}
+
+	var omitted []kube.Resource
+	objects := 0
+	for {
+		part, err := decoder.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to read '%s': %w", readPath, err)
+		}
+
+		if part.Type == kube.TextPart {
+			err = writer.writeText(part.Raw)
 		} else {
-			return err
+			objects++
+			omit, err := c.omitObject(path, part)
+			if err != nil {
+				return err
+			}
+			if omit {
+				resource, _ := part.Resource()
+				omitted = append(omitted, resource)
+				continue
+			}
+			err = writer.writeObject(part)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to obfuscate input file '%s': %w", readPath, err)
 		}
 	}
 
-	if isKubernetesResource {
-		omit, err := c.omitter.OmitKubeResource(kubeResource)
+	c.omitter.ReportOmittedItems(path, omitted, objects)
+	if objects > 0 && len(omitted) == objects {
+		if isSymbolicLink {
+			return nil
+		}
+		return c.writeOmissionStub(path, omitted)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close output file '%s': %w", writePath, err)
+	}
+
+	if isSymbolicLink && len(c.outputFolder) != 0 {
+		err := fsutil.MkdirAllWithChown(filepath.Dir(writePath), filepath.Dir(readPath))
 		if err != nil {
 			return err
 		}
+		return c.relink(readPath, writePath, readPathStat)
+	}
+	return nil
+}
 
-		if omit {
-			return nil
+// omitObject passes the object to the scanners and returns whether it should be omitted. Objects that are not kubernetes resources are never omitted.
+func (c *FileProcessor) omitObject(path string, part *kube.Part) (bool, error) {
+	if len(c.scanners) > 0 {
+		var object kube.Object
+		if err := part.Decode(&object); err == nil && object.Kind() != "" && object.ApiVersion() != "" {
+			for _, o := range kube.ExpandList(object) {
+				for _, s := range c.scanners {
+					s.ScanKubernetesResource(o)
+				}
+			}
 		}
 	}
 
-	// obfuscate the text file with updated path name, which can also contain confidential information
-	return c.ObfuscateFile(path, c.FileContentObfuscator.Obfuscator.Path(path))
+	resource, err := part.Resource()
+	if err != nil {
+		return false, nil
+	}
+	return c.omitter.OmitKubeResourceItem(path, resource)
 }
 
 func (c *FileContentObfuscator) ObfuscateFile(inputFile string, outputFile string) error {
+	_, err := c.obfuscateFile(inputFile, outputFile, nil)
+	return err
+}
+
+// obfuscateFile obfuscates all lines of the inputFile that are not omitted by the filter and returns the number of omitted lines.
+func (c *FileContentObfuscator) obfuscateFile(inputFile string, outputFile string, filter omitter.LineFilter) (int, error) {
 	reportOnly := len(c.outputFolder) == 0
 
 	readPath := filepath.Join(c.inputFolder, inputFile)
@@ -106,21 +241,21 @@ func (c *FileContentObfuscator) ObfuscateFile(inputFile string, outputFile strin
 	if !reportOnly {
 		err := fsutil.MkdirAllWithChown(writePathParentDir, readPathParentDir)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
 	readPathStat, err := os.Lstat(readPath)
 	if err != nil {
-		return fmt.Errorf("failed to lstat input file %s: %w", readPath, err)
+		return 0, fmt.Errorf("failed to lstat input file %s: %w", readPath, err)
 	}
 
 	// symbolic links need some special handling to relink instead of obfuscation
 	if fsutil.IsSymbolicLink(readPathStat) {
 		if reportOnly {
-			return nil
+			return 0, nil
 		}
-		return fsutil.Relink(readPath, writePath, readPathStat)
+		return 0, c.relink(readPath, writePath, readPathStat)
 	}
 
 	var inputOsFile io.ReadCloser
@@ -128,23 +263,19 @@ func (c *FileContentObfuscator) ObfuscateFile(inputFile string, outputFile strin
 
 	inputOsFile, err = os.Open(readPath)
 	if err != nil {
-		return fmt.Errorf("failed to open '%s': %w", readPath, err)
+		return 0, fmt.Errorf("failed to open '%s': %w", readPath, err)
 	}
 
-	if reportOnly {
-		outputOsFile = nopCloser{io.Discard}
-	} else {
-		outputOsFile, err = c.createNonConflictingFileUnderLock(writePath, readPathStat)
-		if err != nil {
-			return fmt.Errorf("failed to create and open '%s': %w", writePath, err)
-		}
+	outputOsFile, err = c.createOutputFile(readPath, writePath, readPathStat)
+	if err != nil {
+		return 0, err
 	}
 
 	// must-gathers can include gunzipped log files nowadays, handling this special case here once
 	if strings.HasSuffix(readPath, ".gz") || strings.HasSuffix(readPath, ".tgz") {
 		inputOsFile, err = gzip.NewReader(inputOsFile)
 		if err != nil {
-			return fmt.Errorf("failed to create a gzip reader when opening '%s': %w", readPath, err)
+			return 0, fmt.Errorf("failed to create a gzip reader when opening '%s': %w", readPath, err)
 		}
 
 		if !reportOnly {
@@ -152,24 +283,62 @@ func (c *FileContentObfuscator) ObfuscateFile(inputFile string, outputFile strin
 		}
 	}
 
-	err = c.ObfuscateReader(inputOsFile, outputOsFile)
+	omittedLines, err := c.obfuscateReader(inputOsFile, outputOsFile, filter)
 	if err != nil {
-		return fmt.Errorf("failed to obfuscate input file '%s': %w", readPath, err)
+		return 0, fmt.Errorf("failed to obfuscate input file '%s': %w", readPath, err)
 	}
 
 	err = inputOsFile.Close()
 	if err != nil {
-		return fmt.Errorf("failed to close input file '%s': %w", readPath, err)
+		return 0, fmt.Errorf("failed to close input file '%s': %w", readPath, err)
 	}
 
 	err = outputOsFile.Close()
 	if err != nil {
-		return fmt.Errorf("failed to close output file '%s': %w", writePath, err)
+		return 0, fmt.Errorf("failed to close output file '%s': %w", writePath, err)
+	}
+
+	return omittedLines, nil
+}
+
+// createOutputFile creates the output file with the permissions of the input file, or discards all output for a dry-run.
+func (c *FileContentObfuscator) createOutputFile(readPath string, writePath string, readPathStat os.FileInfo) (io.WriteCloser, error) {
+	if len(c.outputFolder) == 0 {
+		return nopCloser{io.Discard}, nil
 	}
 
+	outputOsFile, err := c.createNonConflictingFileUnderLock(writePath, readPathStat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create and open '%s': %w", writePath, err)
+	}
+	c.recordOutput(readPath, outputOsFile.Name())
+	return outputOsFile, nil
+}
+
+func (c *FileContentObfuscator) relink(readPath string, writePath string, readPathStat os.FileInfo) error {
+	err := fsutil.Relink(readPath, writePath, readPathStat)
+	if err != nil {
+		return err
+	}
+	c.recordOutput(readPath, writePath)
 	return nil
 }
 
+func (c *FileContentObfuscator) recordOutput(readPath string, writePath string) {
+	if c.recorder == nil {
+		return
+	}
+	inputFile, err := filepath.Rel(c.inputFolder, readPath)
+	if err != nil {
+		return
+	}
+	outputFile, err := filepath.Rel(c.outputFolder, writePath)
+	if err != nil {
+		return
+	}
+	c.recorder.RecordOutput(inputFile, outputFile)
+}
+
 type nopCloser struct {
 	io.Writer
 }
@@ -190,10 +359,21 @@ func (c *FileContentObfuscator) createNonConflictingFileUnderLock(outputFilePath
 }
 
 func (c *ContentObfuscator) ObfuscateReader(inputReader io.Reader, outputWriter io.Writer) error {
+	_, err := c.obfuscateReader(inputReader, outputWriter, nil)
+	return err
+}
+
+// obfuscateReader skips all lines omitted by the filter, if any, and returns their number. Omitted lines are not obfuscated at all,
+// so they don't show up in the obfuscation report.
+func (c *ContentObfuscator) obfuscateReader(inputReader io.Reader, outputWriter io.Writer, filter omitter.LineFilter) (int, error) {
 	// we don't use bufio.Scanner anymore, since that can not read larger than 4096 byte lines (found in prometheus rules.json)
 	reader := bufio.NewReader(inputReader)
 	writer := bufio.NewWriter(outputWriter)
+	if c.Chunks != nil {
+		return c.obfuscateChunks(&lineReader{reader: reader, filter: filter}, writer)
+	}
 
+	omittedLines := 0
 	for {
 		isEOF := false
 		line, err := reader.ReadString('\n')
@@ -205,38 +385,167 @@ func (c *ContentObfuscator) ObfuscateReader(inputReader io.Reader, outputWriter
 					isEOF = true
 				}
 			} else {
-				return err
+				return 0, err
 			}
 		}
 
-		// Replace invalid UTF-8 sequences with the RuneError replacement character (U+FFFD)
-		// This allows processing files with non-UTF-8 content as seen in kube-controller-manager logs
-		if !utf8.ValidString(line) {
-			fmt.Println("Invalid UTF-8 sequence found in line: " + line + " and replacing with " + string(utf8.RuneError))
-			line = strings.ToValidUTF8(line, string(utf8.RuneError))
+		if filter != nil && filter.OmitLine(line) {
+			omittedLines++
+		} else {
+			_, err = fmt.Fprint(writer, c.obfuscateLine(line))
+			if err != nil {
+				return 0, err
+			}
 		}
 
-		contents := c.Obfuscator.Contents(line)
-		_, err = fmt.Fprint(writer, contents)
-		if err != nil {
-			return err
+		if isEOF {
+			break
 		}
+	}
 
-		if isEOF {
+	return omittedLines, writer.Flush()
+}
+
+func (c *ContentObfuscator) obfuscateLine(line string) string {
+	// Replace invalid UTF-8 sequences with the RuneError replacement character (U+FFFD)
+	// This allows processing files with non-UTF-8 content as seen in kube-controller-manager logs
+	if !utf8.ValidString(line) {
+		fmt.Println("Invalid UTF-8 sequence found in line: " + line + " and replacing with " + string(utf8.RuneError))
+		line = strings.ToValidUTF8(line, string(utf8.RuneError))
+	}
+
+	return c.Obfuscator.Contents(line)
+}
+
+// obfuscatingWriter obfuscates everything written to it line by line, the last line without a line break is obfuscated on Close.
+type obfuscatingWriter struct {
+	obfuscator *ContentObfuscator
+	writer     *bufio.Writer
+	closer     io.Closer
+	line       []byte
+}
+
+func (w *obfuscatingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
 			break
 		}
+		w.line = append(w.line, p[:i+1]...)
+		p = p[i+1:]
+		if err := w.writeLine(); err != nil {
+			return 0, err
+		}
 	}
+	return n, nil
+}
 
-	return writer.Flush()
+func (w *obfuscatingWriter) writeLine() error {
+	_, err := w.writer.WriteString(w.obfuscator.obfuscateLine(string(w.line)))
+	w.line = w.line[:0]
+	return err
 }
 
-func NewFileCleaner(inputPath string, outputPath string, obfuscator obfuscator.Obfuscator, omitter omitter.Omitter) Processor {
+func (w *obfuscatingWriter) Close() error {
+	if len(w.line) > 0 {
+		if err := w.writeLine(); err != nil {
+			return err
+		}
+	}
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	return w.closer.Close()
+}
+
+// resourceWriter writes the parts of a resource file that were not omitted. The output is only opened once the first object is written,
+// the text before is held back, so that nothing is written when all objects of the file are omitted.
+type resourceWriter struct {
+	open    func() (io.WriteCloser, error)
+	output  io.WriteCloser
+	pending [][]byte
+	// afterObject is true when the last written part was an object, the separator of the next object of the same list is required then
+	afterObject bool
+}
+
+func (w *resourceWriter) writeText(raw []byte) error {
+	w.afterObject = false
+	if w.output == nil {
+		w.pending = append(w.pending, raw)
+		return nil
+	}
+	_, err := w.output.Write(raw)
+	return err
+}
+
+func (w *resourceWriter) writeObject(part *kube.Part) error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.afterObject && len(part.Separator) > 0 {
+		if _, err := w.output.Write(part.Separator); err != nil {
+			return err
+		}
+	}
+	w.afterObject = true
+	_, err := w.output.Write(part.Raw)
+	return err
+}
+
+func (w *resourceWriter) flush() error {
+	if w.output == nil {
+		output, err := w.open()
+		if err != nil {
+			return err
+		}
+		w.output = output
+	}
+	for _, raw := range w.pending {
+		if _, err := w.output.Write(raw); err != nil {
+			return err
+		}
+	}
+	w.pending = nil
+	return nil
+}
+
+// Close writes the remaining text, which creates the output for files without objects, and closes the output.
+func (w *resourceWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.output.Close()
+}
+
+// NewFileCleaner creates a cleaner that writes the result to the outputPath. The classifier of the layout of the input is optional, without
+// it all yaml and json files are read as kubernetes resources. With omissionStubs, a placeholder is written in place of each
+// file that is omitted entirely. Large text files are split into chunks that are obfuscated by the optional pool, which is shared by
+// all workers of the traversal. The recorder is optional, it is told about every output file.
+func NewFileCleaner(inputPath string, outputPath string, obfuscator obfuscator.Obfuscator, omitter omitter.Omitter, classifier layout.Classifier, omissionStubs bool, chunks *ChunkPool, recorder OutputRecorder) Processor {
 	return &FileProcessor{
 		FileContentObfuscator: FileContentObfuscator{
-			ContentObfuscator: ContentObfuscator{Obfuscator: obfuscator},
+			ContentObfuscator: ContentObfuscator{Obfuscator: obfuscator, Chunks: chunks},
 			inputFolder:       inputPath,
 			outputFolder:      outputPath,
+			recorder:          recorder,
+		},
+		omitter:       omitter,
+		classifier:    classifier,
+		omissionStubs: omissionStubs,
+	}
+}
+
+// NewPrescanFileCleaner creates a dry-run cleaner that additionally passes all kubernetes resources of the input to the given scanners.
+func NewPrescanFileCleaner(inputPath string, obfuscator obfuscator.Obfuscator, classifier layout.Classifier, scanners []obfuscator.KubernetesResourceScanner, chunks *ChunkPool) Processor {
+	return &FileProcessor{
+		FileContentObfuscator: FileContentObfuscator{
+			ContentObfuscator: ContentObfuscator{Obfuscator: obfuscator, Chunks: chunks},
+			inputFolder:       inputPath,
 		},
-		omitter: omitter,
+		omitter:    &omitter.NoopOmitter{},
+		classifier: classifier,
+		scanners:   scanners,
 	}
 }
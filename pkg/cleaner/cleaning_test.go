@@ -1,14 +1,22 @@
 package cleaner
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/layout"
 	"github.com/openshift/must-gather-clean/pkg/obfuscator"
 	"github.com/openshift/must-gather-clean/pkg/omitter"
 	"github.com/openshift/must-gather-clean/pkg/schema"
@@ -51,19 +59,25 @@ func noErrorIpObfuscator(t *testing.T) obfuscator.ReportingObfuscator {
 }
 
 func noErrorK8sSecretOmitter(t *testing.T) omitter.KubernetesResourceOmitter {
-	resourceOmitter, err := omitter.NewKubernetesResourceOmitter(pString("v1"), pString("Secret"), nil)
+	resourceOmitter, err := omitter.NewKubernetesResourceOmitter(pString("v1"), pString("Secret"), nil, nil, nil, nil)
 	require.NoError(t, err)
 	return resourceOmitter
 }
 
+func noErrorTimeRangeOmitter(t *testing.T) omitter.KubernetesResourceOmitter {
+	o, err := omitter.NewTimeRangeOmitter(pString("2023-04-12T10:00:00Z"), pString("2023-04-12T12:00:00Z"), nil)
+	require.NoError(t, err)
+	return o
+}
+
 func TestProcessNotExistingFile(t *testing.T) {
-	fileCleaner := NewFileCleaner("tmpInputDir", "tmpOutputDir", obfuscator.NoopObfuscator{}, &omitter.NoopOmitter{})
+	fileCleaner := NewFileCleaner("tmpInputDir", "tmpOutputDir", obfuscator.NoopObfuscator{}, &omitter.NoopOmitter{}, nil, false, nil, nil)
 	err := fileCleaner.Process("not-existing.yaml")
 	assert.ErrorIs(t, err, os.ErrNotExist)
 }
 
 func TestProcessNoK8sResource(t *testing.T) {
-	fileCleaner := NewFileCleaner("tmpInputDir", "tmpOutputDir", obfuscator.NoopObfuscator{}, &omitter.NoopOmitter{})
+	fileCleaner := NewFileCleaner("tmpInputDir", "tmpOutputDir", obfuscator.NoopObfuscator{}, &omitter.NoopOmitter{}, nil, false, nil, nil)
 	err := fileCleaner.Process("not-existing.zzzz")
 	assert.ErrorIs(t, err, os.ErrNotExist)
 }
@@ -120,6 +134,155 @@ func TestObfuscateReaderNonUTF8Content(t *testing.T) {
 	assert.Contains(t, output.String(), "xxx.xxx.xxx.xxx")
 }
 
+type prefixLineFilter string
+
+func (p prefixLineFilter) OmitLine(line string) bool {
+	return strings.HasPrefix(line, string(p))
+}
+
+func TestObfuscateReaderChunks(t *testing.T) {
+	var input, obfuscated, withoutOmitted strings.Builder
+	for i := 0; input.Len() < 5*chunkSize; i++ {
+		prefix := "line"
+		if i%10 == 0 {
+			prefix = "omitted line"
+		}
+		input.WriteString(fmt.Sprintf("%s %d with ip 10.0.%d.%d\n", prefix, i, i/256%256, i%256))
+		line := fmt.Sprintf("%s %d with ip xxx.xxx.xxx.xxx\n", prefix, i)
+		obfuscated.WriteString(line)
+		if i%10 != 0 {
+			withoutOmitted.WriteString(line)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		input    string
+		filter   omitter.LineFilter
+		expected string
+		omitted  int
+	}{
+		{
+			name:     "single chunk",
+			input:    "10.0.0.1\nno ip\n10.0.0.2",
+			expected: "xxx.xxx.xxx.xxx\nno ip\nxxx.xxx.xxx.xxx",
+		},
+		{
+			name:     "multiple chunks",
+			input:    input.String(),
+			expected: obfuscated.String(),
+		},
+		{
+			name:     "multiple chunks without trailing line break",
+			input:    strings.TrimSuffix(input.String(), "\n"),
+			expected: strings.TrimSuffix(obfuscated.String(), "\n"),
+		},
+		{
+			name:     "multiple chunks with omitted lines",
+			input:    input.String(),
+			filter:   prefixLineFilter("omitted"),
+			expected: withoutOmitted.String(),
+			omitted:  strings.Count(input.String(), "omitted"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, workers := range []int{1, 4} {
+				cf := ContentObfuscator{Obfuscator: noErrorIpObfuscator(t), Chunks: NewChunkPool(workers)}
+				output := &strings.Builder{}
+				omitted, err := cf.obfuscateReader(strings.NewReader(tc.input), output, tc.filter)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, output.String(), "workers: %d", workers)
+				assert.Equal(t, tc.omitted, omitted, "workers: %d", workers)
+			}
+		})
+	}
+}
+
+func TestObfuscateReaderChunksIOErrorPropagates(t *testing.T) {
+	pool := NewChunkPool(4)
+	cf := ContentObfuscator{Obfuscator: noErrorIpObfuscator(t), Chunks: pool}
+	input := strings.Repeat("a line with 10.0.0.1\n", 5*chunkSize/21)
+	_, err := cf.obfuscateReader(strings.NewReader(input), &errWriter{}, nil)
+	require.ErrorIs(t, err, UnwritableErr)
+	// the chunks that were not written anymore must not keep their slots
+	assert.Empty(t, pool.slots)
+}
+
+// concurrencyObfuscator records the maximum number of lines that are obfuscated at the same time.
+type concurrencyObfuscator struct {
+	obfuscator.NoopObfuscator
+	active  atomic.Int32
+	maximum atomic.Int32
+}
+
+func (o *concurrencyObfuscator) Contents(s string) string {
+	active := o.active.Add(1)
+	defer o.active.Add(-1)
+	for {
+		maximum := o.maximum.Load()
+		if active <= maximum || o.maximum.CompareAndSwap(maximum, active) {
+			break
+		}
+	}
+	runtime.Gosched()
+	return s
+}
+
+func TestChunkPoolIsSharedBetweenFiles(t *testing.T) {
+	pool := NewChunkPool(2)
+	o := &concurrencyObfuscator{}
+	input := strings.Repeat("a line with 10.0.0.1\n", 4*chunkSize/21)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cf := ContentObfuscator{Obfuscator: o, Chunks: pool}
+			output := &strings.Builder{}
+			assert.NoError(t, cf.ObfuscateReader(strings.NewReader(input), output))
+			assert.Equal(t, input, output.String())
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, o.maximum.Load(), int32(2))
+	assert.Empty(t, pool.slots)
+	assert.Nil(t, NewChunkPool(1))
+}
+
+func TestObfuscateFileChunksGzip(t *testing.T) {
+	tmpInputDir := t.TempDir()
+	tmpOutputDir := t.TempDir()
+
+	var input, expected strings.Builder
+	for i := 0; input.Len() < 3*chunkSize; i++ {
+		input.WriteString(fmt.Sprintf("line %d with ip 192.168.%d.%d\n", i, i/256%256, i%256))
+		expected.WriteString(fmt.Sprintf("line %d with ip xxx.xxx.xxx.xxx\n", i))
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(input.String()))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, "audit.log.gz"), compressed.Bytes(), 0600))
+
+	fco := &FileContentObfuscator{
+		ContentObfuscator: ContentObfuscator{Obfuscator: noErrorIpObfuscator(t), Chunks: NewChunkPool(4)},
+		inputFolder:       tmpInputDir,
+		outputFolder:      tmpOutputDir,
+	}
+	require.NoError(t, fco.ObfuscateFile("audit.log.gz", "audit.log.gz"))
+
+	f, err := os.Open(filepath.Join(tmpOutputDir, "audit.log.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	output, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), string(output))
+}
+
 func TestObfuscateFileOutputExists(t *testing.T) {
 	tmpInputDir, err := os.MkdirTemp("", "Worker-test-*")
 	require.NoError(t, err)
@@ -153,14 +316,20 @@ func TestObfuscateFileOutputExists(t *testing.T) {
 
 func TestCleanerProcessor(t *testing.T) {
 	for _, tc := range []struct {
-		name             string
-		output           string
-		input            string
+		name   string
+		output string
+		input  string
+		// fileName of the input, defaults to test.yaml
+		fileName         string
 		obfuscators      []obfuscator.ReportingObfuscator
 		fileOmitters     []omitter.FileOmitter
 		k8sOmitters      []omitter.KubernetesResourceOmitter
 		expectedOmission bool
-		err              error
+		// expectedItemOmissions are the namespace/name of the list items that are expected to be omitted individually
+		expectedItemOmissions []string
+		// expectedLineOmissions is the number of lines that are expected to be omitted from the file
+		expectedLineOmissions int
+		err                   error
 	}{
 		{
 			name:         "simple",
@@ -237,6 +406,212 @@ metadata:
 			output:           "",
 			expectedOmission: true,
 		},
+		{
+			name: "secret items omitted from list",
+			input: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+    namespace: default
+  data:
+    ip: 192.178.1.2
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: token
+    namespace: default
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: cluster-token
+`,
+			output: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+    namespace: default
+  data:
+    ip: xxx.xxx.xxx.xxx
+`,
+			obfuscators:           []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:          []omitter.FileOmitter{},
+			k8sOmitters:           []omitter.KubernetesResourceOmitter{noErrorK8sSecretOmitter(t)},
+			expectedItemOmissions: []string{"default/token", "cluster-token"},
+		},
+		{
+			name: "secret documents omitted from multi-document yaml",
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+data:
+  ip: 192.178.1.2
+`,
+			output: `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+data:
+  ip: xxx.xxx.xxx.xxx
+`,
+			obfuscators:           []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:          []omitter.FileOmitter{},
+			k8sOmitters:           []omitter.KubernetesResourceOmitter{noErrorK8sSecretOmitter(t)},
+			expectedItemOmissions: []string{"default/token"},
+		},
+		{
+			name: "list omitted when all items are omitted",
+			input: `apiVersion: v1
+kind: SecretList
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: token
+    namespace: default
+`,
+			obfuscators:      []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:     []omitter.FileOmitter{},
+			k8sOmitters:      []omitter.KubernetesResourceOmitter{noErrorK8sSecretOmitter(t)},
+			expectedOmission: true,
+		},
+		{
+			name: "secret items omitted from json list before its kind",
+			input: `{
+    "apiVersion": "v1",
+    "items": [
+        {
+            "apiVersion": "v1",
+            "kind": "Secret",
+            "metadata": {"name": "token", "namespace": "default"}
+        },
+        {
+            "apiVersion": "v1",
+            "kind": "ConfigMap",
+            "metadata": {"name": "config", "namespace": "default"},
+            "data": {"ip": "192.178.1.2", "brackets": "]}\"["}
+        },
+        {
+            "apiVersion": "v1",
+            "kind": "Secret",
+            "metadata": {"name": "cluster-token"}
+        }
+    ],
+    "kind": "List",
+    "metadata": {"resourceVersion": ""}
+}
+`,
+			fileName: "test.json",
+			output: `{
+    "apiVersion": "v1",
+    "items": [
+        {
+            "apiVersion": "v1",
+            "kind": "ConfigMap",
+            "metadata": {"name": "config", "namespace": "default"},
+            "data": {"ip": "xxx.xxx.xxx.xxx", "brackets": "]}\"["}
+        }
+    ],
+    "kind": "List",
+    "metadata": {"resourceVersion": ""}
+}
+`,
+			obfuscators:           []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:          []omitter.FileOmitter{},
+			k8sOmitters:           []omitter.KubernetesResourceOmitter{noErrorK8sSecretOmitter(t)},
+			expectedItemOmissions: []string{"default/token", "cluster-token"},
+		},
+		{
+			name:                  "single line json list keeps valid separators",
+			input:                 `{"apiVersion":"v1","items":[{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}},{"apiVersion":"v1","kind":"Secret","metadata":{"name":"b"}},{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"c"}}],"kind":"List"}`,
+			fileName:              "test.json",
+			output:                `{"apiVersion":"v1","items":[{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}},{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"c"}}],"kind":"List"}`,
+			obfuscators:           []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:          []omitter.FileOmitter{},
+			k8sOmitters:           []omitter.KubernetesResourceOmitter{noErrorK8sSecretOmitter(t)},
+			expectedItemOmissions: []string{"b"},
+		},
+		{
+			name: "secret omitted before its data is obfuscated",
+			input: `apiVersion: v1
+data:
+  ip: 192.178.1.2
+kind: Secret
+metadata:
+  name: token
+`,
+			obfuscators:      []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:     []omitter.FileOmitter{},
+			k8sOmitters:      []omitter.KubernetesResourceOmitter{noErrorK8sSecretOmitter(t)},
+			expectedOmission: true,
+		},
+		{
+			name: "log lines outside the time range omitted",
+			input: `2023-04-12T09:00:00.000000000Z stdout F connecting to 192.178.1.1
+2023-04-12T10:00:00.000000000Z stdout F connecting to 192.178.1.2
+panic: something went wrong
+2023-04-12T13:00:00.000000000Z stdout F connecting to 192.178.1.3
+`,
+			fileName: "test.log",
+			output: `2023-04-12T10:00:00.000000000Z stdout F connecting to xxx.xxx.xxx.xxx
+panic: something went wrong
+`,
+			obfuscators:           []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:          []omitter.FileOmitter{},
+			k8sOmitters:           []omitter.KubernetesResourceOmitter{noErrorTimeRangeOmitter(t)},
+			expectedLineOmissions: 2,
+		},
+		{
+			name: "events outside the time range omitted",
+			input: `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Event
+  lastTimestamp: "2023-04-12T09:00:00Z"
+  message: Pulled image from 192.178.1.1
+  metadata:
+    name: before
+    namespace: default
+- apiVersion: v1
+  kind: Event
+  lastTimestamp: "2023-04-12T10:30:00Z"
+  message: Pulled image from 192.178.1.2
+  metadata:
+    name: within
+    namespace: default
+kind: EventList
+`,
+			output: `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Event
+  lastTimestamp: "2023-04-12T10:30:00Z"
+  message: Pulled image from xxx.xxx.xxx.xxx
+  metadata:
+    name: within
+    namespace: default
+kind: EventList
+`,
+			obfuscators:           []obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)},
+			fileOmitters:          []omitter.FileOmitter{},
+			k8sOmitters:           []omitter.KubernetesResourceOmitter{noErrorTimeRangeOmitter(t)},
+			expectedItemOmissions: []string{"default/before"},
+		},
 		{
 			name:        "error omitters",
 			obfuscators: []obfuscator.ReportingObfuscator{obfuscator.NoopObfuscator{}},
@@ -279,7 +654,10 @@ metadata:
 				_ = os.RemoveAll(tmpOutputDir)
 			}()
 
-			const testFileName = "test.yaml"
+			testFileName := "test.yaml"
+			if tc.fileName != "" {
+				testFileName = tc.fileName
+			}
 			if tc.input != "" {
 				f, err := os.Create(filepath.Join(tmpInputDir, testFileName))
 				require.NoError(t, err)
@@ -289,8 +667,8 @@ metadata:
 			}
 
 			reportingObfuscator := obfuscator.NewMultiObfuscator(tc.obfuscators)
-			multiOmitter := omitter.NewMultiReportingOmitter(tc.fileOmitters, tc.k8sOmitters)
-			fileCleaner := NewFileCleaner(tmpInputDir, tmpOutputDir, reportingObfuscator, multiOmitter)
+			multiOmitter := omitter.NewMultiReportingOmitter("", nil, tc.fileOmitters, tc.k8sOmitters)
+			fileCleaner := NewFileCleaner(tmpInputDir, tmpOutputDir, reportingObfuscator, multiOmitter, nil, false, nil, nil)
 
 			err = fileCleaner.Process(testFileName)
 			if tc.err != nil {
@@ -306,8 +684,22 @@ metadata:
 				require.Equal(t, tc.output, string(bytes))
 			}
 
+			// the reasons are tested with the omitters
+			var report []string
+			for _, o := range multiOmitter.Report() {
+				o.Reason = ""
+				report = append(report, o.String())
+			}
 			if tc.expectedOmission {
-				require.Contains(t, multiOmitter.Report(), filepath.Join(tmpInputDir, testFileName))
+				require.Contains(t, report, testFileName)
+			}
+
+			if tc.expectedLineOmissions > 0 {
+				require.Contains(t, report, fmt.Sprintf("%s (%d lines)", testFileName, tc.expectedLineOmissions))
+			}
+
+			for _, id := range tc.expectedItemOmissions {
+				require.Contains(t, report, testFileName+"#"+id)
 			}
 		})
 	}
@@ -319,3 +711,252 @@ func newFilePatternOmitter(t *testing.T, pattern string) omitter.FileOmitter {
 	require.NoError(t, err)
 	return o
 }
+
+func TestOmissionStubs(t *testing.T) {
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: pull-secret\n  namespace: openshift-config\ndata:\n  key: dmFsdWU=\n"
+	for _, tc := range []struct {
+		name          string
+		fileName      string
+		input         string
+		omissionStubs bool
+		fileOmitters  []omitter.FileOmitter
+		k8sOmitters   []omitter.KubernetesResourceOmitter
+		// expectedFile is the name of the stub in the output, empty if no stub is expected
+		expectedFile   string
+		expectedOutput string
+	}{
+		{
+			name:           "text stub",
+			fileName:       "core.log",
+			input:          "some log line",
+			omissionStubs:  true,
+			fileOmitters:   []omitter.FileOmitter{omitter.WithFileRule(newFilePatternOmitter(t, "*.log"), omitter.Rule{Index: 0, Type: schema.OmitTypeFile})},
+			expectedFile:   "core.log.omitted",
+			expectedOutput: "This file was omitted by must-gather-clean.\nrule: 0\ntype: File\nreason: matches '*.log'\nsize: 13\n",
+		},
+		{
+			name:          "yaml skeleton",
+			fileName:      "secret.yaml",
+			input:         secret,
+			omissionStubs: true,
+			fileOmitters:  []omitter.FileOmitter{omitter.WithFileRule(newFilePatternOmitter(t, "secret.yaml"), omitter.Rule{Index: 1, Type: schema.OmitTypeFile})},
+			expectedFile:  "secret.yaml",
+			expectedOutput: "apiVersion: v1\nkind: Secret\nmetadata:\n  annotations:\n" +
+				"    must-gather-clean.openshift.io/omitted: 'omitted by rule 1 (File): matches ''secret.yaml'''\n" +
+				"  name: pull-secret\n  namespace: openshift-config\n",
+		},
+		{
+			name:           "text stub of resources omitted for their size",
+			fileName:       "secret.yaml",
+			input:          secret,
+			omissionStubs:  true,
+			fileOmitters:   []omitter.FileOmitter{omitter.WithFileRule(newFilePatternOmitter(t, "secret.yaml"), omitter.Rule{Index: 3, Type: schema.OmitTypeMaxSize})},
+			expectedFile:   "secret.yaml.omitted",
+			expectedOutput: "This file was omitted by must-gather-clean.\nrule: 3\ntype: MaxSize\nreason: matches 'secret.yaml'\nsize: 110\n",
+		},
+		{
+			name:          "list skeleton of omitted items",
+			fileName:      "secrets.yaml",
+			input:         secret + "---\n" + strings.ReplaceAll(secret, "pull-secret", "other-secret"),
+			omissionStubs: true,
+			k8sOmitters:   []omitter.KubernetesResourceOmitter{omitter.WithKubernetesResourceRule(newSecretOmitter(t), omitter.Rule{Index: 2, Type: schema.OmitTypeKubernetes})},
+			expectedFile:  "secrets.yaml",
+			expectedOutput: "apiVersion: v1\nitems:\n" +
+				"- apiVersion: v1\n  kind: Secret\n  metadata:\n    annotations:\n      must-gather-clean.openshift.io/omitted: 'omitted by rule 2 (Kubernetes): matches\n        kind ''v1/Secret'''\n" +
+				"    name: pull-secret\n    namespace: openshift-config\n" +
+				"- apiVersion: v1\n  kind: Secret\n  metadata:\n    annotations:\n      must-gather-clean.openshift.io/omitted: 'omitted by rule 2 (Kubernetes): matches\n        kind ''v1/Secret'''\n" +
+				"    name: other-secret\n    namespace: openshift-config\n" +
+				"kind: List\n",
+		},
+		{
+			name:         "no stubs",
+			fileName:     "core.log",
+			input:        "some log line",
+			fileOmitters: []omitter.FileOmitter{newFilePatternOmitter(t, "*.log")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpInputDir := t.TempDir()
+			tmpOutputDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, tc.fileName), []byte(tc.input), 0600))
+
+			multiOmitter := omitter.NewMultiReportingOmitter(tmpInputDir, nil, tc.fileOmitters, tc.k8sOmitters)
+			fileCleaner := NewFileCleaner(tmpInputDir, tmpOutputDir, obfuscator.NewMultiObfuscator(nil), multiOmitter, nil, tc.omissionStubs, nil, nil)
+			require.NoError(t, fileCleaner.Process(tc.fileName))
+
+			entries, err := os.ReadDir(tmpOutputDir)
+			require.NoError(t, err)
+			if tc.expectedFile == "" {
+				require.Empty(t, entries)
+				return
+			}
+			require.Len(t, entries, 1)
+			assert.Equal(t, tc.expectedFile, entries[0].Name())
+			bytes, err := os.ReadFile(filepath.Join(tmpOutputDir, tc.expectedFile))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOutput, string(bytes))
+		})
+	}
+}
+
+func newSecretOmitter(t *testing.T) omitter.KubernetesResourceOmitter {
+	apiVersion, kind := "v1", "Secret"
+	o, err := omitter.NewKubernetesResourceOmitter(&apiVersion, &kind, nil, nil, nil, nil)
+	require.NoError(t, err)
+	return o
+}
+
+// classifierFunc classifies all files with the function.
+type classifierFunc func(path string) layout.Class
+
+func (f classifierFunc) Classify(path string) layout.Class {
+	return f(path)
+}
+
+func TestFileCleanerClassifier(t *testing.T) {
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: pull-secret\n  namespace: openshift-config\n"
+	hostFiles := classifierFunc(func(path string) layout.Class {
+		if strings.HasPrefix(path, "etc/") {
+			return layout.ClassHostFile
+		}
+		return layout.ClassResource
+	})
+
+	for _, tc := range []struct {
+		name       string
+		classifier layout.Classifier
+		path       string
+		omitted    bool
+	}{
+		{name: "all yaml files are resources without a classifier", path: "etc/secret.yaml", omitted: true},
+		{name: "resource", classifier: hostFiles, path: "secret.yaml", omitted: true},
+		{name: "host file", classifier: hostFiles, path: "etc/secret.yaml", omitted: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpInputDir, tmpOutputDir := t.TempDir(), t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmpInputDir, tc.path)), 0700))
+			require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, tc.path), []byte(secret), 0600))
+
+			multiOmitter := omitter.NewMultiReportingOmitter(tmpInputDir, nil, nil, []omitter.KubernetesResourceOmitter{newSecretOmitter(t)})
+			fileCleaner := NewFileCleaner(tmpInputDir, tmpOutputDir, obfuscator.NewMultiObfuscator(nil), multiOmitter, tc.classifier, false, nil, nil)
+			require.NoError(t, fileCleaner.Process(tc.path))
+
+			if tc.omitted {
+				assert.NoFileExists(t, filepath.Join(tmpOutputDir, tc.path))
+				return
+			}
+			bytes, err := os.ReadFile(filepath.Join(tmpOutputDir, tc.path))
+			require.NoError(t, err)
+			assert.Equal(t, secret, string(bytes))
+		})
+	}
+}
+
+type recordedOutputs map[string][]string
+
+func (r recordedOutputs) RecordOutput(inputFile string, outputFile string) {
+	r[inputFile] = append(r[inputFile], outputFile)
+}
+
+func TestIncrementalFileCleaner(t *testing.T) {
+	previousInputDir, previousOutputDir, inputDir, outputDir := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	for path, contents := range map[string]string{"unchanged.log": "ip 10.0.0.1\n", "changed.log": "ip 10.0.0.2\n", "omitted.log": "ip 10.0.0.3\n"} {
+		require.NoError(t, os.WriteFile(filepath.Join(previousInputDir, path), []byte(contents), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(inputDir, path), []byte(contents), 0600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "changed.log"), []byte("ip 10.0.0.4\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "new.log"), []byte("ip 10.0.0.5\n"), 0600))
+	// the previous output is recognizable, it is only reused for the unchanged file
+	for _, path := range []string{"unchanged.log", "changed.log", "omitted.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(previousOutputDir, path), []byte("previous\n"), 0600))
+	}
+
+	previous := PreviousRun{
+		InputPath:  previousInputDir,
+		OutputPath: previousOutputDir,
+		Omissions: map[string][]omitter.Omission{
+			"unchanged.log": {{Path: filepath.Join(inputDir, "unchanged.log"), Lines: 2}},
+			"omitted.log":   {{Path: "omitted.log", Reason: "placeholder"}},
+		},
+	}
+	reportingOmitter := omitter.NewMultiReportingOmitter(inputDir, nil, nil, nil)
+	recorder := recordedOutputs{}
+	fileCleaner := NewIncrementalFileCleaner(inputDir, outputDir, previous, obfuscator.NewMultiObfuscator([]obfuscator.ReportingObfuscator{noErrorIpObfuscator(t)}), reportingOmitter, nil, false, nil, recorder)
+	for _, path := range []string{"unchanged.log", "changed.log", "omitted.log", "new.log"} {
+		require.NoError(t, fileCleaner.Process(path))
+	}
+
+	for path, expected := range map[string]string{"unchanged.log": "previous\n", "changed.log": "ip xxx.xxx.xxx.xxx\n", "omitted.log": "ip xxx.xxx.xxx.xxx\n", "new.log": "ip xxx.xxx.xxx.xxx\n"} {
+		contents, err := os.ReadFile(filepath.Join(outputDir, path))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(contents), path)
+	}
+	previousStat, err := os.Stat(filepath.Join(previousOutputDir, "unchanged.log"))
+	require.NoError(t, err)
+	stat, err := os.Stat(filepath.Join(outputDir, "unchanged.log"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(previousStat, stat), "the previous output is hard linked")
+
+	assert.Equal(t, recordedOutputs{"unchanged.log": {"unchanged.log"}, "changed.log": {"changed.log"}, "omitted.log": {"omitted.log"}, "new.log": {"new.log"}}, recorder)
+	assert.Equal(t, []omitter.Omission{{Path: filepath.Join(inputDir, "unchanged.log"), Lines: 2}}, reportingOmitter.Report())
+}
+
+func TestIncrementalFileCleanerUnreplaced(t *testing.T) {
+	previousInputDir, previousOutputDir, inputDir, outputDir := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("node worker-7\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	for path, contents := range map[string][]byte{"known.log": []byte("node worker-1\n"), "new-node.log": []byte("node worker-7\n"), "new-node.log.gz": compressed.Bytes()} {
+		require.NoError(t, os.WriteFile(filepath.Join(previousInputDir, path), contents, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(inputDir, path), contents, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(previousOutputDir, path), []byte("previous\n"), 0600))
+	}
+
+	// the previous run did not know worker-7, the unchanged files that contain it are cleaned again
+	previous := PreviousRun{InputPath: previousInputDir, OutputPath: previousOutputDir, Unreplaced: []string{"worker-7"}}
+	fileCleaner := NewIncrementalFileCleaner(inputDir, outputDir, previous, obfuscator.NewMultiObfuscator(nil), omitter.NewMultiReportingOmitter(inputDir, nil, nil, nil), nil, false, nil, nil)
+	for _, path := range []string{"known.log", "new-node.log", "new-node.log.gz"} {
+		require.NoError(t, fileCleaner.Process(path))
+	}
+
+	for path, expected := range map[string]string{"known.log": "previous\n", "new-node.log": "node worker-7\n"} {
+		contents, err := os.ReadFile(filepath.Join(outputDir, path))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(contents), path)
+	}
+	contents, err := os.ReadFile(filepath.Join(outputDir, "new-node.log.gz"))
+	require.NoError(t, err)
+	assert.NotEqual(t, "previous\n", string(contents))
+}
+
+func TestSameContent(t *testing.T) {
+	dir := t.TempDir()
+	large := strings.Repeat("a", 100*1024)
+	for name, contents := range map[string]string{"a": "content", "same": "content", "other": "CONTENT", "longer": "content and more", "large": large + "a", "large-other": large + "b"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+	}
+
+	for _, tc := range []struct {
+		path     string
+		previous string
+		expected bool
+	}{
+		{"a", "same", true},
+		{"a", "other", false},
+		{"a", "longer", false},
+		{"a", "missing", false},
+		{"large", "large", true},
+		{"large", "large-other", false},
+	} {
+		t.Run(tc.path+"-"+tc.previous, func(t *testing.T) {
+			same, err := sameContent(filepath.Join(dir, tc.path), filepath.Join(dir, tc.previous))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, same)
+		})
+	}
+
+	_, err := sameContent(filepath.Join(dir, "missing"), filepath.Join(dir, "a"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
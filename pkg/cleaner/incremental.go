@@ -0,0 +1,229 @@
+package cleaner
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/fsutil"
+	"github.com/openshift/must-gather-clean/pkg/layout"
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"k8s.io/klog/v2"
+)
+
+// PreviousRun is an earlier cleaning of a must-gather, the files that did not change since then are taken from its output.
+type PreviousRun struct {
+	InputPath  string
+	OutputPath string
+	// Omissions are the omissions of the previous report by the path of their file relative to InputPath, the paths of the omissions
+	// themselves already refer to the current input.
+	Omissions map[string][]omitter.Omission
+	// Unreplaced are the values that the prescan discovered in the current input without a replacement in the previous run. The previous
+	// run did not obfuscate them, so an unchanged file that contains any of them is cleaned again.
+	Unreplaced []string
+}
+
+// IncrementalFileProcessor cleans a path like FileProcessor, unless the file is unchanged since the previous run. The previous output of
+// an unchanged file is hard linked, or copied if that fails, and its omissions are reported again.
+type IncrementalFileProcessor struct {
+	*FileProcessor
+
+	previous PreviousRun
+	omitter  omitter.ReportingOmitter
+}
+
+func (c *IncrementalFileProcessor) Process(path string) error {
+	reused, err := c.reuse(path)
+	if err != nil {
+		return err
+	}
+	if reused {
+		klog.V(3).Infof("reusing the previous output of the unchanged file %s", path)
+		return nil
+	}
+	return c.FileProcessor.Process(path)
+}
+
+// reuse links the previous output of the file if it is unchanged. It returns false when the file needs to be cleaned again, which is
+// also the case when the output of the previous run is ambiguous or may contain values that the previous run did not replace.
+func (c *IncrementalFileProcessor) reuse(path string) (bool, error) {
+	for _, o := range c.previous.Omissions[path] {
+		if o.Item == "" && o.Lines == 0 {
+			// the output of a whole omitted file can be a placeholder, the omission is decided again
+			return false, nil
+		}
+	}
+
+	readPath := filepath.Join(c.inputFolder, path)
+	unchanged, err := sameContent(readPath, filepath.Join(c.previous.InputPath, path))
+	if err != nil || !unchanged {
+		return false, err
+	}
+	if len(c.previous.Unreplaced) > 0 {
+		contains, err := containsAny(readPath, c.previous.Unreplaced)
+		if err != nil || contains {
+			return false, err
+		}
+	}
+
+	outputFile := c.FileContentObfuscator.Obfuscator.Path(path)
+	previousPath := filepath.Join(c.previous.OutputPath, outputFile)
+	previousStat, err := os.Lstat(previousPath)
+	if err != nil || !previousStat.Mode().IsRegular() {
+		return false, nil
+	}
+	// another input file had the same output path in the previous run, it is unknown which of them was written to previousPath
+	if _, err := os.Lstat(previousPath + ".1"); err == nil {
+		return false, nil
+	}
+
+	writePath := filepath.Join(c.outputFolder, outputFile)
+	err = fsutil.MkdirAllWithChown(filepath.Dir(writePath), filepath.Dir(readPath))
+	if err != nil {
+		return false, err
+	}
+	linked, err := c.linkUnderLock(previousPath, writePath, previousStat)
+	if err != nil || !linked {
+		return false, err
+	}
+
+	c.recordOutput(readPath, writePath)
+	c.omitter.Restore(c.previous.Omissions[path])
+	return true, nil
+}
+
+// linkUnderLock links or copies the previous output to the writePath, it returns false if the writePath already exists.
+func (c *IncrementalFileProcessor) linkUnderLock(previousPath string, writePath string, previousStat os.FileInfo) (bool, error) {
+	c.pathCollisionMutex.Lock()
+	defer c.pathCollisionMutex.Unlock()
+
+	_, err := os.Lstat(writePath)
+	if err == nil {
+		return false, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to determine if %s already exists: %w", writePath, err)
+	}
+
+	err = os.Link(previousPath, writePath)
+	if err == nil {
+		return true, nil
+	}
+	klog.V(1).Infof("could not link '%s' to '%s', copying instead. Error was: %v", previousPath, writePath, err)
+	return true, copyFile(previousPath, writePath, previousStat)
+}
+
+func copyFile(readPath string, writePath string, readPathStat os.FileInfo) error {
+	input, err := os.Open(readPath)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", readPath, err)
+	}
+	defer input.Close()
+
+	output, err := fsutil.CreateNonConflictingFile(writePath, readPathStat)
+	if err != nil {
+		return fmt.Errorf("failed to create and open '%s': %w", writePath, err)
+	}
+	_, err = io.Copy(output, input)
+	if err != nil {
+		_ = output.Close()
+		return fmt.Errorf("failed to copy '%s' to '%s': %w", readPath, writePath, err)
+	}
+	return output.Close()
+}
+
+// containsAny returns whether any line of the file, which is decompressed like the input of the cleaning, contains one of the values.
+func containsAny(path string, values []string) (bool, error) {
+	var input io.ReadCloser
+	input, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer input.Close()
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		input, err = gzip.NewReader(input)
+		if err != nil {
+			return false, fmt.Errorf("failed to create a gzip reader when opening '%s': %w", path, err)
+		}
+	}
+
+	reader := bufio.NewReader(input)
+	for {
+		line, err := reader.ReadString('\n')
+		for _, value := range values {
+			if strings.Contains(line, value) {
+				return true, nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read '%s': %w", path, err)
+		}
+	}
+}
+
+// sameContent returns whether both paths are regular files with the same content, a missing previous file is reported as a change.
+func sameContent(path string, previousPath string) (bool, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to lstat input file %s: %w", path, err)
+	}
+	previousStat, err := os.Lstat(previousPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lstat previous input file %s: %w", previousPath, err)
+	}
+	if !stat.Mode().IsRegular() || !previousStat.Mode().IsRegular() || stat.Size() != previousStat.Size() {
+		return false, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer file.Close()
+	previousFile, err := os.Open(previousPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open '%s': %w", previousPath, err)
+	}
+	defer previousFile.Close()
+
+	buffer, previousBuffer := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		n, err := io.ReadFull(file, buffer)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, fmt.Errorf("failed to read '%s': %w", path, err)
+		}
+		previousN, previousErr := io.ReadFull(previousFile, previousBuffer)
+		if previousErr != nil && !errors.Is(previousErr, io.EOF) && !errors.Is(previousErr, io.ErrUnexpectedEOF) {
+			return false, fmt.Errorf("failed to read '%s': %w", previousPath, previousErr)
+		}
+		if !bytes.Equal(buffer[:n], previousBuffer[:previousN]) {
+			return false, nil
+		}
+		if err != nil {
+			return previousErr != nil, nil
+		}
+	}
+}
+
+// NewIncrementalFileCleaner creates a cleaner like NewFileCleaner that takes the output of the files that did not change since the
+// previous run from its output.
+func NewIncrementalFileCleaner(inputPath string, outputPath string, previous PreviousRun, obfuscator obfuscator.Obfuscator, omitter omitter.ReportingOmitter, classifier layout.Classifier, omissionStubs bool, chunks *ChunkPool, recorder OutputRecorder) Processor {
+	return &IncrementalFileProcessor{
+		FileProcessor: NewFileCleaner(inputPath, outputPath, obfuscator, omitter, classifier, omissionStubs, chunks, recorder).(*FileProcessor),
+		previous:      previous,
+		omitter:       omitter,
+	}
+}
@@ -0,0 +1,192 @@
+package cleaner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/fsutil"
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OmittedAnnotation is set on the skeletons of omitted kubernetes resources, its value describes the omission.
+	OmittedAnnotation = "must-gather-clean.openshift.io/omitted"
+	// StubExtension is appended to the name of omitted files that are not kubernetes resources.
+	StubExtension = ".omitted"
+)
+
+type skeletonMetadata struct {
+	Name        string            `json:"name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// skeleton is the placeholder of an omitted kubernetes resource, it only keeps the fields that identify the resource.
+type skeleton struct {
+	ApiVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   *skeletonMetadata `json:"metadata,omitempty"`
+	Items      []skeleton        `json:"items,omitempty"`
+}
+
+// writeOmissionStub writes a placeholder in place of a file that was omitted entirely, so that tools reading the output can tell an omitted
+// file from a missing one. Kubernetes resource files are replaced by skeletons of their resources, all other files by a "<name>.omitted"
+// text file describing the omission. The resources are read from the input file when they are not given, unless the file was omitted by
+// a MaxSize rule. Symbolic links never get a stub. Without an output folder the stub is only obfuscated, which assigns the replacements of
+// its path, names and namespaces in the deterministic order of a dry-run.
+func (c *FileProcessor) writeOmissionStub(path string, resources []kube.Resource) error {
+	if !c.omissionStubs {
+		return nil
+	}
+
+	readPath := filepath.Join(c.inputFolder, path)
+	readPathStat, err := os.Lstat(readPath)
+	if err != nil {
+		return fmt.Errorf("failed to lstat input file %s: %w", readPath, err)
+	}
+	if !readPathStat.Mode().IsRegular() {
+		return nil
+	}
+
+	var omission omitter.Omission
+	if lookup, ok := c.omitter.(omitter.OmissionLookup); ok {
+		omission, _ = lookup.OmissionOf(path)
+	}
+
+	// a file omitted for its size is not read again, even its skeletons could be as large as the file
+	omittedForSize := omission.Rule != nil && omission.Rule.Type == schema.OmitTypeMaxSize
+	if resources == nil && c.isResourceFile(path) && !omittedForSize {
+		resources = readResources(readPath)
+	}
+
+	outputFile := c.FileContentObfuscator.Obfuscator.Path(path)
+	var content []byte
+	if len(resources) > 0 {
+		content, err = skeletonOf(path, resources, describeOmission(omission), c.obfuscateLine)
+		if err != nil {
+			return fmt.Errorf("failed to create the stub of '%s': %w", readPath, err)
+		}
+	} else {
+		outputFile += StubExtension
+		content = stubTextOf(omission)
+	}
+	if len(c.outputFolder) == 0 {
+		return nil
+	}
+
+	writePath := filepath.Join(c.outputFolder, outputFile)
+	err = fsutil.MkdirAllWithChown(filepath.Dir(writePath), filepath.Dir(readPath))
+	if err != nil {
+		return err
+	}
+	outputOsFile, err := c.createOutputFile(readPath, writePath, readPathStat)
+	if err != nil {
+		return err
+	}
+	if _, err := outputOsFile.Write(content); err != nil {
+		_ = outputOsFile.Close()
+		return fmt.Errorf("failed to write the stub of '%s': %w", readPath, err)
+	}
+	if err := outputOsFile.Close(); err != nil {
+		return fmt.Errorf("failed to close the stub of '%s': %w", readPath, err)
+	}
+	return nil
+}
+
+// readResources returns all kubernetes resources of the file, the parts that are not kubernetes resources are skipped.
+func readResources(readPath string) []kube.Resource {
+	input, err := os.Open(readPath)
+	if err != nil {
+		return nil
+	}
+	defer input.Close()
+
+	decoder, err := kube.NewResourceDecoder(readPath, input)
+	if err != nil {
+		return nil
+	}
+	var resources []kube.Resource
+	for {
+		part, err := decoder.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return nil
+			}
+			return resources
+		}
+		if part.Type != kube.ObjectPart {
+			continue
+		}
+		if resource, err := part.Resource(); err == nil && resource.Kind != "" && resource.ApiVersion != "" {
+			resources = append(resources, resource)
+		}
+	}
+}
+
+// describeOmission returns a short description of the rule and reason of the omission.
+func describeOmission(omission omitter.Omission) string {
+	description := "omitted"
+	if omission.Rule != nil {
+		description = fmt.Sprintf("omitted by rule %d (%s)", omission.Rule.Index, omission.Rule.Type)
+	}
+	if omission.Reason != "" {
+		description += ": " + omission.Reason
+	}
+	return description
+}
+
+// skeletonOf returns a single skeleton, or a List of skeletons for multiple resources, in the format of the file at path. The name and
+// namespace are obfuscated, the rest of the skeleton is not confidential.
+func skeletonOf(path string, resources []kube.Resource, description string, obfuscate func(string) string) ([]byte, error) {
+	var skeletons []skeleton
+	for _, r := range resources {
+		skeletons = append(skeletons, skeleton{
+			ApiVersion: r.ApiVersion,
+			Kind:       r.Kind,
+			Metadata: &skeletonMetadata{
+				Name:        obfuscate(r.Metadata.Name),
+				Namespace:   obfuscate(r.Metadata.Namespace),
+				Annotations: map[string]string{OmittedAnnotation: description},
+			},
+		})
+	}
+
+	object := skeletons[0]
+	if len(skeletons) > 1 {
+		object = skeleton{ApiVersion: "v1", Kind: "List", Items: skeletons}
+	}
+
+	if strings.HasSuffix(path, ".json") {
+		content, err := json.MarshalIndent(object, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(content, '\n'), nil
+	}
+	return yaml.Marshal(object)
+}
+
+// stubTextOf describes the omission of a file that is not a kubernetes resource.
+func stubTextOf(omission omitter.Omission) []byte {
+	var sb strings.Builder
+	sb.WriteString("This file was omitted by must-gather-clean.\n")
+	if omission.Rule != nil {
+		sb.WriteString(fmt.Sprintf("rule: %d\n", omission.Rule.Index))
+		sb.WriteString(fmt.Sprintf("type: %s\n", omission.Rule.Type))
+	}
+	if omission.Reason != "" {
+		sb.WriteString(fmt.Sprintf("reason: %s\n", omission.Reason))
+	}
+	if omission.Size > 0 {
+		sb.WriteString(fmt.Sprintf("size: %d\n", omission.Size))
+	}
+	return []byte(sb.String())
+}
@@ -1,141 +1,648 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/openshift/must-gather-clean/pkg/checkpoint"
 	"github.com/openshift/must-gather-clean/pkg/cleaner"
 	"github.com/openshift/must-gather-clean/pkg/fsutil"
+	"github.com/openshift/must-gather-clean/pkg/layout"
 	"github.com/openshift/must-gather-clean/pkg/obfuscator"
 	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"github.com/openshift/must-gather-clean/pkg/reporting"
 	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/openshift/must-gather-clean/pkg/suggester"
 	"github.com/openshift/must-gather-clean/pkg/traversal"
+	"github.com/openshift/must-gather-clean/pkg/validation"
 	watermarking "github.com/openshift/must-gather-clean/pkg/watermarker"
 	"k8s.io/klog/v2"
 )
 
 const (
 	reportFileName = "report.yaml"
+	// checkpointInterval is how often the progress of the cleaning is saved to resume an interrupted run
+	checkpointInterval = 30 * time.Second
 )
 
-func RunPipe(configPath string, stdin io.Reader, stdout io.Writer) error {
-	var multiObfuscator *obfuscator.MultiObfuscator
+// RunPipe obfuscates the text of stdin line by line to stdout. With a stateFile, the replacements of earlier and concurrent invocations
+// with the same stateFile are kept and the new ones are added to it. The state file is only locked while it is read and written, the
+// new replacements of the lines are saved before the lines are written.
+func RunPipe(configPath string, stdin io.Reader, stdout io.Writer, stateFile string) error {
+	var config *schema.SchemaJson
 	if configPath != "" {
-		config, err := schema.ReadConfigFromPath(configPath)
+		var err error
+		config, err = schema.ReadConfigFromPath(configPath)
 		if err != nil {
 			return fmt.Errorf("failed to read config at %s: %w", configPath, err)
 		}
-		// we cannot logically prescan because the end of input isn't clear
-		multiObfuscator, _, err = createObfuscatorsFromConfig(config)
-		if err != nil {
-			return fmt.Errorf("failed to create obfuscators via config at %s: %w", configPath, err)
+	}
+	newObfuscator := func() (*obfuscator.MultiObfuscator, error) {
+		if config != nil {
+			// we cannot logically prescan because the end of input isn't clear
+			multiObfuscator, _, err := createObfuscatorsFromConfig(config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create obfuscators via config at %s: %w", configPath, err)
+			}
+			return multiObfuscator, nil
 		}
-	} else {
+
 		ipObfuscator, err := obfuscator.NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, obfuscator.NewSimpleTracker())
 		if err != nil {
-			return fmt.Errorf("failed to create IP obfuscator: %w", err)
+			return nil, fmt.Errorf("failed to create IP obfuscator: %w", err)
 		}
 
 		macObfuscator, err := obfuscator.NewMacAddressObfuscator(schema.ObfuscateReplacementTypeConsistent, obfuscator.NewSimpleTracker())
 		if err != nil {
-			return fmt.Errorf("failed to create MAC obfuscator: %w", err)
+			return nil, fmt.Errorf("failed to create MAC obfuscator: %w", err)
 		}
 
-		multiObfuscator = obfuscator.NewMultiObfuscator([]obfuscator.ReportingObfuscator{
+		return obfuscator.NewMultiObfuscator([]obfuscator.ReportingObfuscator{
 			ipObfuscator,
 			macObfuscator,
-		})
+		}), nil
+	}
+
+	var pipe *pipeObfuscator
+	if stateFile != "" {
+		// the default obfuscators have no config, their state is written without a digest
+		var configDigest string
+		var err error
+		if configPath != "" {
+			configDigest, err = checkpoint.ConfigDigest(configPath)
+			if err != nil {
+				return err
+			}
+		}
+		pipe, err = newStatePipeObfuscator(stateFile, configDigest, newObfuscator)
+		if err != nil {
+			return err
+		}
+	} else {
+		multiObfuscator, err := newObfuscator()
+		if err != nil {
+			return err
+		}
+		pipe = &pipeObfuscator{obfuscator: multiObfuscator}
+	}
+
+	reader := bufio.NewReaderSize(stdin, pipeBatchSize)
+	for {
+		batch, readErr := readBatch(reader)
+		if batch != "" {
+			obfuscated, err := pipe.obfuscate(batch)
+			if err != nil {
+				return fmt.Errorf("failed to obfuscate via pipe: %w", err)
+			}
+			_, err = io.WriteString(stdout, obfuscated)
+			if err != nil {
+				return fmt.Errorf("failed to obfuscate via pipe: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to obfuscate via pipe: %w", readErr)
+		}
+	}
+}
+
+// RunPipeTar cleans the must-gather in the tar stream of stdin like Run and writes the cleaned must-gather as a tar stream to stdout. The
+// prescan needs the whole input, so the stream is extracted into a temporary folder first. The report is only written when the options
+// have a reporting folder. A stream can neither be resumed nor cleaned against a previous run.
+func RunPipeTar(configPath string, stdin io.Reader, stdout io.Writer, options RunOptions) error {
+	if configPath == "" {
+		return fmt.Errorf("a config is required to clean a tar stream")
+	}
+	if options.Resume || options.Previous != (Previous{}) {
+		return fmt.Errorf("a tar stream can't be resumed or cleaned against a previous run")
 	}
 
-	contentObfuscator := cleaner.ContentObfuscator{Obfuscator: multiObfuscator}
-	err := contentObfuscator.ObfuscateReader(stdin, stdout)
+	tmpFolder, err := os.MkdirTemp("", "must-gather-clean-*")
 	if err != nil {
-		return fmt.Errorf("failed to obfuscate via pipe: %w", err)
+		return fmt.Errorf("failed to create temporary folder: %w", err)
 	}
+	defer func() {
+		_ = os.RemoveAll(tmpFolder)
+	}()
 
+	inputPath, outputPath := filepath.Join(tmpFolder, "input"), filepath.Join(tmpFolder, "output")
+	err = os.Mkdir(inputPath, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary folder: %w", err)
+	}
+	err = fsutil.ExtractTar(stdin, inputPath)
+	if err != nil {
+		return err
+	}
+
+	if options.ReportingFolder == "" {
+		options.ReportingFolder = filepath.Join(tmpFolder, "report")
+	}
+	// the output folder is created in the temporary folder
+	options.DeleteOutputFolder = false
+	err = Run(configPath, inputPath, outputPath, options)
+	if err != nil {
+		return err
+	}
+
+	var modTime time.Time
+	if options.Deterministic {
+		modTime = time.Unix(0, 0)
+	}
+	err = fsutil.WriteTar(outputPath, stdout, modTime)
+	if err != nil {
+		return fmt.Errorf("failed to write the cleaned tar stream: %w", err)
+	}
 	return nil
 }
 
-func Run(configPath string, inputPath string, outputPath string, deleteOutputFolder bool, reportingFolder string, workerCount int) error {
-	if workerCount < 1 {
-		return fmt.Errorf("invalid number of workers specified %d", workerCount)
+// RunValidateConfig validates the configuration semantically and prints all found issues to the given writer.
+// It returns an error when the configuration can't be read or contains at least one issue of severity error.
+func RunValidateConfig(configPath string, stdout io.Writer) error {
+	result, err := validation.ValidateConfigFromPath(configPath)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range result.Issues {
+		separator := ":"
+		if issue.Line == 0 {
+			separator = ": "
+		}
+		_, err = fmt.Fprintf(stdout, "%s%s%s\n", configPath, separator, issue)
+		if err != nil {
+			return err
+		}
+	}
+
+	if result.HasErrors() {
+		return fmt.Errorf("config at %s is invalid", configPath)
+	}
+	return nil
+}
+
+// RunSuggestConfig discovers cluster specific domains and names in the must-gather and writes a starter configuration to the given writer.
+func RunSuggestConfig(inputPath string, stdout io.Writer) error {
+	_, err := os.Stat(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat input folder: %w", err)
+	}
+
+	details, err := suggester.DiscoverClusterDetails(inputPath)
+	if err != nil {
+		return err
+	}
+
+	config, err := details.SuggestConfig()
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(config)
+	return err
+}
+
+// Previous is an earlier run of the same configuration, the output of the files that did not change since then is reused. All paths are
+// empty when the must-gather is cleaned from scratch.
+type Previous struct {
+	InputPath  string
+	OutputPath string
+	// ReportPath is the report of the previous run, its replacements are kept for the new run
+	ReportPath string
+}
+
+// RunOptions configures how Run cleans a must-gather.
+type RunOptions struct {
+	// ReportingFolder receives the report, and the checkpoint while the cleaning is in progress.
+	ReportingFolder string
+	// DeleteOutputFolder deletes an existing output folder and all its contents before the cleaning.
+	DeleteOutputFolder bool
+	// WorkerCount is the number of workers that clean the files, and that obfuscate the chunks of large files.
+	WorkerCount   int
+	OmissionStubs bool
+	// Deterministic produces byte-identical output for the same input and config regardless of the WorkerCount, at the cost of an
+	// additional pass and a prescan with a single worker.
+	Deterministic bool
+	// ProgressMode is how the progress of each pass is written to stderr.
+	ProgressMode progress.Mode
+	// Layout is the name of the layout that classifies the files, it is detected from the input for layout.Auto.
+	Layout string
+	// LayoutDefaults adds the default omissions of the layout to the configuration.
+	LayoutDefaults bool
+	// Resume continues an interrupted run from its checkpoint in the ReportingFolder into the same output folder.
+	Resume bool
+	// Previous is an earlier run of the same configuration, only the files that changed since then are cleaned again.
+	Previous Previous
+}
+
+// Run cleans the must-gather at inputPath into outputPath. The progress of the cleaning is periodically saved as a checkpoint in the
+// reporting folder of the options, from which an interrupted run can be resumed.
+func Run(configPath string, inputPath string, outputPath string, options RunOptions) error {
+	if options.WorkerCount < 1 {
+		return fmt.Errorf("invalid number of workers specified %d", options.WorkerCount)
+	}
+	if options.Resume && options.DeleteOutputFolder {
+		return fmt.Errorf("an interrupted run can't be resumed when the output folder is deleted")
 	}
 
-	err := fsutil.EnsureInputOutputPath(inputPath, outputPath, deleteOutputFolder)
+	progressReporter, err := progress.NewReporter(options.ProgressMode, os.Stderr)
 	if err != nil {
 		return err
 	}
 
+	inputLayout, err := layout.ForInput(options.Layout, inputPath)
+	if err != nil {
+		return err
+	}
+	klog.V(1).Infof("cleaning %s with the %s layout", inputPath, inputLayout.Name())
+
+	checkpointPath := filepath.Join(options.ReportingFolder, checkpoint.FileName)
+	var cp *checkpoint.Checkpoint
+	if options.Resume {
+		cp, err = resumeFromCheckpoint(checkpointPath, configPath, inputPath, outputPath, options.Deterministic, inputLayout.Name(), options.LayoutDefaults)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = fsutil.EnsureInputOutputPath(inputPath, outputPath, options.DeleteOutputFolder)
+		if err != nil {
+			return err
+		}
+	}
+
 	config, err := schema.ReadConfigFromPath(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to read config at %s: %w", configPath, err)
 	}
+	if options.LayoutDefaults {
+		err = layout.AddDefaultOmissions(config, inputLayout)
+		if err != nil {
+			return fmt.Errorf("failed to add the default omissions of the %s layout: %w", inputLayout.Name(), err)
+		}
+	}
+
+	var previousRun *cleaner.PreviousRun
+	var previousStates []obfuscator.State
+	if options.Previous != (Previous{}) {
+		previousRun, previousStates, err = readPrevious(options.Previous, config)
+		if err != nil {
+			return err
+		}
+	}
 
 	obfuscator, prescanObfuscator, err := createObfuscatorsFromConfig(config)
 	if err != nil {
 		return fmt.Errorf("failed to create obfuscators via config at %s: %w", configPath, err)
 	}
 
-	// this pass allows obfuscators that first need to scan the input to determine what needs to be obfuscated to run before
-	// redactor actually happens. The empty input path signals a dry-run.
-	prescanCleaner := cleaner.NewFileCleaner(inputPath, "", prescanObfuscator, &omitter.NoopOmitter{})
-	prescanWorkerFactory := func(id int) traversal.QueueProcessor {
-		return traversal.NewWorker(id, prescanCleaner)
+	if options.Resume {
+		// the checkpoint contains everything the prescan and the assignment of the replacements found
+		err = obfuscator.Restore(cp.Obfuscators)
+		if err != nil {
+			return fmt.Errorf("failed to restore the obfuscators from the checkpoint: %w", err)
+		}
+	} else {
+		cp, err = checkpoint.New(configPath, options.Deterministic, inputLayout.Name(), options.LayoutDefaults)
+		if err != nil {
+			return err
+		}
+		if previousRun != nil {
+			err = obfuscator.Restore(previousStates)
+			if err != nil {
+				return fmt.Errorf("failed to restore the obfuscators from the previous report: %w", err)
+			}
+			// new originals must not get the replacement of an original of the previous run. The restored counts are zero, the occurrences
+			// in the reused files are not counted again.
+			obfuscator.SkipSeededReplacements()
+		}
+		err = prescanAndAssign(config, inputPath, inputLayout, obfuscator, prescanObfuscator, options.WorkerCount, options.Deterministic, options.OmissionStubs, progressReporter)
+		if err != nil {
+			return err
+		}
+	}
+	if previousRun != nil {
+		previousRun.Unreplaced = obfuscator.Unreplaced(previousStates)
+		if len(previousRun.Unreplaced) > 0 {
+			klog.V(1).Infof("the prescan discovered %d values that the previous run did not replace, unchanged files containing them are cleaned again", len(previousRun.Unreplaced))
+		}
 	}
-	traversal.NewParallelFileWalker(inputPath, workerCount, prescanWorkerFactory).Traverse()
 
-	mro, err := createOmittersFromConfig(config, inputPath)
+	mro, err := createOmittersFromConfig(config, inputPath, inputLayout)
 	if err != nil {
 		return fmt.Errorf("failed to create omitters via config at %s: %w", configPath, err)
 	}
-	fileCleaner := cleaner.NewFileCleaner(inputPath, outputPath, obfuscator, mro)
+	mro.Restore(cp.Omissions)
+
+	if options.ReportingFolder != "" {
+		err = os.MkdirAll(options.ReportingFolder, 0700)
+		if err != nil {
+			return fmt.Errorf("failed to create reporting folder: %w", err)
+		}
+	}
+	store := checkpoint.NewStore(checkpointPath, cp, obfuscator, mro)
+	// the first checkpoint allows to options.Resume a run without repeating the prescan
+	err = store.Save()
+	if err != nil {
+		return err
+	}
+	var fileCleaner cleaner.Processor
+	chunks := cleaner.NewChunkPool(options.WorkerCount)
+	if previousRun != nil {
+		fileCleaner = cleaner.NewIncrementalFileCleaner(inputPath, outputPath, *previousRun, obfuscator, mro, inputLayout, options.OmissionStubs, chunks, store)
+	} else {
+		fileCleaner = cleaner.NewFileCleaner(inputPath, outputPath, obfuscator, mro, inputLayout, options.OmissionStubs, chunks, store)
+	}
+	fileCleaner = store.Processor(fileCleaner)
 
 	workerFactory := func(id int) traversal.QueueProcessor {
 		return traversal.NewWorker(id, fileCleaner)
 	}
-	traversal.NewParallelFileWalker(inputPath, workerCount, workerFactory).Traverse()
+	stopCheckpoints := store.SaveEvery(checkpointInterval)
+	err = traverse(traversal.NewParallelFileWalker(inputPath, options.WorkerCount, workerFactory), progressReporter, "clean")
+	stopCheckpoints()
+	if err != nil {
+		return fmt.Errorf("failed to clean: %w", err)
+	}
 
 	reporter := reporting.NewSimpleReporter(config)
 	reporter.CollectOmitterReport(mro.Report())
 	reporter.CollectObfuscatorReport(obfuscator.ReportPerObfuscator())
-	reporterErr := reporter.WriteReport(filepath.Join(reportingFolder, reportFileName))
+	reporterErr := reporter.WriteReport(filepath.Join(options.ReportingFolder, reportFileName))
 	if reporterErr != nil {
 		return reporterErr
 	}
 
 	watermarker := watermarking.NewSimpleWaterMarker()
-	return watermarker.WriteWaterMarkFile(outputPath)
+	if options.Deterministic {
+		watermarker = watermarking.NewDeterministicWaterMarker()
+	}
+	err = watermarker.WriteWaterMarkFile(outputPath)
+	if err != nil {
+		return err
+	}
+	return store.Remove()
+}
+
+// readPrevious reads the report of the previous run, which must have been created with the same configuration. It returns the states of the
+// obfuscators with all replacements of the previous run.
+func readPrevious(previous Previous, config *schema.SchemaJson) (*cleaner.PreviousRun, []obfuscator.State, error) {
+	if previous.InputPath == "" || previous.OutputPath == "" || previous.ReportPath == "" {
+		return nil, nil, fmt.Errorf("the input, output and report of the previous run must all be given")
+	}
+	for _, path := range []string{previous.InputPath, previous.OutputPath} {
+		_, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to stat folder of the previous run: %w", err)
+		}
+	}
+
+	previousConfig, err := schema.ReadConfigFromPath(previous.ReportPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config of the previous report at %s: %w", previous.ReportPath, err)
+	}
+	err = verifyPreviousConfig(config, previousConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the previous report at %s can't be reused: %w", previous.ReportPath, err)
+	}
+	report, err := reporting.ReadReport(previous.ReportPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the omissions are reported with paths relative to the input, they apply to the same paths of the new input
+	omissions := map[string][]omitter.Omission{}
+	for _, o := range report.OmitterOmissions() {
+		omissions[o.Path] = append(omissions[o.Path], o)
+	}
+	previousRun := &cleaner.PreviousRun{InputPath: previous.InputPath, OutputPath: previous.OutputPath, Omissions: omissions}
+	return previousRun, report.ObfuscatorStates(), nil
+}
+
+// verifyPreviousConfig returns an error if the configuration of a previous report differs from the given one in more than the replacements
+// that the previous run added.
+func verifyPreviousConfig(config *schema.SchemaJson, previousConfig *schema.SchemaJson) error {
+	if len(config.Config.Obfuscate) != len(previousConfig.Config.Obfuscate) {
+		return fmt.Errorf("the number of obfuscators differs")
+	}
+	for i, o := range config.Config.Obfuscate {
+		for original, replacement := range o.Replacement {
+			if r, ok := previousConfig.Config.Obfuscate[i].Replacement[original]; !ok || r != replacement {
+				return fmt.Errorf("the replacement of '%s' of obfuscator %d differs", original, i)
+			}
+		}
+	}
+
+	// the replacements are compared above, the remaining configurations must be identical
+	withoutReplacements := func(c schema.SchemaJsonConfig) ([]byte, error) {
+		c.Obfuscate = append([]schema.Obfuscate{}, c.Obfuscate...)
+		for i := range c.Obfuscate {
+			c.Obfuscate[i].Replacement = nil
+		}
+		return json.Marshal(c)
+	}
+	current, err := withoutReplacements(config.Config)
+	if err != nil {
+		return err
+	}
+	previous, err := withoutReplacements(previousConfig.Config)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, previous) {
+		return fmt.Errorf("the configuration differs")
+	}
+	return nil
+}
+
+// resumeFromCheckpoint loads the checkpoint of an interrupted run and removes the output of the files that were not completed.
+func resumeFromCheckpoint(checkpointPath string, configPath string, inputPath string, outputPath string, deterministic bool, layoutName string, layoutDefaults bool) (*checkpoint.Checkpoint, error) {
+	_, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input folder: %w", err)
+	}
+
+	cp, err := checkpoint.Load(checkpointPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume: %w", err)
+	}
+	err = cp.Verify(configPath, deterministic, layoutName, layoutDefaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume: %w", err)
+	}
+	err = cp.RemoveIncompleteOutput(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove the incomplete output: %w", err)
+	}
+	return cp, nil
+}
+
+// prescanAndAssign runs the prescan and, with deterministic, assigns the replacements before the actual cleaning.
+func prescanAndAssign(config *schema.SchemaJson, inputPath string, classifier layout.Classifier, multiObfuscator *obfuscator.MultiObfuscator, prescanObfuscator *obfuscator.MultiObfuscator, workerCount int, deterministic bool, omissionStubs bool, progressReporter *progress.Reporter) error {
+	// this pass allows obfuscators that first need to scan the input to determine what needs to be obfuscated to run before
+	// redactor actually happens. The empty input path signals a dry-run.
+	prescanWorkerCount := workerCount
+	if deterministic {
+		// some scanners already generate replacements, a single worker visits the files in lexical order
+		prescanWorkerCount = 1
+	}
+	prescanCleaner := cleaner.NewPrescanFileCleaner(inputPath, prescanObfuscator, classifier, prescanObfuscator.KubernetesResourceScanners(), cleaner.NewChunkPool(prescanWorkerCount))
+	prescanWorkerFactory := func(id int) traversal.QueueProcessor {
+		return traversal.NewWorker(id, prescanCleaner)
+	}
+	err := traverse(traversal.NewParallelFileWalker(inputPath, prescanWorkerCount, prescanWorkerFactory), progressReporter, "prescan")
+	if err != nil {
+		return fmt.Errorf("failed to prescan: %w", err)
+	}
+
+	if deterministic {
+		err = assignReplacements(config, inputPath, classifier, multiObfuscator, omissionStubs, progressReporter)
+		if err != nil {
+			return fmt.Errorf("failed to assign replacements: %w", err)
+		}
+	}
+	return nil
 }
 
-func createOmittersFromConfig(config *schema.SchemaJson, inputPath string) (omitter.ReportingOmitter, error) {
+// assignReplacements runs a dry-run over the input with a single worker, which visits the files in lexical order and obfuscates their lines
+// one after another. This numbers the consistent replacements by their first occurrence instead of by the scheduling of the workers.
+// With omissionStubs, the names and namespaces of the stubs of omitted files are numbered in the same order. The counts are reset
+// afterwards, so that the report only contains the occurrences of the actual cleaning.
+func assignReplacements(config *schema.SchemaJson, inputPath string, classifier layout.Classifier, multiObfuscator *obfuscator.MultiObfuscator, omissionStubs bool, progressReporter *progress.Reporter) error {
+	// the omissions of this pass are not reported, but the content of omitted files must not be numbered
+	mro, err := createOmittersFromConfig(config, inputPath, classifier)
+	if err != nil {
+		return err
+	}
+	dryRunCleaner := cleaner.NewFileCleaner(inputPath, "", multiObfuscator, mro, classifier, omissionStubs, nil, nil)
+	workerFactory := func(id int) traversal.QueueProcessor {
+		return traversal.NewWorker(id, dryRunCleaner)
+	}
+	err = traverse(traversal.NewParallelFileWalker(inputPath, 1, workerFactory), progressReporter, "assign")
+	if err != nil {
+		return err
+	}
+
+	multiObfuscator.ResetCounts()
+	return nil
+}
+
+// traverse runs the walker and reports its progress as the given phase.
+func traverse(walker *traversal.FileWalker, progressReporter *progress.Reporter, phase string) error {
+	tracker := progress.NewTracker(phase)
+	stop := progressReporter.Track(tracker)
+	err := walker.WithProgress(tracker).Walk()
+	stop()
+	return err
+}
+
+// createOmittersFromConfig creates the omitters of all rules, the classifier tells the TimeRange rules about the log files of the input.
+func createOmittersFromConfig(config *schema.SchemaJson, inputPath string, classifier layout.Classifier) (omitter.ReportingOmitter, error) {
 	var fileOmitters []omitter.FileOmitter
 	var k8sOmitters []omitter.KubernetesResourceOmitter
-	for _, o := range config.Config.Omit {
+	// all include rules are combined, everything that is included by any of them is kept
+	var includedNamespaces, includedPatterns []string
+	var includeRule *omitter.Rule
+	for i, o := range config.Config.Omit {
+		rule := omitter.Rule{Index: i, Type: o.Type}
 		switch o.Type {
+		case schema.OmitTypeInclude:
+			if o.Include == nil {
+				return nil, fmt.Errorf("type Include must also include an 'include'. Given: %v", o)
+			}
+			if includeRule == nil {
+				includeRule = &rule
+			}
+			includedNamespaces = append(includedNamespaces, o.Include.Namespaces...)
+			includedPatterns = append(includedPatterns, o.Include.Patterns...)
 		case schema.OmitTypeSymbolicLink:
-			fileOmitters = append(fileOmitters, omitter.NewSymlinkOmitter(inputPath))
+			fileOmitters = append(fileOmitters, omitter.WithFileRule(omitter.NewSymlinkOmitter(inputPath), rule))
 		case schema.OmitTypeFile:
+			if o.Pattern == nil {
+				return nil, fmt.Errorf("type File must also include a 'pattern'. Given: %v", o)
+			}
 			om, err := omitter.NewFilenamePatternOmitter(*o.Pattern)
 			if err != nil {
 				return nil, err
 			}
-			fileOmitters = append(fileOmitters, om)
+			fileOmitters = append(fileOmitters, omitter.WithFileRule(om, rule))
+		case schema.OmitTypePathRegex:
+			if o.PathRegex == nil {
+				return nil, fmt.Errorf("type PathRegex must also include a 'pathRegex'. Given: %v", o)
+			}
+			om, err := omitter.NewPathRegexOmitter(*o.PathRegex)
+			if err != nil {
+				return nil, err
+			}
+			fileOmitters = append(fileOmitters, omitter.WithFileRule(om, rule))
+		case schema.OmitTypeMaxSize:
+			if o.MaxSize == nil {
+				return nil, fmt.Errorf("type MaxSize must also include a 'maxSize'. Given: %v", o)
+			}
+			om, err := omitter.NewMaxSizeOmitter(inputPath, *o.MaxSize)
+			if err != nil {
+				return nil, err
+			}
+			fileOmitters = append(fileOmitters, omitter.WithFileRule(om, rule))
+		case schema.OmitTypeFileType:
+			om, err := omitter.NewFileTypeOmitter(inputPath, o.FileTypes)
+			if err != nil {
+				return nil, err
+			}
+			fileOmitters = append(fileOmitters, omitter.WithFileRule(om, rule))
+		case schema.OmitTypeContainsRegex:
+			if o.ContainsRegex == nil {
+				return nil, fmt.Errorf("type ContainsRegex must also include a 'containsRegex'. Given: %v", o)
+			}
+			om, err := omitter.NewContainsRegexOmitter(inputPath, *o.ContainsRegex)
+			if err != nil {
+				return nil, err
+			}
+			fileOmitters = append(fileOmitters, omitter.WithFileRule(om, rule))
 		case schema.OmitTypeKubernetes:
 			if o.KubernetesResource == nil {
-				klog.Exitf("type Kubernetes must also include a 'kubernetesResource'. Given: %v", o)
+				return nil, fmt.Errorf("type Kubernetes must also include a 'kubernetesResource'. Given: %v", o)
 			}
 			kr := *o.KubernetesResource
-			om, err := omitter.NewKubernetesResourceOmitter(kr.ApiVersion, kr.Kind, kr.Namespaces)
+			om, err := omitter.NewKubernetesResourceOmitter(kr.ApiVersion, kr.Kind, kr.Namespaces, kr.Names, kr.LabelSelector, kr.Annotations)
+			if err != nil {
+				return nil, err
+			}
+			k8sOmitters = append(k8sOmitters, omitter.WithKubernetesResourceRule(om, rule))
+		case schema.OmitTypeTimeRange:
+			if o.TimeRange == nil {
+				return nil, fmt.Errorf("type TimeRange must also include a 'timeRange'. Given: %v", o)
+			}
+			isLog := func(path string) bool {
+				return classifier.Classify(path).IsLog()
+			}
+			om, err := omitter.NewTimeRangeOmitter(o.TimeRange.Since, o.TimeRange.Until, isLog)
 			if err != nil {
 				return nil, err
 			}
-			k8sOmitters = append(k8sOmitters, om)
+			k8sOmitters = append(k8sOmitters, omitter.WithKubernetesResourceRule(om, rule))
+		}
+	}
+
+	var includer omitter.Includer
+	if includeRule != nil {
+		namespaceIncluder, err := omitter.NewNamespaceIncluder(includedNamespaces, includedPatterns)
+		if err != nil {
+			return nil, err
 		}
+		includer = omitter.WithIncluderRule(namespaceIncluder, *includeRule)
 	}
 
-	return omitter.NewMultiReportingOmitter(fileOmitters, k8sOmitters), nil
+	return omitter.NewMultiReportingOmitter(inputPath, includer, fileOmitters, k8sOmitters), nil
 }
 
 // finalObfuscator is the obfuscator to use to actually clean a directory.
@@ -179,6 +686,20 @@ func createObfuscatorsFromConfig(config *schema.SchemaJson) (finalObfuscator *ob
 				return nil, nil, err
 			}
 			prescanObfuscators = append(prescanObfuscators, k)
+		case schema.ObfuscateTypeClusterIdentity:
+			c, err := obfuscator.NewClusterIdentityObfuscator(o.ReplacementType, tracker)
+			if err != nil {
+				return nil, nil, err
+			}
+			k = c
+			prescanObfuscators = append(prescanObfuscators, obfuscator.NewScanOnlyObfuscator(c))
+		case schema.ObfuscateTypeHostname:
+			h, err := obfuscator.NewHostnameObfuscator(o.ReplacementType, o.KeepHostnameStructure != nil && *o.KeepHostnameStructure, tracker)
+			if err != nil {
+				return nil, nil, err
+			}
+			k = h
+			prescanObfuscators = append(prescanObfuscators, obfuscator.NewScanOnlyObfuscator(h))
 		case schema.ObfuscateTypeExact:
 			k = obfuscator.NewExactReplacementObfuscator(o.ExactReplacements, tracker)
 		case schema.ObfuscateTypeIP:
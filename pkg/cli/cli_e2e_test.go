@@ -12,10 +12,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
-	"strings"
 	"testing"
 
+	"github.com/openshift/must-gather-clean/pkg/layout"
 	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"github.com/openshift/must-gather-clean/pkg/reporting"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,29 +52,17 @@ func TestEndToEnd(t *testing.T) {
 	generatedReportDir := path.Join(rootDir, fmt.Sprintf("%s-report", input))
 	reportPath := path.Join(rootDir, report)
 
-	err := Run(configPath,
-		inputDir,
-		outputDir,
-		true,
-		generatedReportDir,
-		runtime.NumCPU())
+	err := Run(configPath, inputDir, outputDir, RunOptions{ReportingFolder: generatedReportDir, DeleteOutputFolder: true, WorkerCount: runtime.NumCPU(), ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
 	require.NoError(t, err)
 
 	// read reports
 	truthReport := readReport(t, reportPath)
 	generatedReport := readReport(t, filepath.Join(generatedReportDir, reportFileName))
-	removeRelativePath(generatedReport, inputDir)
 	// compare reports
 	verifyReport(t, inputDir, truthReport, generatedReport)
 	verifyObfuscation(t, outputDir, generatedReport)
 }
 
-func removeRelativePath(r *reporting.Report, path string) {
-	for i := range r.Omissions {
-		r.Omissions[i] = strings.TrimPrefix(r.Omissions[i], path)
-	}
-}
-
 func verifyObfuscation(t *testing.T, dir string, report *reporting.Report) {
 	// report should already be verified by verifyReport before to ensure it does contain correct information
 	generatedMap := map[string]string{}
@@ -125,9 +114,21 @@ func verifyReplacements(t *testing.T, inputDir string, truthReport *reporting.Re
 }
 
 func verifyOmissions(t *testing.T, inputDir string, truthReport *reporting.Report, generatedReport *reporting.Report) {
-	sort.Strings(truthReport.Omissions)
-	sort.Strings(generatedReport.Omissions)
-	assert.Equal(t, truthReport.Omissions, generatedReport.Omissions)
+	assert.Equal(t, omittedPaths(truthReport), omittedPaths(generatedReport))
+}
+
+// omittedPaths returns the sorted paths of all omissions, the paths of omitted resources are suffixed with "#namespace/name".
+func omittedPaths(report *reporting.Report) []string {
+	var paths []string
+	for _, o := range report.Omissions {
+		if o.Item != "" {
+			paths = append(paths, o.Path+"#"+o.Item)
+		} else {
+			paths = append(paths, o.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
 }
 
 func reportInternalRepresentation(report *reporting.Report) obfuscator.ReplacementReport {
@@ -1,26 +1,44 @@
 package cli
 
 import (
+	"archive/tar"
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/openshift/must-gather-clean/pkg/checkpoint"
+	"github.com/openshift/must-gather-clean/pkg/cleaner"
 	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/layout"
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/openshift/must-gather-clean/pkg/statefile"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRunFailsOnNegativeAndZeroWorkers(t *testing.T) {
-	err := Run("", "", "", false, "", 0)
+	err := Run("", "", "", RunOptions{WorkerCount: 0, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
 	assert.Equal(t, fmt.Errorf("invalid number of workers specified %d", 0), err)
-	err = Run("", "", "", false, "", -2)
+	err = Run("", "", "", RunOptions{WorkerCount: -2, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
 	assert.Equal(t, fmt.Errorf("invalid number of workers specified %d", -2), err)
 }
 
+func TestRunFailsOnInvalidProgressMode(t *testing.T) {
+	err := Run("", "", "", RunOptions{WorkerCount: 1, ProgressMode: "yaml", Layout: layout.Auto, LayoutDefaults: true})
+	assert.EqualError(t, err, "invalid progress mode 'yaml', expected one of none, text or json")
+}
+
 func TestRunFailsOnNotExistingInputPath(t *testing.T) {
-	err := Run("", "", "", false, "", 1)
+	err := Run("", "", "", RunOptions{WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
 	assert.Equal(t, "input folder does not exist: stat : no such file or directory", err.Error())
 }
 
@@ -31,7 +49,7 @@ func TestFailConfigReading(t *testing.T) {
 		_ = os.RemoveAll(testDir)
 	}()
 
-	err = Run("some.yaml", "", testDir, false, "", 1)
+	err = Run("some.yaml", "", testDir, RunOptions{WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
 	assert.ErrorIs(t, err, os.ErrNotExist)
 }
 
@@ -97,7 +115,7 @@ func TestCreateOmitter(t *testing.T) {
 		},
 	}}
 
-	om, err := createOmittersFromConfig(config, "")
+	om, err := createOmittersFromConfig(config, "", layout.Detect(""))
 	require.NoError(t, err)
 
 	match, err := om.OmitPath("would-match")
@@ -107,18 +125,29 @@ func TestCreateOmitter(t *testing.T) {
 	require.NoError(t, err)
 	assert.Falsef(t, match, "'would-not-match' should match the path omission config")
 
-	match, err = om.OmitKubeResource(&kube.ResourceListWithPath{
-		ResourceList: kube.ResourceList{
-			Items: []kube.Resource{
-				{ApiVersion: sampleApiVersion, Kind: sampleKind, Metadata: kube.Metadata{Namespace: "kube-system"}},
-			},
-		},
-		Path: "some-path",
-	})
+	match, err = om.OmitKubeResourceItem("some-path", kube.Resource{ApiVersion: sampleApiVersion, Kind: sampleKind, Metadata: kube.Metadata{Namespace: "kube-system"}})
 	require.NoError(t, err)
 	assert.Truef(t, match, "k8s resource with the exact same input should match")
 }
 
+func TestCreateOmitterMissingField(t *testing.T) {
+	for _, omitType := range []schema.OmitType{
+		schema.OmitTypeInclude,
+		schema.OmitTypeFile,
+		schema.OmitTypePathRegex,
+		schema.OmitTypeMaxSize,
+		schema.OmitTypeContainsRegex,
+		schema.OmitTypeKubernetes,
+		schema.OmitTypeTimeRange,
+	} {
+		t.Run(string(omitType), func(t *testing.T) {
+			config := &schema.SchemaJson{Config: schema.SchemaJsonConfig{Omit: []schema.Omit{{Type: omitType}}}}
+			_, err := createOmittersFromConfig(config, "", layout.Detect(""))
+			assert.ErrorContains(t, err, fmt.Sprintf("type %s must also include", omitType))
+		})
+	}
+}
+
 func TestRunPipeNoConfig(t *testing.T) {
 	file, err := os.CreateTemp("", "temp-file")
 	require.NoError(t, err)
@@ -142,7 +171,7 @@ func TestRunPipeNoConfig(t *testing.T) {
 		_ = os.RemoveAll(outputFile.Name())
 	}()
 
-	err = RunPipe("", inputFile, outputFile)
+	err = RunPipe("", inputFile, outputFile, "")
 	require.NoError(t, err)
 	require.NoError(t, outputFile.Close())
 
@@ -191,7 +220,7 @@ config:
 		_ = os.RemoveAll(outputFile.Name())
 	}()
 
-	err = RunPipe(cfgFile.Name(), inputFile, outputFile)
+	err = RunPipe(cfgFile.Name(), inputFile, outputFile, "")
 	require.NoError(t, err)
 	require.NoError(t, outputFile.Close())
 
@@ -201,6 +230,89 @@ config:
 	assert.Equal(t, "some IP 192.167.122.2 that should not to be obfuscated\nand some mac x-mac-0000000001-x\n", string(bytes))
 }
 
+func TestRunPipeStateFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	pipe := func(configPath string, input string) (string, error) {
+		var stdout bytes.Buffer
+		err := RunPipe(configPath, strings.NewReader(input), &stdout, stateFile)
+		return stdout.String(), err
+	}
+
+	output, err := pipe("", "10.0.0.1 and eb:a1:2a:b2:09:bf\n")
+	require.NoError(t, err)
+	assert.Equal(t, "x-ipv4-0000000001-x and x-mac-0000000001-x\n", output)
+
+	// a later invocation keeps the replacements and continues the numbering
+	output, err = pipe("", "10.0.0.2 and 10.0.0.1\n")
+	require.NoError(t, err)
+	assert.Equal(t, "x-ipv4-0000000002-x and x-ipv4-0000000001-x\n", output)
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte("config:\n  obfuscate:\n    - type: IP\n      replacementType: Consistent\n"), 0600))
+	_, err = pipe(cfgPath, "10.0.0.1\n")
+	assert.EqualError(t, err, "the state file at "+stateFile+" was written with a different config")
+
+	// concurrent invocations run one after another, every original gets exactly one replacement
+	wg := sync.WaitGroup{}
+	outputs := make([]string, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			output, err := pipe("", fmt.Sprintf("10.0.1.%d\n", i))
+			assert.NoError(t, err)
+			outputs[i] = output
+		}(i)
+	}
+	wg.Wait()
+	output, err = pipe("", "10.0.1.0\n10.0.1.1\n10.0.1.2\n10.0.1.3\n10.0.1.4\n10.0.1.5\n10.0.1.6\n10.0.1.7\n")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(outputs, ""), output)
+	assert.ElementsMatch(t, []string{
+		"x-ipv4-0000000003-x\n", "x-ipv4-0000000004-x\n", "x-ipv4-0000000005-x\n", "x-ipv4-0000000006-x\n",
+		"x-ipv4-0000000007-x\n", "x-ipv4-0000000008-x\n", "x-ipv4-0000000009-x\n", "x-ipv4-0000000010-x\n",
+	}, outputs)
+}
+
+func TestRunPipeStateFileLongRunning(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	stdin, input := io.Pipe()
+	output, stdout := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- RunPipe("", stdin, stdout, stateFile)
+		_ = stdout.Close()
+	}()
+	lines := bufio.NewReader(output)
+	obfuscateLine := func(line string) string {
+		_, err := io.WriteString(input, line)
+		require.NoError(t, err)
+		obfuscated, err := lines.ReadString('\n')
+		require.NoError(t, err)
+		return obfuscated
+	}
+
+	assert.Equal(t, "x-ipv4-0000000001-x\n", obfuscateLine("10.0.0.1\n"))
+	// the replacement was saved before it was written, an interrupted invocation does not lose it
+	require.NoError(t, statefile.Update(stateFile, "", func(states []obfuscator.State) ([]obfuscator.State, error) {
+		require.Len(t, states, 2)
+		require.Len(t, states[0].Replacements, 1)
+		assert.Equal(t, "10.0.0.1", states[0].Replacements[0].Canonical)
+		return nil, nil
+	}))
+
+	// other invocations don't wait for the running one
+	var other bytes.Buffer
+	require.NoError(t, RunPipe("", strings.NewReader("10.0.0.2 10.0.0.1\n"), &other, stateFile))
+	assert.Equal(t, "x-ipv4-0000000002-x x-ipv4-0000000001-x\n", other.String())
+
+	// the running invocation learns the replacements of the other one instead of using the same number for another original
+	assert.Equal(t, "x-ipv4-0000000003-x x-ipv4-0000000002-x\n", obfuscateLine("10.0.0.3 10.0.0.2\n"))
+	assert.Equal(t, "x-ipv4-0000000003-x\n", obfuscateLine("10.0.0.3\n"))
+	require.NoError(t, input.Close())
+	require.NoError(t, <-done)
+}
+
 func TestWaterMarkerNotCreatedOnFail(t *testing.T) {
 	testDir, err := os.MkdirTemp(os.TempDir(), "test-dir-*")
 	require.NoError(t, err)
@@ -208,7 +320,525 @@ func TestWaterMarkerNotCreatedOnFail(t *testing.T) {
 		_ = os.RemoveAll(testDir)
 	}()
 
-	err = Run("some.yaml", "", testDir, false, "", 1)
+	err = Run("some.yaml", "", testDir, RunOptions{WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
 	assert.ErrorIs(t, err, os.ErrNotExist)
 	require.NoFileExists(t, filepath.Join(testDir, "watermark.txt"))
 }
+
+func TestRunValidateConfig(t *testing.T) {
+	cfgFile, err := os.CreateTemp("", "temp-file-*.yaml")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(cfgFile.Name())
+	}()
+
+	_, err = cfgFile.WriteString(`config:
+  obfuscate:
+    - type: Regex
+  omit:
+    - type: Kubernetes
+`)
+	require.NoError(t, err)
+	require.NoError(t, cfgFile.Close())
+
+	output := &strings.Builder{}
+	err = RunValidateConfig(cfgFile.Name(), output)
+	require.EqualError(t, err, fmt.Sprintf("config at %s is invalid", cfgFile.Name()))
+	assert.Equal(t, fmt.Sprintf(`%[1]s:3:7: error: config.obfuscate[0].regex: type Regex requires the 'regex' property
+%[1]s:5:7: error: config.omit[0].kubernetesResource: type Kubernetes requires the 'kubernetesResource' property
+`, cfgFile.Name()), output.String())
+}
+
+func TestRunValidateConfigExample(t *testing.T) {
+	output := &strings.Builder{}
+	err := RunValidateConfig("../../examples/openshift_default.yaml", output)
+	require.NoError(t, err)
+	assert.Empty(t, output.String())
+}
+
+func TestRunSuggestConfigIsValid(t *testing.T) {
+	cfgFile, err := os.CreateTemp("", "suggested-*.yaml")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(cfgFile.Name())
+	}()
+
+	err = RunSuggestConfig("../suggester/testfiles/mg", cfgFile)
+	require.NoError(t, err)
+	require.NoError(t, cfgFile.Close())
+
+	config, err := schema.ReadConfigFromPath(cfgFile.Name())
+	require.NoError(t, err)
+	assert.Len(t, config.Config.Obfuscate, 4)
+
+	output := &strings.Builder{}
+	require.NoError(t, RunValidateConfig(cfgFile.Name(), output))
+	assert.Empty(t, output.String())
+}
+
+func TestRunSuggestConfigNotExistingInput(t *testing.T) {
+	err := RunSuggestConfig("not-existing", &strings.Builder{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRunClusterIdentity(t *testing.T) {
+	inputDir, err := os.MkdirTemp("", "input-*")
+	require.NoError(t, err)
+	outputDir, err := os.MkdirTemp("", "output-*")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(inputDir)
+		_ = os.RemoveAll(outputDir)
+	}()
+
+	cfgPath := filepath.Join(inputDir, "..", filepath.Base(inputDir)+"-config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  obfuscate:
+    - type: ClusterIdentity
+      replacementType: Consistent
+      target: All
+`), 0600))
+	defer func() {
+		_ = os.RemoveAll(cfgPath)
+	}()
+
+	// the log file is traversed before the node, the node name must still be replaced in it
+	require.NoError(t, os.MkdirAll(filepath.Join(inputDir, "a-logs"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a-logs", "worker-abcde-1.log"), []byte("kubelet on worker-abcde-1 started\n"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(inputDir, "nodes"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "nodes", "worker-abcde-1.yaml"), []byte(`apiVersion: v1
+kind: Node
+metadata:
+  name: worker-abcde-1
+`), 0600))
+
+	err = Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: outputDir, DeleteOutputFolder: true, WorkerCount: 2, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
+	require.NoError(t, err)
+
+	bytes, err := os.ReadFile(filepath.Join(outputDir, "a-logs", "x-node-0000000001-x.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "kubelet on x-node-0000000001-x started\n", string(bytes))
+	assert.FileExists(t, filepath.Join(outputDir, "nodes", "x-node-0000000001-x.yaml"))
+}
+
+func TestRunDeterministic(t *testing.T) {
+	inputDir := t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  omit:
+    - type: File
+      pattern: "*.omit"
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+`), 0600))
+
+	// every file has its own IP, the omitted file is visited first but must not take a number
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "0.omit"), []byte("10.0.0.1\n"), 0600))
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(inputDir, fmt.Sprintf("dir-%02d", i%5))
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d.log", i)), []byte(fmt.Sprintf("node 10.0.1.%d and 10.0.2.1\n", i)), 0600))
+	}
+
+	run := func(workerCount int) (map[string]string, string) {
+		outputDir := t.TempDir()
+		reportDir := t.TempDir()
+		require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, DeleteOutputFolder: true, WorkerCount: workerCount, Deterministic: true, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+		return readOutput(t, outputDir, reportDir)
+	}
+
+	files, report := run(1)
+	assert.Equal(t, "node x-ipv4-0000000001-x and x-ipv4-0000000002-x\n", files[filepath.Join("/dir-00", "00.log")])
+	assert.Equal(t, "node x-ipv4-0000000003-x and x-ipv4-0000000002-x\n", files[filepath.Join("/dir-00", "05.log")])
+	assert.Contains(t, report, "- original: 10.0.2.1\n              count: 50\n")
+	for _, workerCount := range []int{4, 8} {
+		parallelFiles, parallelReport := run(workerCount)
+		assert.Equal(t, files, parallelFiles)
+		assert.Equal(t, report, parallelReport)
+	}
+}
+
+func TestRunDeterministicOmissionStubs(t *testing.T) {
+	inputDir := t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  omit:
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+`), 0600))
+
+	// the names of the omitted secrets only appear in their stubs, they are numbered in the order of the files as well
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(inputDir, fmt.Sprintf("dir-%02d", i%5))
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d.log", i)), []byte(fmt.Sprintf("node 10.0.1.%d\n", i)), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d-secret.yaml", i)), []byte(fmt.Sprintf(`apiVersion: v1
+kind: Secret
+metadata:
+  name: node-10.0.3.%d
+  namespace: default
+`, i)), 0600))
+	}
+
+	run := func(workerCount int) (map[string]string, string) {
+		outputDir := t.TempDir()
+		reportDir := t.TempDir()
+		require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, DeleteOutputFolder: true, WorkerCount: workerCount, Deterministic: true, OmissionStubs: true, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+		return readOutput(t, outputDir, reportDir)
+	}
+
+	files, report := run(1)
+	assert.Contains(t, files[filepath.Join("/dir-00", "00-secret.yaml")], "name: node-x-ipv4-0000000001-x\n")
+	assert.Equal(t, "node x-ipv4-0000000002-x\n", files[filepath.Join("/dir-00", "00.log")])
+	for _, workerCount := range []int{4, 8} {
+		parallelFiles, parallelReport := run(workerCount)
+		assert.Equal(t, files, parallelFiles)
+		assert.Equal(t, report, parallelReport)
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	inputDir := t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  omit:
+    - type: File
+      pattern: "*.omit"
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+    - type: Hostname
+      replacementType: Consistent
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a.log"), []byte("worker-abcde-1 at 10.0.0.1\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "b.log"), []byte("worker-abcde-1 at 10.0.0.2 and 10.0.0.1\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "c.omit"), []byte("10.0.0.3\n"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(inputDir, "nodes"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "nodes", "worker-abcde-1.yaml"), []byte(`apiVersion: v1
+kind: Node
+metadata:
+  name: worker-abcde-1
+`), 0600))
+
+	fullOutputDir, fullReportDir := t.TempDir(), t.TempDir()
+	require.NoError(t, Run(cfgPath, inputDir, fullOutputDir, RunOptions{ReportingFolder: fullReportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+	files, report := readOutput(t, fullOutputDir, fullReportDir)
+	assert.Equal(t, "x-host-0000000001-x at x-ipv4-0000000001-x\n", files["/a.log"])
+	assert.NoFileExists(t, filepath.Join(fullReportDir, checkpoint.FileName))
+
+	// the run is interrupted after a.log and c.omit were completed, while b.log was partially written
+	outputDir, reportDir := t.TempDir(), t.TempDir()
+	config, err := schema.ReadConfigFromPath(cfgPath)
+	require.NoError(t, err)
+	inputLayout := layout.Detect(inputDir)
+	require.NoError(t, layout.AddDefaultOmissions(config, inputLayout))
+	multiObfuscator, prescanObfuscator, err := createObfuscatorsFromConfig(config)
+	require.NoError(t, err)
+	progressReporter, err := progress.NewReporter(progress.ModeNone, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, prescanAndAssign(config, inputDir, inputLayout, multiObfuscator, prescanObfuscator, 1, false, false, progressReporter))
+	mro, err := createOmittersFromConfig(config, inputDir, inputLayout)
+	require.NoError(t, err)
+	cp, err := checkpoint.New(cfgPath, false, inputLayout.Name(), true)
+	require.NoError(t, err)
+	store := checkpoint.NewStore(filepath.Join(reportDir, checkpoint.FileName), cp, multiObfuscator, mro)
+	processor := store.Processor(cleaner.NewFileCleaner(inputDir, outputDir, multiObfuscator, mro, inputLayout, false, nil, store))
+	require.NoError(t, processor.Process("a.log"))
+	require.NoError(t, processor.Process("c.omit"))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "b.log"), []byte("x-host-0000000001-x at"), 0600))
+	require.NoError(t, store.Save())
+
+	err = Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, DeleteOutputFolder: true, WorkerCount: 2, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Resume: true})
+	assert.EqualError(t, err, "an interrupted run can't be resumed when the output folder is deleted")
+	err = Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 2, Deterministic: true, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Resume: true})
+	assert.EqualError(t, err, "failed to resume: the checkpoint was written with deterministic=false, but the run was resumed with deterministic=true")
+	err = Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 2, ProgressMode: progress.ModeNone, Layout: "sosreport", LayoutDefaults: true, Resume: true})
+	assert.EqualError(t, err, "failed to resume: the checkpoint was written for the layout must-gather with defaults=true, but the run was resumed for the layout sosreport with defaults=true")
+
+	require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 2, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Resume: true}))
+	resumedFiles, resumedReport := readOutput(t, outputDir, reportDir)
+	delete(files, "/watermark.txt")
+	delete(resumedFiles, "/watermark.txt")
+	assert.Equal(t, files, resumedFiles)
+	assert.Equal(t, report, resumedReport)
+	assert.NoFileExists(t, filepath.Join(reportDir, checkpoint.FileName))
+}
+
+// blockingProcessor waits for release after it processed the given file, until then the file is not completed.
+type blockingProcessor struct {
+	cleaner.Processor
+	file     string
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (b *blockingProcessor) Process(inputFile string) error {
+	err := b.Processor.Process(inputFile)
+	if inputFile == b.file {
+		close(b.started)
+		<-b.released
+	}
+	return err
+}
+
+func TestRunResumeCounts(t *testing.T) {
+	inputDir := t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a.log"), []byte("10.0.0.1\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "b.log"), []byte("10.0.0.1 and 10.0.0.2\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "c.log"), []byte("10.0.0.2\n"), 0600))
+
+	fullOutputDir, fullReportDir := t.TempDir(), t.TempDir()
+	require.NoError(t, Run(cfgPath, inputDir, fullOutputDir, RunOptions{ReportingFolder: fullReportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+	_, report := readOutput(t, fullOutputDir, fullReportDir)
+	assert.Contains(t, report, "- original: 10.0.0.1\n              count: 2\n")
+
+	// the checkpoint is saved while b.log is cleaned, then the run is interrupted before c.log
+	outputDir, reportDir := t.TempDir(), t.TempDir()
+	config, err := schema.ReadConfigFromPath(cfgPath)
+	require.NoError(t, err)
+	inputLayout := layout.Detect(inputDir)
+	require.NoError(t, layout.AddDefaultOmissions(config, inputLayout))
+	multiObfuscator, prescanObfuscator, err := createObfuscatorsFromConfig(config)
+	require.NoError(t, err)
+	progressReporter, err := progress.NewReporter(progress.ModeNone, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, prescanAndAssign(config, inputDir, inputLayout, multiObfuscator, prescanObfuscator, 1, false, false, progressReporter))
+	mro, err := createOmittersFromConfig(config, inputDir, inputLayout)
+	require.NoError(t, err)
+	cp, err := checkpoint.New(cfgPath, false, inputLayout.Name(), true)
+	require.NoError(t, err)
+	store := checkpoint.NewStore(filepath.Join(reportDir, checkpoint.FileName), cp, multiObfuscator, mro)
+	blocking := &blockingProcessor{
+		Processor: cleaner.NewFileCleaner(inputDir, outputDir, multiObfuscator, mro, inputLayout, false, nil, store),
+		file:      "b.log",
+		started:   make(chan struct{}),
+		released:  make(chan struct{}),
+	}
+	processor := store.Processor(blocking)
+	require.NoError(t, processor.Process("a.log"))
+	processed := make(chan error)
+	go func() {
+		processed <- processor.Process("b.log")
+	}()
+	<-blocking.started
+	saved := make(chan error)
+	go func() {
+		saved <- store.Save()
+	}()
+	// give the save a chance to take its snapshot while b.log is not completed
+	time.Sleep(50 * time.Millisecond)
+	close(blocking.released)
+	require.NoError(t, <-processed)
+	require.NoError(t, <-saved)
+
+	require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 2, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Resume: true}))
+	_, resumedReport := readOutput(t, outputDir, reportDir)
+	assert.Equal(t, report, resumedReport)
+}
+
+func TestRunResumeFailsWithoutCheckpoint(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte("config: {}\n"), 0600))
+	err := Run(cfgPath, t.TempDir(), t.TempDir(), RunOptions{ReportingFolder: t.TempDir(), WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Resume: true})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// readOutput returns the contents of all output files by their path and the report.
+func readOutput(t *testing.T, outputDir string, reportDir string) (map[string]string, string) {
+	files := map[string]string{}
+	require.NoError(t, filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		bytes, err := os.ReadFile(path)
+		files[strings.TrimPrefix(path, outputDir)] = string(bytes)
+		return err
+	}))
+	report, err := os.ReadFile(filepath.Join(reportDir, reportFileName))
+	require.NoError(t, err)
+	return files, string(report)
+}
+
+func TestRunPrevious(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  omit:
+    - type: File
+      pattern: "*.omit"
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+    - type: Domain
+      domainNames: [example.com]
+      replacementType: Consistent
+`), 0600))
+	previousInputDir, previousOutputDir, previousReportDir := t.TempDir(), t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(previousInputDir, "a.log"), []byte("10.0.0.1 on api.example.com\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(previousInputDir, "b.log"), []byte("10.0.0.2\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(previousInputDir, "c.omit"), []byte("10.0.0.3\n"), 0600))
+	require.NoError(t, Run(cfgPath, previousInputDir, previousOutputDir, RunOptions{ReportingFolder: previousReportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+
+	// b.log changed and d.log is new, both are cleaned with the replacements of the previous run
+	inputDir, outputDir, reportDir := t.TempDir(), t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a.log"), []byte("10.0.0.1 on api.example.com\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "b.log"), []byte("10.0.0.2 and 10.0.0.4 on www.example.com\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "c.omit"), []byte("10.0.0.3\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "d.log"), []byte("10.0.0.1 and 10.0.0.5\n"), 0600))
+	previous := Previous{InputPath: previousInputDir, OutputPath: previousOutputDir, ReportPath: filepath.Join(previousReportDir, reportFileName)}
+	require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Previous: previous}))
+
+	files, report := readOutput(t, outputDir, reportDir)
+	assert.Equal(t, "x-ipv4-0000000001-x on api.domain0000000001\n", files["/a.log"])
+	previousStat, err := os.Stat(filepath.Join(previousOutputDir, "a.log"))
+	require.NoError(t, err)
+	stat, err := os.Stat(filepath.Join(outputDir, "a.log"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(previousStat, stat), "the output of the unchanged file is reused")
+	assert.Equal(t, "x-ipv4-0000000002-x and x-ipv4-0000000003-x on www.domain0000000001\n", files["/b.log"])
+	assert.Equal(t, "x-ipv4-0000000001-x and x-ipv4-0000000004-x\n", files["/d.log"])
+	assert.NotContains(t, files, "/c.omit")
+	assert.Contains(t, report, "c.omit")
+}
+
+func TestRunPreviousFailsOnChangedConfig(t *testing.T) {
+	inputDir, reportDir := t.TempDir(), t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte("config:\n  obfuscate:\n    - type: IP\n      replacementType: Consistent\n"), 0600))
+	require.NoError(t, Run(cfgPath, inputDir, t.TempDir(), RunOptions{ReportingFolder: reportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+
+	reportPath := filepath.Join(reportDir, reportFileName)
+	require.NoError(t, os.WriteFile(cfgPath, []byte("config:\n  obfuscate:\n    - type: IP\n      replacementType: Static\n"), 0600))
+	err := Run(cfgPath, inputDir, t.TempDir(), RunOptions{ReportingFolder: t.TempDir(), WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Previous: Previous{InputPath: inputDir, OutputPath: t.TempDir(), ReportPath: reportPath}})
+	assert.EqualError(t, err, "the previous report at "+reportPath+" can't be reused: the configuration differs")
+
+	err = Run(cfgPath, inputDir, t.TempDir(), RunOptions{ReportingFolder: t.TempDir(), WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true, Previous: Previous{InputPath: inputDir}})
+	assert.EqualError(t, err, "the input, output and report of the previous run must all be given")
+}
+
+func TestRunPipeTar(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  omit:
+    - type: File
+      pattern: "*.omit"
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+`), 0600))
+
+	input := map[string]string{
+		"a.log":                           "10.0.0.1 and 10.0.0.2\n",
+		"b.omit":                          "10.0.0.3\n",
+		"namespaces/default/secrets.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: token\n  namespace: default\n",
+	}
+	var stdin bytes.Buffer
+	writer := tar.NewWriter(&stdin)
+	for _, name := range []string{"a.log", "b.omit", "namespaces/default/secrets.yaml"} {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(input[name])), Typeflag: tar.TypeReg}))
+		_, err := writer.Write([]byte(input[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	readTar := func(stdout *bytes.Buffer) map[string]string {
+		files := map[string]string{}
+		reader := tar.NewReader(stdout)
+		for header, err := reader.Next(); err == nil; header, err = reader.Next() {
+			assert.True(t, header.ModTime.Equal(time.Unix(0, 0)), "%s has the mod time %v", header.Name, header.ModTime)
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			contents, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			files[header.Name] = string(contents)
+		}
+		return files
+	}
+
+	reportDir := t.TempDir()
+	var stdout bytes.Buffer
+	require.NoError(t, RunPipeTar(cfgPath, bytes.NewReader(stdin.Bytes()), &stdout, RunOptions{ReportingFolder: reportDir, WorkerCount: 1, Deterministic: true, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+	files := readTar(&stdout)
+	assert.Equal(t, "x-ipv4-0000000001-x and x-ipv4-0000000002-x\n", files["a.log"])
+	assert.NotContains(t, files, "b.omit")
+	assert.NotContains(t, files, "namespaces/default/secrets.yaml")
+	assert.Contains(t, files, "watermark.txt")
+	report, err := os.ReadFile(filepath.Join(reportDir, reportFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "b.omit")
+	assert.Contains(t, string(report), "10.0.0.1")
+
+	// without a reporting folder, the output is the same and no report is kept
+	stdout.Reset()
+	require.NoError(t, RunPipeTar(cfgPath, bytes.NewReader(stdin.Bytes()), &stdout, RunOptions{WorkerCount: 1, Deterministic: true, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+	assert.Equal(t, files, readTar(&stdout))
+}
+
+func TestRunPipeTarNoConfig(t *testing.T) {
+	err := RunPipeTar("", strings.NewReader(""), io.Discard, RunOptions{WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true})
+	assert.EqualError(t, err, "a config is required to clean a tar stream")
+
+	err = RunPipeTar("some.yaml", strings.NewReader(""), io.Discard, RunOptions{WorkerCount: 1, ProgressMode: progress.ModeNone, Resume: true})
+	assert.EqualError(t, err, "a tar stream can't be resumed or cleaned against a previous run")
+	err = RunPipeTar("some.yaml", strings.NewReader(""), io.Discard, RunOptions{WorkerCount: 1, ProgressMode: progress.ModeNone, Previous: Previous{InputPath: "previous"}})
+	assert.EqualError(t, err, "a tar stream can't be resumed or cleaned against a previous run")
+}
+
+func TestRunLayout(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`config:
+  omit:
+    - type: TimeRange
+      timeRange:
+        since: "2023-04-12T10:00:00Z"
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+`), 0600))
+	inputDir := t.TempDir()
+	for path, contents := range map[string]string{
+		"etc/shadow":                              "root:$6$hash:19000:0:99999:7:::\n",
+		"etc/hosts":                               "10.0.0.1 master-0\n",
+		"etc/containers/registries.json":          "{\"mirror\": \"10.0.0.2\"\n",
+		"var/log/journal/0123/system.journal":     "binary",
+		"sos_commands/logs/journalctl_--no-pager": "2023-04-12T09:00:00Z 10.0.0.3 before\n2023-04-12T11:00:00Z 10.0.0.1 after\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(inputDir, path)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(inputDir, path), []byte(contents), 0600))
+	}
+
+	outputDir, reportDir := t.TempDir(), t.TempDir()
+	require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: layout.Auto, LayoutDefaults: true}))
+	files, report := readOutput(t, outputDir, reportDir)
+	assert.NotContains(t, files, "/etc/shadow")
+	assert.NotContains(t, files, "/var/log/journal/0123/system.journal")
+	// the configuration files of the host are no kubernetes resources, they are cleaned as text
+	assert.Equal(t, "{\"mirror\": \"x-ipv4-0000000001-x\"\n", files["/etc/containers/registries.json"])
+	assert.Equal(t, "x-ipv4-0000000002-x master-0\n", files["/etc/hosts"])
+	// the journal is a log of the sosreport layout
+	assert.Equal(t, "2023-04-12T11:00:00Z x-ipv4-0000000002-x after\n", files["/sos_commands/logs/journalctl_--no-pager"])
+	assert.Contains(t, report, "pattern: etc/shadow*")
+
+	outputDir, reportDir = t.TempDir(), t.TempDir()
+	require.NoError(t, Run(cfgPath, inputDir, outputDir, RunOptions{ReportingFolder: reportDir, WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: "sosreport"}))
+	files, report = readOutput(t, outputDir, reportDir)
+	assert.Contains(t, files, "/etc/shadow")
+	assert.NotContains(t, report, "etc/shadow")
+
+	err := Run(cfgPath, inputDir, t.TempDir(), RunOptions{ReportingFolder: t.TempDir(), WorkerCount: 1, ProgressMode: progress.ModeNone, Layout: "supportconfig", LayoutDefaults: true})
+	assert.EqualError(t, err, "unknown layout 'supportconfig', expected one of sosreport, cluster-info-dump, inspect, must-gather or auto")
+}
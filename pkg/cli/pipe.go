@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/cleaner"
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/statefile"
+)
+
+// pipeBatchSize is the number of bytes after which the lines of the pipe are obfuscated, even if more input is available right away.
+const pipeBatchSize = 256 * 1024
+
+// pipeObfuscator obfuscates the batches of lines of the pipe mode. With a state file, the new replacements of a batch are saved before the
+// batch is written, so that an interrupted invocation never wrote a replacement that the state file does not know. The state file is only
+// locked while it is read and written, concurrent invocations can run for as long as their input lasts.
+type pipeObfuscator struct {
+	obfuscator *obfuscator.MultiObfuscator
+
+	// the remaining fields are only set with a state file
+	stateFile     string
+	configDigest  string
+	newObfuscator func() (*obfuscator.MultiObfuscator, error)
+	// synced are the states that were last loaded from or saved to the state file, replacements the replacement count of the obfuscator
+	// at that time
+	synced       []obfuscator.State
+	replacements int
+}
+
+// newStatePipeObfuscator creates a pipeObfuscator that continues with the replacements of the state file.
+func newStatePipeObfuscator(stateFile string, configDigest string, newObfuscator func() (*obfuscator.MultiObfuscator, error)) (*pipeObfuscator, error) {
+	p := &pipeObfuscator{stateFile: stateFile, configDigest: configDigest, newObfuscator: newObfuscator}
+	err := statefile.Update(stateFile, configDigest, func(states []obfuscator.State) ([]obfuscator.State, error) {
+		return nil, p.restore(states)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// restore replaces the obfuscator by one with the given states.
+func (p *pipeObfuscator) restore(states []obfuscator.State) error {
+	o, err := p.newObfuscator()
+	if err != nil {
+		return err
+	}
+	if states != nil {
+		err = o.Restore(states)
+		if err != nil {
+			return fmt.Errorf("failed to restore the obfuscators from the state file: %w", err)
+		}
+	}
+	p.obfuscator, p.synced, p.replacements = o, states, o.ReplacementCount()
+	return nil
+}
+
+// obfuscate returns the obfuscated batch, its new replacements are saved to the state file before it returns.
+func (p *pipeObfuscator) obfuscate(batch string) (string, error) {
+	obfuscated, err := obfuscateText(p.obfuscator, batch)
+	if err != nil || p.stateFile == "" || p.obfuscator.ReplacementCount() == p.replacements {
+		return obfuscated, err
+	}
+
+	var saved []obfuscator.State
+	err = statefile.Update(p.stateFile, p.configDigest, func(states []obfuscator.State) ([]obfuscator.State, error) {
+		if !sameCounters(states, p.synced) {
+			// another invocation generated replacements since the last sync, the new replacements of the batch may use the same numbers.
+			// The batch was not written yet, it is obfuscated again with the replacements of the other invocation.
+			err := p.restore(states)
+			if err != nil {
+				return nil, err
+			}
+			obfuscated, err = obfuscateText(p.obfuscator, batch)
+			if err != nil {
+				return nil, err
+			}
+			saved = p.obfuscator.State()
+		} else {
+			saved = mergeStates(states, p.obfuscator.State())
+		}
+		return saved, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	p.synced, p.replacements = saved, p.obfuscator.ReplacementCount()
+	return obfuscated, nil
+}
+
+func obfuscateText(o obfuscator.Obfuscator, text string) (string, error) {
+	var obfuscated strings.Builder
+	contentObfuscator := cleaner.ContentObfuscator{Obfuscator: o}
+	err := contentObfuscator.ObfuscateReader(strings.NewReader(text), &obfuscated)
+	return obfuscated.String(), err
+}
+
+// sameCounters returns whether the obfuscators of both states generated the same numbers, a missing counter is the same as zero.
+func sameCounters(a []obfuscator.State, b []obfuscator.State) bool {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var countersA, countersB map[string]int64
+		if i < len(a) {
+			countersA = a[i].Counters
+		}
+		if i < len(b) {
+			countersB = b[i].Counters
+		}
+		for template, count := range countersA {
+			if countersB[template] != count {
+				return false
+			}
+		}
+		for template, count := range countersB {
+			if countersA[template] != count {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mergeStates adds the replacements of the saved states that the obfuscators do not know to their states. The saved states must not have
+// generated numbers that the obfuscators do not know, the replacements they add are those without a number, like static replacements.
+func mergeStates(saved []obfuscator.State, states []obfuscator.State) []obfuscator.State {
+	for i := range states {
+		if i >= len(saved) {
+			break
+		}
+		known := map[string]bool{}
+		for _, r := range states[i].Replacements {
+			known[r.Canonical] = true
+		}
+		for _, r := range saved[i].Replacements {
+			if !known[r.Canonical] {
+				states[i].Replacements = append(states[i].Replacements, r)
+			}
+		}
+	}
+	return states
+}
+
+// readBatch returns the lines that can be read without waiting for more input, up to pipeBatchSize bytes. It waits for at least one line
+// unless the input ends, the error is io.EOF after the last line.
+func readBatch(reader *bufio.Reader) (string, error) {
+	var batch strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		batch.WriteString(line)
+		if err != nil || reader.Buffered() == 0 || batch.Len() >= pipeBatchSize {
+			return batch.String(), err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameCounters(t *testing.T) {
+	ip := func(count int64) obfuscator.State {
+		return obfuscator.State{Counters: map[string]int64{"x-ipv4-%010d-x": count}}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		a        []obfuscator.State
+		b        []obfuscator.State
+		expected bool
+	}{
+		{name: "no states", expected: true},
+		{name: "same", a: []obfuscator.State{ip(1), {}}, b: []obfuscator.State{ip(1), {}}, expected: true},
+		{name: "zero is missing", a: []obfuscator.State{ip(0)}, b: []obfuscator.State{{}}, expected: true},
+		{name: "missing states are zero", a: []obfuscator.State{ip(0)}, expected: true},
+		{name: "different", a: []obfuscator.State{ip(1)}, b: []obfuscator.State{ip(2)}, expected: false},
+		{name: "new state", b: []obfuscator.State{ip(1)}, expected: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sameCounters(tc.a, tc.b))
+			assert.Equal(t, tc.expected, sameCounters(tc.b, tc.a))
+		})
+	}
+}
+
+func TestMergeStates(t *testing.T) {
+	replacement := func(canonical string, replacedWith string) obfuscator.Replacement {
+		return obfuscator.Replacement{Canonical: canonical, ReplacedWith: replacedWith, Counter: map[string]uint{canonical: 1}}
+	}
+	saved := []obfuscator.State{{Replacements: []obfuscator.Replacement{replacement("10.0.0.1", "xxx"), replacement("10.0.0.2", "xxx")}}}
+	states := []obfuscator.State{{Replacements: []obfuscator.Replacement{replacement("10.0.0.1", "xxx"), replacement("10.0.0.3", "xxx")}}, {}}
+
+	merged := mergeStates(saved, states)
+	assert.Equal(t, []obfuscator.State{{Replacements: []obfuscator.Replacement{
+		replacement("10.0.0.1", "xxx"), replacement("10.0.0.3", "xxx"), replacement("10.0.0.2", "xxx"),
+	}}, {}}, merged)
+}
@@ -0,0 +1,536 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/cleaner"
+	"github.com/openshift/must-gather-clean/pkg/fsutil"
+	"github.com/openshift/must-gather-clean/pkg/layout"
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"github.com/openshift/must-gather-clean/pkg/progress"
+	"github.com/openshift/must-gather-clean/pkg/reporting"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/openshift/must-gather-clean/pkg/traversal"
+	watermarking "github.com/openshift/must-gather-clean/pkg/watermarker"
+	"k8s.io/klog/v2"
+)
+
+const sessionsPath = "/v1/sessions/"
+
+var (
+	// sessionName is the format of the ids of the sessions and the names of the uploads, they must be safe to use in paths
+	sessionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+	gzipMagic   = []byte{0x1f, 0x8b}
+
+	errExtractedSizeExceeded = errors.New("the extracted upload exceeds the maximum size")
+)
+
+// ServeOptions configures the limits of the server.
+type ServeOptions struct {
+	// WorkerCount is the number of workers that clean an upload, and that obfuscate the chunks of a text stream.
+	WorkerCount int
+	// MaxRequestSize is the maximum size of a request body in bytes, for a compressed upload this is the compressed size.
+	MaxRequestSize int64
+	// MaxExtractedSize is the maximum size of an upload in bytes after its decompression.
+	MaxExtractedSize int64
+	// RequestTimeout is the time to read a request and to write its response, the cleaning of an upload must complete within it.
+	RequestTimeout time.Duration
+	// LayoutDefaults adds the default omissions of the layout of each upload to the configuration of its session.
+	LayoutDefaults bool
+	OmissionStubs  bool
+	// SessionTTL is the time after the last request of a session after which it is forgotten, sessions never expire when it is zero.
+	SessionTTL time.Duration
+	// MaxSessions is the maximum number of sessions, a request that would create another one is answered with 429. The number is not
+	// limited when it is zero.
+	MaxSessions int
+	// Token is the bearer token that every request must send in its Authorization header. Without a token, the server must only be
+	// reachable through a proxy that authenticates the clients, as anyone who knows the id of a session can read its report.
+	Token string
+}
+
+// Server cleans the uploads of clients over HTTP. The uploads are grouped in sessions, for example one per support case, the same original
+// gets the same replacement in all uploads of a session and the report of a session covers all of them:
+//
+//	POST   /v1/sessions/{id}/obfuscate  obfuscates the text of the request body and streams it back
+//	POST   /v1/sessions/{id}/clean      cleans the must-gather in the tar or tar.gz of the request body and returns it in the same format
+//	GET    /v1/sessions/{id}/report     returns the report of all uploads of the session
+//	DELETE /v1/sessions/{id}            forgets the session and its replacements
+//
+// A session is created by its first upload and expires after the SessionTTL of the options.
+type Server struct {
+	config  *schema.SchemaJson
+	options ServeOptions
+	now     func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session holds the obfuscators that are shared by all uploads of a session. The uploads of a session are cleaned one after another, as
+// the prescan of each upload adds to the replacements of the session.
+type session struct {
+	obfuscator        *obfuscator.MultiObfuscator
+	prescanObfuscator *obfuscator.MultiObfuscator
+
+	// lastUsed is the end of the last request and active the number of requests in progress, both are guarded by the mutex of the server
+	lastUsed time.Time
+	active   int
+
+	mu sync.Mutex
+	// config is a copy of the configuration of the server with the default omissions of the layouts of the uploads
+	config    *schema.SchemaJson
+	omissions []omitter.Omission
+	uploads   int
+}
+
+// NewServer creates a server for the configuration at configPath. The obfuscators and omitters of the configuration are created once to
+// report an invalid configuration before the first upload.
+func NewServer(configPath string, options ServeOptions) (*Server, error) {
+	if configPath == "" {
+		return nil, fmt.Errorf("a config is required to serve")
+	}
+	if options.WorkerCount < 1 {
+		return nil, fmt.Errorf("invalid number of workers specified %d", options.WorkerCount)
+	}
+	config, err := schema.ReadConfigFromPath(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config at %s: %w", configPath, err)
+	}
+	_, _, err = createObfuscatorsFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create obfuscators via config at %s: %w", configPath, err)
+	}
+	_, err = createOmittersFromConfig(config, "", layout.Detect(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create omitters via config at %s: %w", configPath, err)
+	}
+	return &Server{config: config, options: options, now: time.Now, sessions: map[string]*session{}}, nil
+}
+
+// RunServe serves the API of the Server on address until it receives SIGINT or SIGTERM, the requests in progress are completed before it
+// returns.
+func RunServe(configPath string, address string, options ServeOptions) error {
+	server, err := NewServer(configPath, options)
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       options.RequestTimeout,
+		WriteTimeout:      options.RequestTimeout,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+	klog.Infof("serving on %s", address)
+	if options.Token == "" {
+		klog.Warningf("serving without a token, the API must only be reachable through a proxy that authenticates the clients")
+	}
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	klog.Infof("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), options.RequestTimeout)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, sessionsPath) {
+		http.NotFound(w, r)
+		return
+	}
+	id, operation, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, sessionsPath), "/")
+	if !sessionName.MatchString(id) {
+		http.Error(w, fmt.Sprintf("invalid session id '%s'", id), http.StatusBadRequest)
+		return
+	}
+
+	switch operation {
+	case "":
+		if allowMethod(w, r, http.MethodDelete) {
+			s.deleteSession(w, id)
+		}
+	case "obfuscate":
+		if allowMethod(w, r, http.MethodPost) {
+			if sess := s.acquire(w, id, true); sess != nil {
+				defer s.release(sess)
+				s.obfuscate(w, r, sess)
+			}
+		}
+	case "clean":
+		if allowMethod(w, r, http.MethodPost) {
+			if sess := s.acquire(w, id, true); sess != nil {
+				defer s.release(sess)
+				s.clean(w, r, sess)
+			}
+		}
+	case "report":
+		if allowMethod(w, r, http.MethodGet) {
+			if sess := s.acquire(w, id, false); sess != nil {
+				defer s.release(sess)
+				s.report(w, sess)
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// allowMethod responds with 405 and returns false if the request does not use the method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	return false
+}
+
+// authorized returns whether the request carries the token of the options, if there is one.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.options.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) == 1
+}
+
+// acquire returns the session with the id and keeps it from expiring until it is released. With create, a missing session is created
+// unless the maximum number of sessions is reached. It responds with 404 or 429 and returns nil when there is no session.
+func (s *Server) acquire(w http.ResponseWriter, id string, create bool) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireSessions()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		if !create {
+			http.Error(w, fmt.Sprintf("session '%s' not found", id), http.StatusNotFound)
+			return nil
+		}
+		if s.options.MaxSessions > 0 && len(s.sessions) >= s.options.MaxSessions {
+			http.Error(w, fmt.Sprintf("the maximum of %d sessions is reached", s.options.MaxSessions), http.StatusTooManyRequests)
+			return nil
+		}
+		config := copyConfig(s.config)
+		// the configuration was checked by NewServer
+		multiObfuscator, prescanObfuscator, _ := createObfuscatorsFromConfig(config)
+		sess = &session{obfuscator: multiObfuscator, prescanObfuscator: prescanObfuscator, config: config}
+		s.sessions[id] = sess
+	}
+	sess.active++
+	return sess
+}
+
+// release ends a request of the session, the time to live of the session starts again.
+func (s *Server) release(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.active--
+	sess.lastUsed = s.now()
+}
+
+// expireSessions forgets the sessions without requests in progress whose last request ended more than the SessionTTL ago. It must be
+// called with the mutex of the server held.
+func (s *Server) expireSessions() {
+	if s.options.SessionTTL <= 0 {
+		return
+	}
+	for id, sess := range s.sessions {
+		if sess.active == 0 && s.now().Sub(sess.lastUsed) > s.options.SessionTTL {
+			klog.V(1).Infof("session '%s' expired", id)
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	s.expireSessions()
+	_, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("session '%s' not found", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// obfuscate streams the obfuscated lines of the request body back while the body is still read.
+func (s *Server) obfuscate(w http.ResponseWriter, r *http.Request, sess *session) {
+	// without full duplex, the server would close the request body with the first write of the response
+	_ = http.NewResponseController(w).EnableFullDuplex()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	out := &countingWriter{w: w}
+	contentObfuscator := cleaner.ContentObfuscator{Obfuscator: sess.obfuscator, Chunks: cleaner.NewChunkPool(s.options.WorkerCount)}
+	err := contentObfuscator.ObfuscateReader(http.MaxBytesReader(w, r.Body, s.options.MaxRequestSize), out)
+	if err != nil {
+		if out.n > 0 {
+			// the status was already sent, the client recognizes the truncated response by the aborted connection
+			klog.Errorf("failed to obfuscate the stream after %d bytes: %v", out.n, err)
+			panic(http.ErrAbortHandler)
+		}
+		httpError(w, fmt.Errorf("failed to obfuscate: %w", err))
+	}
+}
+
+// clean cleans the must-gather of the uploaded tar stream. The optional query parameter name identifies the upload in the report of the
+// session, the omitted files are reported with the name in front of their path. The layout parameter selects the layout like the
+// command line flag.
+func (s *Server) clean(w http.ResponseWriter, r *http.Request, sess *session) {
+	tmpFolder, err := os.MkdirTemp("", "must-gather-clean-serve-*")
+	if err != nil {
+		httpError(w, fmt.Errorf("failed to create temporary folder: %w", err))
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpFolder)
+	}()
+
+	inputPath, outputPath := filepath.Join(tmpFolder, "input"), filepath.Join(tmpFolder, "output")
+	compressed, err := extractUpload(http.MaxBytesReader(w, r.Body, s.options.MaxRequestSize), inputPath, s.options.MaxExtractedSize)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	err = s.cleanUpload(sess, r.URL.Query().Get("name"), r.URL.Query().Get("layout"), inputPath, outputPath)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	var out io.Writer = w
+	if compressed {
+		w.Header().Set("Content-Type", "application/gzip")
+		gzipWriter := gzip.NewWriter(w)
+		defer func() {
+			_ = gzipWriter.Close()
+		}()
+		out = gzipWriter
+	} else {
+		w.Header().Set("Content-Type", "application/x-tar")
+	}
+	err = fsutil.WriteTar(outputPath, out, time.Time{})
+	if err != nil {
+		klog.Errorf("failed to write the cleaned tar stream: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// cleanUpload cleans the extracted upload at inputPath into outputPath with the obfuscators of the session.
+func (s *Server) cleanUpload(sess *session, name string, layoutName string, inputPath string, outputPath string) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.uploads++
+	if name == "" {
+		name = fmt.Sprintf("upload-%d", sess.uploads)
+	}
+	if !sessionName.MatchString(name) {
+		return badRequest(fmt.Errorf("invalid upload name '%s'", name))
+	}
+	inputLayout, err := layout.ForInput(layoutName, inputPath)
+	if err != nil {
+		return badRequest(err)
+	}
+	if s.options.LayoutDefaults {
+		// the rules are appended, the indices of the rules in the omissions of earlier uploads stay valid
+		err = layout.AddDefaultOmissions(sess.config, inputLayout)
+		if err != nil {
+			return fmt.Errorf("failed to add the default omissions of the %s layout: %w", inputLayout.Name(), err)
+		}
+	}
+
+	progressReporter, err := progress.NewReporter(progress.ModeNone, io.Discard)
+	if err != nil {
+		return err
+	}
+	err = prescanAndAssign(sess.config, inputPath, inputLayout, sess.obfuscator, sess.prescanObfuscator, s.options.WorkerCount, false, s.options.OmissionStubs, progressReporter)
+	if err != nil {
+		return err
+	}
+	mro, err := createOmittersFromConfig(sess.config, inputPath, inputLayout)
+	if err != nil {
+		return err
+	}
+	fileCleaner := cleaner.NewFileCleaner(inputPath, outputPath, sess.obfuscator, mro, inputLayout, s.options.OmissionStubs, cleaner.NewChunkPool(s.options.WorkerCount), nil)
+	workerFactory := func(id int) traversal.QueueProcessor {
+		return traversal.NewWorker(id, fileCleaner)
+	}
+	err = traverse(traversal.NewParallelFileWalker(inputPath, s.options.WorkerCount, workerFactory), progressReporter, "clean")
+	if err != nil {
+		return fmt.Errorf("failed to clean: %w", err)
+	}
+
+	// the temporary input folder is replaced by the name of the upload
+	for _, o := range mro.Report() {
+		o.Path = filepath.Join(name, o.Path)
+		sess.omissions = append(sess.omissions, o)
+	}
+	return watermarking.NewSimpleWaterMarker().WriteWaterMarkFile(outputPath)
+}
+
+// report writes the report of all uploads of the session as yaml.
+func (s *Server) report(w http.ResponseWriter, sess *session) {
+	sess.mu.Lock()
+	// the reporter adds the replacements to the config, the config of the session must only contain those of the configuration file
+	reporter := reporting.NewSimpleReporter(copyConfig(sess.config))
+	reporter.CollectOmitterReport(sess.omissions)
+	sess.mu.Unlock()
+	reporter.CollectObfuscatorReport(sess.obfuscator.ReportPerObfuscator())
+
+	var report bytes.Buffer
+	err := reporter.WriteReportTo(&report)
+	if err != nil {
+		httpError(w, fmt.Errorf("failed to write report: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(report.Bytes())
+}
+
+// extractUpload extracts the tar stream, which may be compressed with gzip, into the folder and returns whether it was compressed.
+func extractUpload(body io.Reader, folder string, maxExtractedSize int64) (bool, error) {
+	upload := bufio.NewReader(body)
+	magic, _ := upload.Peek(len(gzipMagic))
+	compressed := bytes.Equal(magic, gzipMagic)
+
+	var tarStream io.Reader = upload
+	if compressed {
+		gzipReader, err := gzip.NewReader(upload)
+		if err != nil {
+			return false, badRequest(fmt.Errorf("failed to decompress the upload: %w", err))
+		}
+		tarStream = gzipReader
+	}
+	extracted := bufio.NewReader(&limitedReader{r: tarStream, remaining: maxExtractedSize})
+	if !fsutil.IsTarStream(extracted) {
+		var maxBytesErr *http.MaxBytesError
+		if _, err := extracted.Peek(1); errors.As(err, &maxBytesErr) || errors.Is(err, errExtractedSizeExceeded) {
+			return false, err
+		}
+		return false, badRequest(fmt.Errorf("the upload is not a tar archive"))
+	}
+
+	err := os.Mkdir(folder, 0700)
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary folder: %w", err)
+	}
+	err = fsutil.ExtractTar(extracted, folder)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.Is(err, errExtractedSizeExceeded) {
+			return false, err
+		}
+		return false, badRequest(err)
+	}
+	return compressed, nil
+}
+
+// limitedReader fails with errExtractedSizeExceeded instead of io.EOF like io.LimitedReader, a truncated upload must not be cleaned.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, errExtractedSizeExceeded
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// requestError is an error that is caused by the request, it is not logged by the server.
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(err error) error {
+	return &requestError{err: err}
+}
+
+// httpError responds with the status code of the error.
+func httpError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, errExtractedSizeExceeded):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &reqErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		klog.Errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// copyConfig returns a deep copy of the configuration.
+func copyConfig(config *schema.SchemaJson) *schema.SchemaJson {
+	// the configuration was read from json or yaml, so it can be written and read again
+	data, _ := json.Marshal(config)
+	var c schema.SchemaJson
+	_ = json.Unmarshal(data, &c)
+	return &c
+}
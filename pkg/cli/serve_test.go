@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/reporting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const serveConfig = `config:
+  omit:
+    - type: File
+      pattern: "*.omit"
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+`
+
+func newTestServer(t *testing.T, options ServeOptions) *httptest.Server {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(serveConfig), 0600))
+	server, err := NewServer(cfgPath, options)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+func defaultServeOptions() ServeOptions {
+	return ServeOptions{WorkerCount: 1, MaxRequestSize: 1 << 20, MaxExtractedSize: 1 << 20, RequestTimeout: time.Minute, LayoutDefaults: true}
+}
+
+func tarOf(t *testing.T, files map[string]string) []byte {
+	var stream bytes.Buffer
+	writer := tar.NewWriter(&stream)
+	for name, contents := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := writer.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return stream.Bytes()
+}
+
+func gzipOf(t *testing.T, data []byte) []byte {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return compressed.Bytes()
+}
+
+func readTarFiles(t *testing.T, r io.Reader) map[string]string {
+	files := map[string]string{}
+	reader := tar.NewReader(r)
+	for header, err := reader.Next(); err == nil; header, err = reader.Next() {
+		if header.Typeflag != tar.TypeReg || header.Name == "watermark.txt" {
+			continue
+		}
+		contents, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		files[header.Name] = string(contents)
+	}
+	return files
+}
+
+func doRequest(t *testing.T, method string, url string, body []byte) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, respBody
+}
+
+func TestServerSession(t *testing.T) {
+	server := newTestServer(t, defaultServeOptions())
+	session := server.URL + "/v1/sessions/case-1"
+
+	resp, body := doRequest(t, http.MethodPost, session+"/clean", tarOf(t, map[string]string{
+		"a.log":  "10.0.0.1 and 10.0.0.2\n",
+		"b.omit": "10.0.0.3\n",
+	}))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "application/x-tar", resp.Header.Get("Content-Type"))
+	assert.Equal(t, map[string]string{"a.log": "x-ipv4-0000000001-x and x-ipv4-0000000002-x\n"}, readTarFiles(t, bytes.NewReader(body)))
+
+	// a compressed upload is answered compressed, the replacements of the first upload are kept
+	resp, body = doRequest(t, http.MethodPost, session+"/clean?name=second", gzipOf(t, tarOf(t, map[string]string{
+		"c.log":  "10.0.0.2 10.0.0.3\n",
+		"d.omit": "10.0.0.4\n",
+	})))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "application/gzip", resp.Header.Get("Content-Type"))
+	gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"c.log": "x-ipv4-0000000002-x x-ipv4-0000000003-x\n"}, readTarFiles(t, gzipReader))
+
+	resp, body = doRequest(t, http.MethodPost, session+"/obfuscate", []byte("10.0.0.3 and 10.0.0.5\n"))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "x-ipv4-0000000003-x and x-ipv4-0000000004-x\n", string(body))
+
+	// other sessions have their own replacements
+	resp, body = doRequest(t, http.MethodPost, server.URL+"/v1/sessions/case-2/obfuscate", []byte("10.0.0.5\n"))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "x-ipv4-0000000001-x\n", string(body))
+
+	resp, body = doRequest(t, http.MethodGet, session+"/report", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var report reporting.Report
+	require.NoError(t, yaml.Unmarshal(body, &report))
+	var omitted []string
+	for _, o := range report.Omissions {
+		omitted = append(omitted, o.Path)
+	}
+	assert.Equal(t, []string{"second/d.omit", "upload-1/b.omit"}, omitted)
+	require.Len(t, report.Replacements, 1)
+	assert.Len(t, report.Replacements[0], 4)
+	// the must-gather layout adds the omission of secrets once for all uploads
+	assert.Len(t, report.Config.Omit, 2)
+	assert.Len(t, report.Config.Obfuscate[0].Replacement, 4)
+
+	resp, _ = doRequest(t, http.MethodDelete, session, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = doRequest(t, http.MethodGet, session+"/report", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = doRequest(t, http.MethodDelete, session, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServerErrors(t *testing.T) {
+	options := defaultServeOptions()
+	options.MaxRequestSize = 4096
+	options.MaxExtractedSize = 8192
+	server := newTestServer(t, options)
+	upload := tarOf(t, map[string]string{"a.log": "10.0.0.1\n"})
+
+	for _, tc := range []struct {
+		name           string
+		method         string
+		path           string
+		body           []byte
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "unknown path", method: http.MethodGet, path: "/v1/other", expectedStatus: http.StatusNotFound},
+		{name: "unknown operation", method: http.MethodPost, path: "/v1/sessions/case/other", expectedStatus: http.StatusNotFound},
+		{name: "invalid session id", method: http.MethodPost, path: "/v1/sessions/.case/obfuscate", expectedStatus: http.StatusBadRequest, expectedBody: "invalid session id '.case'"},
+		{name: "wrong method", method: http.MethodGet, path: "/v1/sessions/case/clean", expectedStatus: http.StatusMethodNotAllowed},
+		{name: "unknown session", method: http.MethodGet, path: "/v1/sessions/unknown/report", expectedStatus: http.StatusNotFound},
+		{name: "no tar", method: http.MethodPost, path: "/v1/sessions/case/clean", body: []byte("10.0.0.1\n"), expectedStatus: http.StatusBadRequest, expectedBody: "the upload is not a tar archive"},
+		{name: "invalid name", method: http.MethodPost, path: "/v1/sessions/case/clean?name=../x", body: upload, expectedStatus: http.StatusBadRequest, expectedBody: "invalid upload name '../x'"},
+		{name: "unknown layout", method: http.MethodPost, path: "/v1/sessions/case/clean?layout=other", body: upload, expectedStatus: http.StatusBadRequest, expectedBody: "unknown layout 'other'"},
+		{name: "request too large", method: http.MethodPost, path: "/v1/sessions/case/clean", body: tarOf(t, map[string]string{"a.log": strings.Repeat("a", 5000)}), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "stream too large", method: http.MethodPost, path: "/v1/sessions/case/obfuscate", body: bytes.Repeat([]byte("a"), 5000), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "extracted too large", method: http.MethodPost, path: "/v1/sessions/case/clean", body: gzipOf(t, tarOf(t, map[string]string{"a.log": strings.Repeat("a", 10000)})), expectedStatus: http.StatusRequestEntityTooLarge, expectedBody: "the extracted upload exceeds the maximum size"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := doRequest(t, tc.method, server.URL+tc.path, tc.body)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			assert.Contains(t, string(body), tc.expectedBody)
+			if tc.expectedStatus == http.StatusMethodNotAllowed {
+				assert.Equal(t, http.MethodPost, resp.Header.Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	options := defaultServeOptions()
+	options.Token = "secret"
+	server := newTestServer(t, options)
+
+	for _, tc := range []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "no token", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer other", expectedStatus: http.StatusUnauthorized},
+		{name: "basic auth", authorization: "Basic secret", expectedStatus: http.StatusUnauthorized},
+		{name: "token", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/sessions/case/obfuscate", strings.NewReader("10.0.0.1\n"))
+			require.NoError(t, err)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			if tc.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestServerSessionLimits(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(serveConfig), 0600))
+	options := defaultServeOptions()
+	options.SessionTTL = time.Hour
+	options.MaxSessions = 2
+	server, err := NewServer(cfgPath, options)
+	require.NoError(t, err)
+	now := time.Unix(0, 0)
+	server.now = func() time.Time {
+		return now
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	sessions := httpServer.URL + "/v1/sessions/"
+
+	for _, id := range []string{"case-1", "case-2"} {
+		resp, body := doRequest(t, http.MethodPost, sessions+id+"/obfuscate", []byte("10.0.0.1\n"))
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	}
+	resp, body := doRequest(t, http.MethodPost, sessions+"case-3/obfuscate", []byte("10.0.0.1\n"))
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Contains(t, string(body), "the maximum of 2 sessions is reached")
+
+	// the request to case-2 keeps it alive, case-1 expires and makes room for case-3
+	now = now.Add(40 * time.Minute)
+	resp, _ = doRequest(t, http.MethodGet, sessions+"case-2/report", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	now = now.Add(40 * time.Minute)
+	resp, _ = doRequest(t, http.MethodGet, sessions+"case-1/report", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, body = doRequest(t, http.MethodPost, sessions+"case-3/obfuscate", []byte("10.0.0.1\n"))
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	resp, body = doRequest(t, http.MethodPost, sessions+"case-2/obfuscate", []byte("10.0.0.2\n"))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "x-ipv4-0000000002-x\n", string(body))
+
+	// a deleted session makes room as well
+	resp, _ = doRequest(t, http.MethodDelete, sessions+"case-3", nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body = doRequest(t, http.MethodPost, sessions+"case-4/obfuscate", []byte("10.0.0.1\n"))
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+}
+
+func TestNewServerErrors(t *testing.T) {
+	_, err := NewServer("", defaultServeOptions())
+	assert.EqualError(t, err, "a config is required to serve")
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(serveConfig), 0600))
+	options := defaultServeOptions()
+	options.WorkerCount = 0
+	_, err = NewServer(cfgPath, options)
+	assert.EqualError(t, err, "invalid number of workers specified 0")
+}
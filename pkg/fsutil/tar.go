@@ -0,0 +1,170 @@
+package fsutil
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	tarMagicOffset = 257
+	tarMagic       = "ustar"
+)
+
+// IsTarStream returns whether the buffered input starts with a tar header. The input is only peeked at, nothing is consumed.
+func IsTarStream(r *bufio.Reader) bool {
+	header, _ := r.Peek(tarMagicOffset + len(tarMagic))
+	return len(header) == tarMagicOffset+len(tarMagic) && string(header[tarMagicOffset:]) == tarMagic
+}
+
+// ExtractTar extracts the tar stream into the existing folder. Directories, regular files, symbolic and hard links are extracted, other
+// entries are skipped. Entries must not point outside the folder.
+func ExtractTar(r io.Reader, folder string) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read tar stream: %w", err)
+		}
+
+		path, err := pathInFolder(folder, header.Name)
+		if err != nil {
+			return err
+		}
+		if path == folder {
+			continue
+		}
+		if header.Typeflag != tar.TypeDir {
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				return err
+			}
+		}
+
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, mode.Perm()|0700)
+		case tar.TypeReg:
+			err = extractFile(reader, path, mode)
+		case tar.TypeSymlink:
+			err = os.Symlink(header.Linkname, path)
+		case tar.TypeLink:
+			var target string
+			target, err = pathInFolder(folder, header.Linkname)
+			if err == nil {
+				err = os.Link(target, path)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s from tar stream: %w", header.Name, err)
+		}
+	}
+}
+
+// pathInFolder returns the path of the tar entry name inside the folder, or an error if it points outside. A symbolic link that was extracted
+// before must not be a parent of the entry, it could point outside as well.
+func pathInFolder(folder string, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("tar entry %s points outside of the extracted folder", name)
+	}
+
+	parent := folder
+	segments := strings.Split(cleaned, string(filepath.Separator))
+	for _, segment := range segments[:len(segments)-1] {
+		parent = filepath.Join(parent, segment)
+		stat, err := os.Lstat(parent)
+		if err == nil && stat.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("tar entry %s points outside of the extracted folder through a symbolic link", name)
+		}
+	}
+	return filepath.Join(folder, cleaned), nil
+}
+
+func extractFile(r io.Reader, path string, mode fs.FileMode) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteTar writes the contents of the folder as a tar stream in lexical order, the names of the entries are relative to the folder. A
+// modTime that is not zero replaces the modification times of all entries.
+func WriteTar(folder string, w io.Writer, modTime time.Time) error {
+	writer := tar.NewWriter(w)
+	err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == folder {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if IsSymbolicLink(info) {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("failed to create tar header for %s: %w", path, err)
+		}
+		name, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if !modTime.IsZero() {
+			header.ModTime, header.AccessTime, header.ChangeTime = modTime, time.Time{}, time.Time{}
+		}
+
+		err = writer.WriteHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write tar header for %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, file)
+		if err != nil {
+			return fmt.Errorf("failed to write %s to tar stream: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
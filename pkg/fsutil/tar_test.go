@@ -0,0 +1,129 @@
+package fsutil
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTarStream(t *testing.T) {
+	var tarStream bytes.Buffer
+	writer := tar.NewWriter(&tarStream)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: "a.log", Mode: 0644, Size: 0, Typeflag: tar.TypeReg}))
+	require.NoError(t, writer.Close())
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		isTar bool
+	}{
+		{name: "tar", input: tarStream.String(), isTar: true},
+		{name: "text", input: "some text with 10.0.0.1\n", isTar: false},
+		{name: "long text", input: strings.Repeat("ustar ", 100), isTar: false},
+		{name: "empty", input: "", isTar: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tc.input))
+			assert.Equal(t, tc.isTar, IsTarStream(reader))
+			// nothing was consumed
+			rest := new(bytes.Buffer)
+			_, err := rest.ReadFrom(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tc.input, rest.String())
+		})
+	}
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	input := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(input, "dir", "empty"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(input, "dir", "a.log"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(input, "b.log"), []byte("b"), 0600))
+	require.NoError(t, os.Symlink(filepath.Join("dir", "a.log"), filepath.Join(input, "link")))
+
+	var stream bytes.Buffer
+	modTime := time.Unix(0, 0)
+	require.NoError(t, WriteTar(input, &stream, modTime))
+
+	var names []string
+	reader := tar.NewReader(bytes.NewReader(stream.Bytes()))
+	for header, err := reader.Next(); err == nil; header, err = reader.Next() {
+		names = append(names, header.Name)
+		assert.True(t, header.ModTime.Equal(modTime), "%s has the mod time %v", header.Name, header.ModTime)
+	}
+	assert.Equal(t, []string{"b.log", "dir/", "dir/a.log", "dir/empty/", "link"}, names)
+
+	output := t.TempDir()
+	require.NoError(t, ExtractTar(bytes.NewReader(stream.Bytes()), output))
+	contents, err := os.ReadFile(filepath.Join(output, "dir", "a.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(contents))
+	stat, err := os.Stat(filepath.Join(output, "b.log"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	assert.DirExists(t, filepath.Join(output, "dir", "empty"))
+	link, err := os.Readlink(filepath.Join(output, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("dir", "a.log"), link)
+}
+
+func TestExtractTarHardLink(t *testing.T) {
+	var stream bytes.Buffer
+	writer := tar.NewWriter(&stream)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: "a.log", Mode: 0644, Size: 1, Typeflag: tar.TypeReg}))
+	_, err := writer.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: "dir/b.log", Linkname: "a.log", Typeflag: tar.TypeLink}))
+	require.NoError(t, writer.Close())
+
+	output := t.TempDir()
+	require.NoError(t, ExtractTar(&stream, output))
+	contents, err := os.ReadFile(filepath.Join(output, "dir", "b.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(contents))
+}
+
+func TestExtractTarRejectsPathsOutsideFolder(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		headers []tar.Header
+	}{
+		{name: "parent", headers: []tar.Header{{Name: "../x.log", Mode: 0644, Typeflag: tar.TypeReg}}},
+		{name: "nested parent", headers: []tar.Header{{Name: "dir/../../x.log", Mode: 0644, Typeflag: tar.TypeReg}}},
+		{name: "absolute", headers: []tar.Header{{Name: "/x.log", Mode: 0644, Typeflag: tar.TypeReg}}},
+		{name: "hard link", headers: []tar.Header{{Name: "x.log", Linkname: "../y.log", Typeflag: tar.TypeLink}}},
+		{name: "symbolic link", headers: []tar.Header{
+			{Name: "link", Linkname: "..", Typeflag: tar.TypeSymlink},
+			{Name: "link/x.log", Mode: 0644, Typeflag: tar.TypeReg},
+		}},
+		{name: "nested symbolic link", headers: []tar.Header{
+			{Name: "dir/", Mode: 0755, Typeflag: tar.TypeDir},
+			{Name: "dir/link", Linkname: "../..", Typeflag: tar.TypeSymlink},
+			{Name: "dir/link/x.log", Mode: 0644, Typeflag: tar.TypeReg},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stream bytes.Buffer
+			writer := tar.NewWriter(&stream)
+			for _, header := range tc.headers {
+				header := header
+				require.NoError(t, writer.WriteHeader(&header))
+			}
+			require.NoError(t, writer.Close())
+
+			parent := t.TempDir()
+			output := filepath.Join(parent, "output")
+			require.NoError(t, os.Mkdir(output, 0755))
+			assert.ErrorContains(t, ExtractTar(&stream, output), "points outside of the extracted folder")
+			assert.NoFileExists(t, filepath.Join(parent, "x.log"))
+		})
+	}
+}
@@ -0,0 +1,565 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var NoKubernetesResourceError = errors.New("not a k8s resource")
+
+type PartType int
+
+const (
+	// TextPart is content that is not an object by itself, for example the header of a List or a yaml document separator.
+	TextPart PartType = iota
+	// ObjectPart is a single object, either an item of a List or a whole yaml document or json file.
+	ObjectPart
+)
+
+// Part is a consecutive piece of the decoded file. Writing the Raw content of all parts in order, with the Separator written before each
+// object that follows another written object, yields the original file again.
+type Part struct {
+	Type PartType
+	// Separator must be written before Raw when the previous object of the same list was written as well, it is only set for json lists.
+	Separator []byte
+	Raw       []byte
+
+	isJson bool
+	// isSequenceItem is set for yaml list items, their Raw content starts with the "- " of the sequence entry.
+	isSequenceItem bool
+}
+
+// Decode unmarshals the object of an ObjectPart into out.
+func (p *Part) Decode(out interface{}) error {
+	if p.Type != ObjectPart {
+		return NoKubernetesResourceError
+	}
+	if p.isJson {
+		return json.Unmarshal(p.Raw, out)
+	}
+	if p.isSequenceItem {
+		var sequence []yaml.Node
+		if err := yaml.Unmarshal(p.Raw, &sequence); err != nil {
+			return err
+		}
+		if len(sequence) != 1 {
+			return fmt.Errorf("expected a single list item, found %d", len(sequence))
+		}
+		return sequence[0].Decode(out)
+	}
+	return yaml.Unmarshal(p.Raw, out)
+}
+
+// Resource returns the kind, apiVersion and metadata of an ObjectPart, or a NoKubernetesResourceError when it is not a kubernetes resource.
+func (p *Part) Resource() (Resource, error) {
+	var resource Resource
+	if err := p.Decode(&resource); err != nil || resource.Kind == "" || resource.ApiVersion == "" {
+		return Resource{}, NoKubernetesResourceError
+	}
+	return resource, nil
+}
+
+// ResourceDecoder splits a yaml or json file into its objects while streaming through it. Only a single object is kept in memory at
+// a time, the size of which is bounded by the maximum size of a kubernetes resource, while the size of a List or multi-document
+// yaml is not. Any top-level "items" list is treated as the items of a List. Content that can't be parsed is returned as an ObjectPart
+// that fails to decode.
+type ResourceDecoder interface {
+	// Next returns the next part of the file, or io.EOF when the end of the file is reached.
+	Next() (*Part, error)
+}
+
+// IsResourceFile returns true when the file extension denotes a yaml or json file, which possibly contains kubernetes resources.
+func IsResourceFile(path string) bool {
+	return isYamlFile(path) || strings.HasSuffix(path, ".json")
+}
+
+func isYamlFile(path string) bool {
+	return strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".yaml")
+}
+
+// NewResourceDecoder creates a decoder for the file at path, reading its content from reader.
+// It will return a NoKubernetesResourceError in case it's not a yml/yaml or json file.
+func NewResourceDecoder(path string, reader io.Reader) (ResourceDecoder, error) {
+	switch {
+	case isYamlFile(path):
+		return &yamlDecoder{reader: bufio.NewReader(reader)}, nil
+	case strings.HasSuffix(path, ".json"):
+		return &jsonDecoder{reader: bufio.NewReader(reader)}, nil
+	}
+	return nil, NoKubernetesResourceError
+}
+
+type yamlDecoderMode int
+
+const (
+	// yamlModeDocument collects the lines of a document, which is either a single object or the header of a List
+	yamlModeDocument yamlDecoderMode = iota
+	// yamlModeItemsKey is entered after a top-level "items:" key, it's not yet known whether a sequence follows
+	yamlModeItemsKey
+	yamlModeItems
+	// yamlModeTrailer collects the lines of a List document after its items
+	yamlModeTrailer
+)
+
+// yamlDecoder splits yaml files line by line, based on the document separators and the indentation of the top-level "items" sequence.
+type yamlDecoder struct {
+	reader *bufio.Reader
+	queue  []*Part
+	eof    bool
+
+	mode       yamlDecoderMode
+	document   bytes.Buffer
+	item       bytes.Buffer
+	itemIndent int
+}
+
+func (d *yamlDecoder) Next() (*Part, error) {
+	for len(d.queue) == 0 {
+		if d.eof {
+			return nil, io.EOF
+		}
+
+		line, err := d.reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if line != "" {
+			d.processLine(line)
+		}
+		if err != nil {
+			d.finishDocument()
+			d.eof = true
+		}
+	}
+
+	part := d.queue[0]
+	d.queue = d.queue[1:]
+	return part, nil
+}
+
+func (d *yamlDecoder) processLine(line string) {
+	if isYamlDocumentSeparator(line) {
+		d.finishDocument()
+		d.queueText([]byte(line))
+		return
+	}
+
+	switch d.mode {
+	case yamlModeDocument:
+		d.document.WriteString(line)
+		if isYamlItemsKey(line) {
+			d.mode = yamlModeItemsKey
+		}
+	case yamlModeItemsKey:
+		if isYamlBlankOrComment(line) {
+			d.document.WriteString(line)
+			return
+		}
+		if indent, ok := yamlSequenceEntryIndent(line); ok {
+			// everything up to here is the header of the List
+			d.queueText(d.document.Bytes())
+			d.document.Reset()
+			d.itemIndent = indent
+			d.item.WriteString(line)
+			d.mode = yamlModeItems
+			return
+		}
+		// the items are not a block sequence, the document is a single object
+		d.document.WriteString(line)
+		d.mode = yamlModeDocument
+	case yamlModeItems:
+		if indent, ok := yamlSequenceEntryIndent(line); ok && indent == d.itemIndent {
+			d.finishItem()
+			d.item.WriteString(line)
+			return
+		}
+		if isYamlBlankOrComment(line) || yamlIndent(line) > d.itemIndent {
+			d.item.WriteString(line)
+			return
+		}
+		d.finishItem()
+		d.document.WriteString(line)
+		d.mode = yamlModeTrailer
+	case yamlModeTrailer:
+		d.document.WriteString(line)
+	}
+}
+
+func (d *yamlDecoder) finishItem() {
+	if d.item.Len() == 0 {
+		return
+	}
+	d.queue = append(d.queue, &Part{Type: ObjectPart, Raw: copyBytes(d.item.Bytes()), isSequenceItem: true})
+	d.item.Reset()
+}
+
+func (d *yamlDecoder) finishDocument() {
+	switch d.mode {
+	case yamlModeDocument, yamlModeItemsKey:
+		if d.document.Len() > 0 {
+			if isYamlBlankOrComment(d.document.String()) {
+				d.queueText(d.document.Bytes())
+			} else {
+				d.queue = append(d.queue, &Part{Type: ObjectPart, Raw: copyBytes(d.document.Bytes())})
+			}
+		}
+	case yamlModeItems:
+		d.finishItem()
+	case yamlModeTrailer:
+		d.queueText(d.document.Bytes())
+	}
+	d.document.Reset()
+	d.mode = yamlModeDocument
+}
+
+func (d *yamlDecoder) queueText(raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+	d.queue = append(d.queue, &Part{Type: TextPart, Raw: copyBytes(raw)})
+}
+
+func isYamlDocumentSeparator(line string) bool {
+	for _, marker := range []string{"---", "..."} {
+		if strings.HasPrefix(line, marker) {
+			rest := line[len(marker):]
+			if rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isYamlItemsKey(line string) bool {
+	if !strings.HasPrefix(line, "items:") {
+		return false
+	}
+	rest := strings.TrimSpace(line[len("items:"):])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// isYamlBlankOrComment returns true when all lines of s are empty or comments.
+func isYamlBlankOrComment(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+func yamlIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func yamlSequenceEntryIndent(line string) (int, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if !strings.HasPrefix(trimmed, "-") {
+		return 0, false
+	}
+	rest := trimmed[1:]
+	if rest == "" || rest[0] == ' ' || rest[0] == '\n' || rest[0] == '\r' {
+		return len(line) - len(trimmed), true
+	}
+	return 0, false
+}
+
+type jsonDecoderState int
+
+const (
+	jsonStateStart jsonDecoderState = iota
+	jsonStateMembers
+	jsonStateItems
+	jsonStateDone
+)
+
+// jsonDecoder scans the structure of a json file byte by byte. The members of the top-level object are collected, except for the
+// elements of its "items" array, which are returned one by one.
+type jsonDecoder struct {
+	reader *bufio.Reader
+	queue  []*Part
+	state  jsonDecoderState
+
+	buf       bytes.Buffer
+	isList    bool
+	firstItem bool
+}
+
+func (d *jsonDecoder) Next() (*Part, error) {
+	for len(d.queue) == 0 {
+		if d.state == jsonStateDone {
+			return nil, io.EOF
+		}
+
+		err := d.step()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, errJsonSyntax) {
+				// the remaining content can't be parsed as json, it is returned as an object that is not a kubernetes resource
+				if rest, err := io.ReadAll(d.reader); err == nil {
+					d.buf.Write(rest)
+				} else {
+					return nil, err
+				}
+				d.finish(true)
+				continue
+			}
+			return nil, err
+		}
+	}
+
+	part := d.queue[0]
+	d.queue = d.queue[1:]
+	return part, nil
+}
+
+var errJsonSyntax = errors.New("json syntax error")
+
+func (d *jsonDecoder) step() error {
+	switch d.state {
+	case jsonStateStart:
+		if err := d.readWhitespace(&d.buf); err != nil {
+			return err
+		}
+		c, err := d.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		d.buf.WriteByte(c)
+		if c != '{' {
+			return errJsonSyntax
+		}
+		d.state = jsonStateMembers
+	case jsonStateMembers:
+		if err := d.readWhitespace(&d.buf); err != nil {
+			return err
+		}
+		c, err := d.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		d.buf.WriteByte(c)
+		switch c {
+		case ',':
+			return nil
+		case '}':
+			if err := d.readWhitespace(&d.buf); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			if _, err := d.reader.Peek(1); !errors.Is(err, io.EOF) {
+				return errJsonSyntax
+			}
+			d.finish(!d.isList)
+			return nil
+		case '"':
+		default:
+			return errJsonSyntax
+		}
+
+		key, err := d.readString(&d.buf)
+		if err != nil {
+			return err
+		}
+		if err := d.readWhitespace(&d.buf); err != nil {
+			return err
+		}
+		if c, err = d.reader.ReadByte(); err != nil {
+			return err
+		}
+		d.buf.WriteByte(c)
+		if c != ':' {
+			return errJsonSyntax
+		}
+		if err := d.readWhitespace(&d.buf); err != nil {
+			return err
+		}
+
+		next, err := d.reader.Peek(1)
+		if err != nil {
+			return err
+		}
+		if key == "items" && next[0] == '[' && !d.isList {
+			_, _ = d.reader.ReadByte()
+			d.buf.WriteByte('[')
+			d.queueText()
+			d.isList = true
+			d.firstItem = true
+			d.state = jsonStateItems
+			return nil
+		}
+		return d.readValue(&d.buf)
+	case jsonStateItems:
+		// the buffer is empty at the start of every item, so that partially read items remain in it when they can't be parsed
+		if err := d.readWhitespace(&d.buf); err != nil {
+			return err
+		}
+		c, err := d.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == ']' {
+			d.buf.WriteByte(c)
+			d.state = jsonStateMembers
+			return nil
+		}
+
+		separatorLen := 0
+		if d.firstItem {
+			_ = d.reader.UnreadByte()
+			d.firstItem = false
+		} else {
+			d.buf.WriteByte(c)
+			if c != ',' {
+				return errJsonSyntax
+			}
+			separatorLen = d.buf.Len()
+			if err := d.readWhitespace(&d.buf); err != nil {
+				return err
+			}
+		}
+		if err := d.readValue(&d.buf); err != nil {
+			return err
+		}
+
+		raw := d.buf.Bytes()
+		var separator []byte
+		if separatorLen > 0 {
+			separator = copyBytes(raw[:separatorLen])
+		}
+		d.queue = append(d.queue, &Part{Type: ObjectPart, Separator: separator, Raw: copyBytes(raw[separatorLen:]), isJson: true})
+		d.buf.Reset()
+	}
+	return nil
+}
+
+// finish queues the remaining content, which is the whole object when the file did not contain a List.
+func (d *jsonDecoder) finish(isObject bool) {
+	if isObject && d.buf.Len() > 0 {
+		d.queue = append(d.queue, &Part{Type: ObjectPart, Raw: copyBytes(d.buf.Bytes()), isJson: true})
+		d.buf.Reset()
+	} else {
+		d.queueText()
+	}
+	d.state = jsonStateDone
+}
+
+func (d *jsonDecoder) queueText() {
+	if d.buf.Len() == 0 {
+		return
+	}
+	d.queue = append(d.queue, &Part{Type: TextPart, Raw: copyBytes(d.buf.Bytes())})
+	d.buf.Reset()
+}
+
+func (d *jsonDecoder) readWhitespace(out *bytes.Buffer) error {
+	for {
+		c, err := d.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return d.reader.UnreadByte()
+		}
+		out.WriteByte(c)
+	}
+}
+
+// readString reads the remainder of a string after its opening quote and returns its decoded value.
+func (d *jsonDecoder) readString(out *bytes.Buffer) (string, error) {
+	start := out.Len() - 1
+	escaped := false
+	for {
+		c, err := d.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		out.WriteByte(c)
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			var s string
+			if err := json.Unmarshal(out.Bytes()[start:], &s); err != nil {
+				return "", errJsonSyntax
+			}
+			return s, nil
+		}
+	}
+}
+
+// readValue reads a complete json value of any type.
+func (d *jsonDecoder) readValue(out *bytes.Buffer) error {
+	c, err := d.reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	out.WriteByte(c)
+	switch c {
+	case '"':
+		_, err := d.readString(out)
+		return err
+	case '{', '[':
+		closing := []byte{jsonClosing(c)}
+		for len(closing) > 0 {
+			c, err := d.reader.ReadByte()
+			if err != nil {
+				return err
+			}
+			out.WriteByte(c)
+			switch c {
+			case '"':
+				if _, err := d.readString(out); err != nil {
+					return err
+				}
+			case '{', '[':
+				closing = append(closing, jsonClosing(c))
+			case '}', ']':
+				if closing[len(closing)-1] != c {
+					return errJsonSyntax
+				}
+				closing = closing[:len(closing)-1]
+			}
+		}
+		return nil
+	case '}', ']', ',', ':':
+		return errJsonSyntax
+	}
+
+	// numbers, booleans and null end at the next delimiter
+	for {
+		next, err := d.reader.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch next[0] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			return nil
+		}
+		c, _ := d.reader.ReadByte()
+		out.WriteByte(c)
+	}
+}
+
+func jsonClosing(opening byte) byte {
+	if opening == '{' {
+		return '}'
+	}
+	return ']'
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
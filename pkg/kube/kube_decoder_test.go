@@ -0,0 +1,200 @@
+package kube
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceDecoder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		path     string
+		input    string
+		expected []string
+		// expectedText are the indices of the parts that are expected to be TextPart, all other parts are objects
+		expectedText []int
+	}{
+		{
+			name: "single yaml resource",
+			path: "a.yaml",
+			input: `apiVersion: v1
+data:
+  key: value
+kind: Secret
+metadata:
+  name: token
+`,
+			expected: []string{"v1/Secret token"},
+		},
+		{
+			name: "yaml list with items before the kind",
+			path: "a.yaml",
+			input: `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: first
+  data:
+    items: |
+      - not an item
+# a comment
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: second
+kind: List
+metadata:
+  resourceVersion: ""
+`,
+			expected:     []string{"", "v1/Secret first", "v1/ConfigMap second", ""},
+			expectedText: []int{0, 3},
+		},
+		{
+			name: "yaml list with indented items",
+			path: "a.yml",
+			input: `apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: first
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: second
+`,
+			expected:     []string{"", "v1/Secret first", "v1/Secret second"},
+			expectedText: []int{0},
+		},
+		{
+			name: "yaml items that are not a sequence",
+			path: "a.yaml",
+			input: `apiVersion: v1
+kind: Something
+metadata:
+  name: no-list
+items:
+  nested: value
+`,
+			expected: []string{"v1/Something no-list"},
+		},
+		{
+			name: "multi-document yaml",
+			path: "a.yaml",
+			input: `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: first
+---
+# only a comment
+---
+not: a resource
+...
+`,
+			expected:     []string{"", "v1/Secret first", "", "", "", "error", ""},
+			expectedText: []int{0, 2, 3, 4, 6},
+		},
+		{
+			name: "json list",
+			path: "a.json",
+			input: `{
+    "apiVersion": "v1",
+    "items": [
+        {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "first"}},
+        {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "[second]"}}
+    ],
+    "kind": "List"
+}
+`,
+			expected:     []string{"", "v1/Secret first", "v1/ConfigMap [second]", ""},
+			expectedText: []int{0, 3},
+		},
+		{
+			name:         "empty json list",
+			path:         "a.json",
+			input:        `{"kind": "List", "apiVersion": "v1", "items": []}`,
+			expected:     []string{"", ""},
+			expectedText: []int{0, 1},
+		},
+		{
+			name:     "single json resource with escaped strings",
+			path:     "a.json",
+			input:    `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "a\"}b"}, "items": 5}` + "\n",
+			expected: []string{"v1/Secret a\"}b"},
+		},
+		{
+			name:     "invalid json",
+			path:     "a.json",
+			input:    `{"apiVersion": "v1", "kind": }`,
+			expected: []string{"error"},
+		},
+		{
+			name:     "json without a colon",
+			path:     "a.json",
+			input:    `{"apiVersion" "v1"}`,
+			expected: []string{"error"},
+		},
+		{
+			name:         "json list with invalid item",
+			path:         "a.json",
+			input:        `{"items": [{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "first"}}, {"kind": ]}`,
+			expected:     []string{"", "v1/Secret first", "error"},
+			expectedText: []int{0},
+		},
+		{
+			name:     "empty file",
+			path:     "a.yaml",
+			input:    "",
+			expected: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder, err := NewResourceDecoder(tc.path, strings.NewReader(tc.input))
+			require.NoError(t, err)
+
+			var actual []string
+			var text []int
+			var raw strings.Builder
+			for i := 0; ; i++ {
+				part, err := decoder.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				raw.Write(part.Separator)
+				raw.Write(part.Raw)
+
+				if part.Type == TextPart {
+					text = append(text, i)
+					actual = append(actual, "")
+					continue
+				}
+				resource, err := part.Resource()
+				if err != nil {
+					actual = append(actual, "error")
+					continue
+				}
+				actual = append(actual, resource.ApiVersion+"/"+resource.Kind+" "+resource.Metadata.Name)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.expectedText, text)
+			assert.Equal(t, tc.input, raw.String(), "all parts together must yield the original input")
+		})
+	}
+}
+
+func TestResourceDecoderNoKubernetesResource(t *testing.T) {
+	_, err := NewResourceDecoder("some.txt", strings.NewReader(""))
+	require.ErrorIs(t, err, NoKubernetesResourceError)
+	assert.False(t, IsResourceFile("some.yaml.gz"))
+	assert.True(t, IsResourceFile("some.yml"))
+}
@@ -0,0 +1,150 @@
+package kube
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Object is an unstructured kubernetes resource. It is used whenever more than the Resource fields (e.g. spec or status) are required.
+type Object map[string]interface{}
+
+func (o Object) Kind() string {
+	return o.NestedString("kind")
+}
+
+func (o Object) ApiVersion() string {
+	return o.NestedString("apiVersion")
+}
+
+func (o Object) Name() string {
+	return o.NestedString("metadata", "name")
+}
+
+func (o Object) Namespace() string {
+	return o.NestedString("metadata", "namespace")
+}
+
+// NestedString returns the string found under the given path of fields, or an empty string if it does not exist or is not a string.
+func (o Object) NestedString(fields ...string) string {
+	s, _ := o.nested(fields...).(string)
+	return s
+}
+
+// NestedURLHost returns the host without the port of the URL found under the given path of fields, or an empty string if it does not
+// exist or is not a valid URL.
+func (o Object) NestedURLHost(fields ...string) string {
+	rawURL := o.NestedString(fields...)
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// NestedStringSlice returns all strings of the list found under the given path of fields, non-string elements are skipped.
+func (o Object) NestedStringSlice(fields ...string) []string {
+	var result []string
+	for _, v := range o.NestedSlice(fields...) {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// NestedSlice returns the list found under the given path of fields, or nil if it does not exist or is not a list.
+func (o Object) NestedSlice(fields ...string) []interface{} {
+	s, _ := o.nested(fields...).([]interface{})
+	return s
+}
+
+// NestedObjects returns all objects of the list found under the given path of fields, non-object elements are skipped.
+func (o Object) NestedObjects(fields ...string) []Object {
+	var result []Object
+	for _, v := range o.NestedSlice(fields...) {
+		if m, ok := asMap(v); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func (o Object) nested(fields ...string) interface{} {
+	var current interface{} = map[string]interface{}(o)
+	for _, f := range fields {
+		m, ok := asMap(current)
+		if !ok {
+			return nil
+		}
+		current = m[f]
+	}
+	return current
+}
+
+// asMap is required because yaml.v3 decodes nested mappings into the type of the outer map, while json always uses map[string]interface{}.
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Object:
+		return m, true
+	}
+	return nil, false
+}
+
+// ReadObjectsFromPath reads a kubernetes resource from the file as unstructured Object.
+// It will return a NoKubernetesResourceError in case it's not a yml/yaml or json file or when it does not contain a kind and apiVersion.
+// List kinds (of any apiVersion) are expanded into their individual items, as are all documents of a multi-document yaml.
+func ReadObjectsFromPath(path string) ([]Object, error) {
+	if !IsResourceFile(path) {
+		return nil, NoKubernetesResourceError
+	}
+
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	decoder, err := NewResourceDecoder(path, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for {
+		part, err := decoder.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		var object Object
+		if err := part.Decode(&object); err != nil || object.Kind() == "" || object.ApiVersion() == "" {
+			continue
+		}
+		objects = append(objects, ExpandList(object)...)
+	}
+	if len(objects) == 0 {
+		return nil, NoKubernetesResourceError
+	}
+	return objects, nil
+}
+
+// ExpandList returns the items of List kinds or the object itself otherwise.
+func ExpandList(object Object) []Object {
+	if strings.HasSuffix(object.Kind(), "List") {
+		if _, ok := object["items"]; ok {
+			return object.NestedObjects("items")
+		}
+	}
+	return []Object{object}
+}
@@ -3,13 +3,34 @@ package kube
 // TODO(tjungblu): check whether we can tap into the OpenShift and Kubernetes api-machinery for this
 
 type Metadata struct {
-	Namespace string `yaml:"namespace" json:"namespace"`
+	Name              string            `yaml:"name" json:"name"`
+	Namespace         string            `yaml:"namespace" json:"namespace"`
+	Labels            map[string]string `yaml:"labels" json:"labels"`
+	Annotations       map[string]string `yaml:"annotations" json:"annotations"`
+	CreationTimestamp string            `yaml:"creationTimestamp" json:"creationTimestamp"`
 }
 
 type Resource struct {
 	ApiVersion string   `yaml:"apiVersion" json:"apiVersion"`
 	Kind       string   `yaml:"kind" json:"kind"`
 	Metadata   Metadata `yaml:"metadata" json:"metadata"`
+
+	// EventTimes are only set for Event kinds
+	EventTimes `yaml:",inline"`
+}
+
+// EventTimes are the timestamps of the core/v1 and events.k8s.io/v1 Event kinds.
+type EventTimes struct {
+	EventTime                string      `yaml:"eventTime" json:"eventTime"`
+	FirstTimestamp           string      `yaml:"firstTimestamp" json:"firstTimestamp"`
+	LastTimestamp            string      `yaml:"lastTimestamp" json:"lastTimestamp"`
+	DeprecatedFirstTimestamp string      `yaml:"deprecatedFirstTimestamp" json:"deprecatedFirstTimestamp"`
+	DeprecatedLastTimestamp  string      `yaml:"deprecatedLastTimestamp" json:"deprecatedLastTimestamp"`
+	Series                   EventSeries `yaml:"series" json:"series"`
+}
+
+type EventSeries struct {
+	LastObservedTime string `yaml:"lastObservedTime" json:"lastObservedTime"`
 }
 
 type ResourceList struct {
@@ -22,5 +43,29 @@ type ResourceListWithPath struct {
 	Path string
 }
 
-// ResourceUnmarshaller is a helper type to abstract yaml and json marshalling
-type ResourceUnmarshaller func(in []byte, out interface{}) (err error)
+// ID returns the namespace and name of the resource as "namespace/name", or only the name for cluster scoped resources.
+func (r Resource) ID() string {
+	if r.Metadata.Namespace == "" {
+		return r.Metadata.Name
+	}
+	return r.Metadata.Namespace + "/" + r.Metadata.Name
+}
+
+// FirstOccurrence returns the raw timestamp of the first occurrence of an Event, or its creation timestamp when it's not set.
+func (r Resource) FirstOccurrence() string {
+	return firstNonEmpty(r.FirstTimestamp, r.DeprecatedFirstTimestamp, r.EventTime, r.Metadata.CreationTimestamp)
+}
+
+// LastOccurrence returns the raw timestamp of the last occurrence of an Event, or its creation timestamp when it's not set.
+func (r Resource) LastOccurrence() string {
+	return firstNonEmpty(r.Series.LastObservedTime, r.LastTimestamp, r.DeprecatedLastTimestamp, r.EventTime, r.Metadata.CreationTimestamp)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -10,143 +10,45 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestKubernetesResourceReaderYaml(t *testing.T) {
-	for _, tc := range []struct {
-		name           string
-		resource       string
-		expectedError  error
-		expectedOutput *ResourceList
-	}{
-		{
-			name: "basic secret",
-			resource: `apiVersion: v1
+func TestReadObjectsFromPathMultiDocument(t *testing.T) {
+	file, err := asYaml(t, `---
+apiVersion: v1
 kind: Secret
 metadata:
-    namespace: kube-system
-`,
-			expectedError: nil,
-			expectedOutput: &ResourceList{
-				Items: []Resource{
-					{
-						ApiVersion: "v1",
-						Kind:       "Secret",
-						Metadata: Metadata{
-							Namespace: "kube-system",
-						},
-					},
-				},
-			},
-		},
-		{
-
-			name: "non resource kind",
-			resource: `key1:value1
-key2:value2
-`,
-			expectedError:  NoKubernetesResourceError,
-			expectedOutput: nil,
-		},
-		{
-			name: "resource list",
-			resource: `---
+    name: first
+    namespace: default
+---
+not: a resource
+---
 apiVersion: v1
-kind: SecretList
-items:
-    - apiVersion: v1
-      kind: Secret
-      metadata:
-          namespace: kube-system
-          name: first
-    - apiVersion: v1
-      kind: Secret
-      metadata:
-          namespace: kube-system
-          name: second
-`,
-			expectedError: nil,
-			expectedOutput: &ResourceList{
-				Items: []Resource{
-					{
-						ApiVersion: "v1",
-						Kind:       "Secret",
-						Metadata: Metadata{
-							Namespace: "kube-system",
-						},
-					},
-					{
-						ApiVersion: "v1",
-						Kind:       "Secret",
-						Metadata: Metadata{
-							Namespace: "kube-system",
-						},
-					},
-				},
-			},
-		},
-		{
-			name: "empty resource list",
-			resource: `---
-apiVersion: v1
-kind: SecretList
-items: []
-`,
-			expectedError: nil,
-			expectedOutput: &ResourceList{
-				Items: []Resource{},
-			},
-		},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			file, err := asYaml(t, tc.resource)
-			require.NoError(t, err)
-			defer func(name string) {
-				_ = os.Remove(name)
-			}(file.Name())
-
-			assertOutput(t, file.Name(), tc.expectedError, tc.expectedOutput)
-
-			file, err = fromYamlToJson(t, tc.resource)
-			require.NoError(t, err)
-			defer func(name string) {
-				_ = os.Remove(name)
-			}(file.Name())
-
-			assertOutput(t, file.Name(), tc.expectedError, tc.expectedOutput)
-		})
-	}
-}
-func TestNonYamlNonJsonReading(t *testing.T) {
-	_, err := ReadKubernetesResourceFromPath("some.path")
-	require.Equal(t, NoKubernetesResourceError, err)
-}
+kind: ConfigMap
+metadata:
+    name: second
+---
+`)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
 
-func TestReadNonK8sJSON(t *testing.T) {
-	file, err := os.CreateTemp("", "kube-schema-read-*.yaml")
+	objects, err := ReadObjectsFromPath(file.Name())
 	require.NoError(t, err)
-	defer func(name string) {
-		_ = os.Remove(name)
-	}(file.Name())
+	require.Len(t, objects, 2)
+	assert.Equal(t, "first", objects[0].Name())
+	assert.Equal(t, "second", objects[1].Name())
+}
 
-	randomJson := `{"status":"success"}`
-	_, err = file.WriteString(randomJson)
+func TestReadObjectsFromPathMultiDocumentWithoutResources(t *testing.T) {
+	file, err := asYaml(t, "a: b\n---\nc: d\n")
 	require.NoError(t, err)
-	require.NoError(t, file.Close())
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
 
-	_, err = ReadKubernetesResourceFromPath(file.Name())
+	_, err = ReadObjectsFromPath(file.Name())
 	assert.Equal(t, NoKubernetesResourceError, err)
 }
 
-func assertOutput(t *testing.T, fileName string, expectedError error, expectedOutput *ResourceList) {
-	resource, err := ReadKubernetesResourceFromPath(fileName)
-	assert.Equal(t, expectedError, err)
-	if expectedOutput != nil {
-		assert.Equal(t, fileName, resource.Path)
-		assert.Equal(t, expectedOutput, &resource.ResourceList)
-	} else {
-		assert.Nil(t, resource)
-	}
-}
-
 func asYaml(t *testing.T, resource string) (*os.File, error) {
 	file, err := os.CreateTemp("", "kube-schema-read-*.yaml")
 	require.NoError(t, err)
@@ -166,3 +68,89 @@ func fromYamlToJson(t *testing.T, resource string) (*os.File, error) {
 	require.NoError(t, file.Close())
 	return file, err
 }
+
+func TestReadObjectsFromPath(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		resource      string
+		expectedError error
+		expectedNames []string
+	}{
+		{
+			name: "single resource",
+			resource: `apiVersion: config.openshift.io/v1
+kind: Infrastructure
+metadata:
+    name: cluster
+status:
+    infrastructureName: mycluster-x7k2p
+`,
+			expectedNames: []string{"cluster"},
+		},
+		{
+			name: "non v1 list",
+			resource: `apiVersion: config.openshift.io/v1
+kind: InfrastructureList
+items:
+    - apiVersion: config.openshift.io/v1
+      kind: Infrastructure
+      metadata:
+          name: first
+    - apiVersion: config.openshift.io/v1
+      kind: Infrastructure
+      metadata:
+          name: second
+`,
+			expectedNames: []string{"first", "second"},
+		},
+		{
+			name: "non resource kind",
+			resource: `key1: value1
+`,
+			expectedError: NoKubernetesResourceError,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, createFile := range []func(t *testing.T, resource string) (*os.File, error){asYaml, fromYamlToJson} {
+				file, err := createFile(t, tc.resource)
+				require.NoError(t, err)
+				defer func(name string) {
+					_ = os.Remove(name)
+				}(file.Name())
+
+				objects, err := ReadObjectsFromPath(file.Name())
+				assert.Equal(t, tc.expectedError, err)
+				var names []string
+				for _, o := range objects {
+					names = append(names, o.Name())
+				}
+				assert.Equal(t, tc.expectedNames, names)
+			}
+		})
+	}
+}
+
+func TestObjectNestedAccessors(t *testing.T) {
+	o := Object{
+		"spec": map[string]interface{}{
+			"noProxy": "a,b",
+			"api":     "https://api.example.com:6443/path",
+			"invalid": "https://[::1",
+			"mirrors": []interface{}{"one", 2, "three"},
+			"digests": []interface{}{
+				map[string]interface{}{"source": "quay.io"},
+				"not an object",
+			},
+		},
+	}
+
+	assert.Equal(t, "a,b", o.NestedString("spec", "noProxy"))
+	assert.Equal(t, "", o.NestedString("spec", "noProxy", "deeper"))
+	assert.Equal(t, "", o.NestedString("status", "noProxy"))
+	assert.Equal(t, "api.example.com", o.NestedURLHost("spec", "api"))
+	assert.Equal(t, "", o.NestedURLHost("spec", "invalid"))
+	assert.Equal(t, "", o.NestedURLHost("spec", "missing"))
+	assert.Equal(t, []string{"one", "three"}, o.NestedStringSlice("spec", "mirrors"))
+	require.Len(t, o.NestedObjects("spec", "digests"), 1)
+	assert.Equal(t, "quay.io", o.NestedObjects("spec", "digests")[0].NestedString("source"))
+}
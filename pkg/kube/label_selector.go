@@ -0,0 +1,141 @@
+package kube
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type selectorOperator string
+
+const (
+	operatorExists       selectorOperator = "exists"
+	operatorDoesNotExist selectorOperator = "!"
+	operatorEquals       selectorOperator = "="
+	operatorNotEquals    selectorOperator = "!="
+	operatorIn           selectorOperator = "in"
+	operatorNotIn        selectorOperator = "notin"
+)
+
+var (
+	setRequirementPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+	labelKeyPattern       = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?/)?[a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?$`)
+	labelValuePattern     = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?)?$`)
+)
+
+type labelRequirement struct {
+	key      string
+	operator selectorOperator
+	values   map[string]struct{}
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, exists := labels[r.key]
+	_, contained := r.values[value]
+	switch r.operator {
+	case operatorExists:
+		return exists
+	case operatorDoesNotExist:
+		return !exists
+	case operatorEquals, operatorIn:
+		return exists && contained
+	case operatorNotEquals, operatorNotIn:
+		// like in kubernetes, resources without the label match negative requirements
+		return !exists || !contained
+	}
+	return false
+}
+
+// LabelSelector is a parsed label selector. All of its requirements must match.
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// Matches returns true if the labels fulfill all requirements of the selector. An empty selector matches all labels.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabelSelector parses a label selector in the syntax used by kubectl, for example "app=web,tier in (frontend, backend),!deprecated".
+// The supported operators are =, ==, !=, in, notin as well as the existence check "key" and the non-existence check "!key".
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var result LabelSelector
+	if strings.TrimSpace(selector) == "" {
+		return result, nil
+	}
+
+	for _, part := range splitRequirements(selector) {
+		r, err := parseRequirement(strings.TrimSpace(part))
+		if err != nil {
+			return LabelSelector{}, fmt.Errorf("invalid label selector '%s': %w", selector, err)
+		}
+		result.requirements = append(result.requirements, r)
+	}
+	return result, nil
+}
+
+// splitRequirements splits the selector on all commas that are not part of a set of values.
+func splitRequirements(selector string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseRequirement(part string) (labelRequirement, error) {
+	if part == "" {
+		return labelRequirement{}, fmt.Errorf("empty requirement")
+	}
+
+	var r labelRequirement
+	var values []string
+	if m := setRequirementPattern.FindStringSubmatch(part); m != nil {
+		r.key, r.operator = m[1], selectorOperator(m[2])
+		values = strings.Split(m[3], ",")
+	} else if strings.HasPrefix(part, "!") && !strings.Contains(part, "=") {
+		r.key, r.operator = strings.TrimSpace(part[1:]), operatorDoesNotExist
+	} else if i := strings.Index(part, "!="); i >= 0 {
+		r.key, r.operator = part[:i], operatorNotEquals
+		values = []string{part[i+2:]}
+	} else if i := strings.Index(part, "=="); i >= 0 {
+		r.key, r.operator = part[:i], operatorEquals
+		values = []string{part[i+2:]}
+	} else if i := strings.Index(part, "="); i >= 0 {
+		r.key, r.operator = part[:i], operatorEquals
+		values = []string{part[i+1:]}
+	} else {
+		r.key, r.operator = part, operatorExists
+	}
+
+	r.key = strings.TrimSpace(r.key)
+	if !labelKeyPattern.MatchString(r.key) {
+		return labelRequirement{}, fmt.Errorf("invalid label key '%s'", r.key)
+	}
+
+	r.values = map[string]struct{}{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !labelValuePattern.MatchString(v) {
+			return labelRequirement{}, fmt.Errorf("invalid label value '%s' for key '%s'", v, r.key)
+		}
+		r.values[v] = struct{}{}
+	}
+	return r, nil
+}
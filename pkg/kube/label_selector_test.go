@@ -0,0 +1,63 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{
+		"app":                         "web",
+		"tier":                        "frontend",
+		"customer-data":               "true",
+		"app.kubernetes.io/component": "server",
+	}
+	for _, tc := range []struct {
+		selector string
+		matches  bool
+	}{
+		{selector: "", matches: true},
+		{selector: "app=web", matches: true},
+		{selector: "app==web", matches: true},
+		{selector: "app = web", matches: true},
+		{selector: "app=db", matches: false},
+		{selector: "app!=db", matches: true},
+		{selector: "missing!=db", matches: true},
+		{selector: "app!=web", matches: false},
+		{selector: "customer-data", matches: true},
+		{selector: "missing", matches: false},
+		{selector: "!missing", matches: true},
+		{selector: "!app", matches: false},
+		{selector: "tier in (frontend, backend)", matches: true},
+		{selector: "tier in (backend)", matches: false},
+		{selector: "missing in (backend)", matches: false},
+		{selector: "tier notin (backend)", matches: true},
+		{selector: "tier notin (frontend,backend)", matches: false},
+		{selector: "missing notin (backend)", matches: true},
+		{selector: "app.kubernetes.io/component=server", matches: true},
+		{selector: "app=web,tier in (frontend, backend),!deprecated", matches: true},
+		{selector: "app=web,tier in (backend),!deprecated", matches: false},
+	} {
+		t.Run(tc.selector, func(t *testing.T) {
+			s, err := ParseLabelSelector(tc.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tc.matches, s.Matches(labels))
+		})
+	}
+}
+
+func TestParseLabelSelectorErrors(t *testing.T) {
+	for selector, expectedError := range map[string]string{
+		"app=web,,tier":   "invalid label selector 'app=web,,tier': empty requirement",
+		"=web":            "invalid label selector '=web': invalid label key ''",
+		"app in (a b)":    "invalid label selector 'app in (a b)': invalid label value 'a b' for key 'app'",
+		"app=web=db":      "invalid label selector 'app=web=db': invalid label value 'web=db' for key 'app'",
+		"-app=web":        "invalid label selector '-app=web': invalid label key '-app'",
+		"app in frontend": "invalid label selector 'app in frontend': invalid label key 'app in frontend'",
+	} {
+		_, err := ParseLabelSelector(selector)
+		assert.EqualError(t, err, expectedError, selector)
+	}
+}
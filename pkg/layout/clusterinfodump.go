@@ -0,0 +1,37 @@
+package layout
+
+import (
+	"path/filepath"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+)
+
+// clusterInfoDump is the layout of `kubectl cluster-info dump --output-directory`. The cluster scoped resources are json lists in the
+// root, like nodes.json, and the namespaced resources are in a folder per namespace, next to a folder per pod with its logs.txt.
+type clusterInfoDump struct{}
+
+func (c *clusterInfoDump) Name() string {
+	return "cluster-info-dump"
+}
+
+func (c *clusterInfoDump) Matches(inputPath string) bool {
+	return isFile(filepath.Join(inputPath, "nodes.json"))
+}
+
+func (c *clusterInfoDump) Classify(path string) Class {
+	if kube.IsResourceFile(path) {
+		return ClassResource
+	}
+	if filepath.Base(path) == "logs.txt" {
+		return ClassPodLog
+	}
+	return ClassOther
+}
+
+func (c *clusterInfoDump) DefaultOmissions() []schema.Omit {
+	return []schema.Omit{
+		// Resource: kubectl does not dump secrets, but they must not slip through when a dump is extended by hand
+		kubernetesKind("Secret"),
+	}
+}
@@ -0,0 +1,23 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterInfoDumpClassify(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected Class
+	}{
+		{path: "nodes.json", expected: ClassResource},
+		{path: "kube-system/events.json", expected: ClassResource},
+		{path: "kube-system/etcd-master-0/logs.txt", expected: ClassPodLog},
+		{path: "kube-system/etcd-master-0/notes.txt", expected: ClassOther},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, (&clusterInfoDump{}).Classify(tc.path))
+		})
+	}
+}
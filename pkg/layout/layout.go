@@ -0,0 +1,164 @@
+// Package layout describes the directory layouts of the bundles that can be cleaned, like must-gathers or sosreports. A layout classifies
+// the files of a bundle, which tells the cleaner how to read them, and brings default omit rules for the classes of its files.
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+)
+
+// Class is the kind of content of a file in a bundle.
+type Class string
+
+const (
+	// ClassResource files contain kubernetes resources, they are read as yaml or json and omitted per resource.
+	ClassResource Class = "Resource"
+	// ClassPodLog files are the logs of containers.
+	ClassPodLog Class = "PodLog"
+	// ClassNodeLog files are the journals and service logs of nodes.
+	ClassNodeLog Class = "NodeLog"
+	// ClassHostFile files are copied from the file system of a host, like the /etc folder of a sosreport.
+	ClassHostFile Class = "HostFile"
+	// ClassOther are all other files, like the output of commands, they are read as text.
+	ClassOther Class = "Other"
+)
+
+// IsLog returns whether the files of the class consist of log lines, which are filtered by rules of type TimeRange.
+func (c Class) IsLog() bool {
+	return c == ClassPodLog || c == ClassNodeLog
+}
+
+// Classifier decides on the class of the files of a bundle.
+type Classifier interface {
+	// Classify returns the class of the file at path, relative to the root of the bundle. Only yaml and json files can be of the class
+	// Resource.
+	Classify(path string) Class
+}
+
+// Layout is the directory layout of a kind of bundle.
+type Layout interface {
+	Classifier
+
+	// Name identifies the layout on the command line.
+	Name() string
+	// Matches returns whether the folder at inputPath looks like a bundle of the layout.
+	Matches(inputPath string) bool
+	// DefaultOmissions returns the omit rules for the classes of the layout, they are added to the configuration unless disabled.
+	DefaultOmissions() []schema.Omit
+}
+
+// Auto detects the layout of the input.
+const Auto = "auto"
+
+// layouts are all known layouts in the order of their detection. The must-gather layout matches everything, so it must be the last.
+var layouts = []Layout{&sosReport{}, &clusterInfoDump{}, &inspect{}, &mustGather{}}
+
+// Names returns the names of all known layouts.
+func Names() []string {
+	var names []string
+	for _, l := range layouts {
+		names = append(names, l.Name())
+	}
+	return names
+}
+
+// ForInput returns the layout with the given name. With Auto or an empty name, the layout is detected from the input.
+func ForInput(name string, inputPath string) (Layout, error) {
+	if name == "" || name == Auto {
+		return Detect(inputPath), nil
+	}
+	for _, l := range layouts {
+		if l.Name() == name {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown layout '%s', expected one of %s or %s", name, strings.Join(Names(), ", "), Auto)
+}
+
+// Detect returns the first layout matching the input, which is the must-gather layout if no other matches.
+func Detect(inputPath string) Layout {
+	for _, l := range layouts {
+		if l.Matches(inputPath) {
+			return l
+		}
+	}
+	return layouts[len(layouts)-1]
+}
+
+// AddDefaultOmissions appends the default omissions of the layout to the omit rules of the config. Rules the config already contains are
+// not added again, for example when the config is the report of an earlier run.
+func AddDefaultOmissions(config *schema.SchemaJson, l Layout) error {
+	var existing [][]byte
+	for _, o := range config.Config.Omit {
+		rule, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		existing = append(existing, rule)
+	}
+
+	for _, o := range l.DefaultOmissions() {
+		rule, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		if !containsRule(existing, rule) {
+			config.Config.Omit = append(config.Config.Omit, o)
+		}
+	}
+	return nil
+}
+
+func containsRule(rules [][]byte, rule []byte) bool {
+	for _, r := range rules {
+		if bytes.Equal(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyResourceOrPodLog classifies the yaml and json files as resources and the logs of the pod folders of a must-gather as pod logs.
+func classifyResourceOrPodLog(path string) (Class, bool) {
+	if kube.IsResourceFile(path) {
+		return ClassResource, true
+	}
+	segments := segmentsOf(path)
+	name := segments[len(segments)-1]
+	for i := 0; i+2 < len(segments)-1; i++ {
+		// namespaces/<namespace>/pods/<pod>/<container>/<container>/logs/current.log
+		if segments[i] == "namespaces" && segments[i+2] == "pods" && strings.HasSuffix(name, ".log") {
+			return ClassPodLog, true
+		}
+	}
+	return "", false
+}
+
+func segmentsOf(path string) []string {
+	return strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+}
+
+func isDir(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+func isFile(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Mode().IsRegular()
+}
+
+func filePattern(pattern string) schema.Omit {
+	return schema.Omit{Type: schema.OmitTypeFile, Pattern: &pattern}
+}
+
+func kubernetesKind(kind string) schema.Omit {
+	return schema.Omit{Type: schema.OmitTypeKubernetes, KubernetesResource: &schema.OmitKubernetesResource{Kind: &kind}}
+}
@@ -0,0 +1,106 @@
+package layout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createInput(t *testing.T, files ...string) string {
+	inputPath := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(inputPath, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte("content"), 0600))
+	}
+	return inputPath
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		files    []string
+		expected string
+	}{
+		{
+			name:     "must-gather",
+			files:    []string{"timestamp", "quay-io-image/namespaces/default/core/pods.yaml", "quay-io-image/host_service_logs/masters/kubelet_service.log"},
+			expected: "must-gather",
+		},
+		{
+			name:     "must-gather image folder",
+			files:    []string{"namespaces/default/core/pods.yaml", "host_service_logs/masters/kubelet_service.log"},
+			expected: "must-gather",
+		},
+		{
+			name:     "inspect",
+			files:    []string{"timestamp", "event-filter.html", "namespaces/default/core/pods.yaml"},
+			expected: "inspect",
+		},
+		{
+			name:     "inspect of cluster scoped resources",
+			files:    []string{"cluster-scoped-resources/core/nodes/master-0.yaml"},
+			expected: "inspect",
+		},
+		{
+			name:     "sosreport",
+			files:    []string{"etc/hostname", "sos_commands/logs/journalctl_--no-pager", "var/log/messages"},
+			expected: "sosreport",
+		},
+		{
+			name:     "cluster-info dump",
+			files:    []string{"nodes.json", "kube-system/pods.json", "kube-system/etcd-master-0/logs.txt"},
+			expected: "cluster-info-dump",
+		},
+		{
+			name:     "anything else",
+			files:    []string{"a.log"},
+			expected: "must-gather",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Detect(createInput(t, tc.files...)).Name())
+		})
+	}
+}
+
+func TestForInput(t *testing.T) {
+	inputPath := createInput(t, "sos_commands/logs/journalctl_--no-pager")
+
+	for _, name := range []string{"", Auto, "sosreport"} {
+		l, err := ForInput(name, inputPath)
+		require.NoError(t, err)
+		assert.Equal(t, "sosreport", l.Name())
+	}
+
+	l, err := ForInput("must-gather", inputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "must-gather", l.Name())
+
+	_, err = ForInput("supportconfig", inputPath)
+	assert.EqualError(t, err, "unknown layout 'supportconfig', expected one of sosreport, cluster-info-dump, inspect, must-gather or auto")
+}
+
+func TestAddDefaultOmissions(t *testing.T) {
+	pattern := "*.omit"
+	config := &schema.SchemaJson{Config: schema.SchemaJsonConfig{Omit: []schema.Omit{
+		{Type: schema.OmitTypeFile, Pattern: &pattern},
+		kubernetesKind("Secret"),
+	}}}
+
+	require.NoError(t, AddDefaultOmissions(config, &sosReport{}))
+	require.Len(t, config.Config.Omit, 7)
+	assert.Equal(t, config.Config.Omit[2:], (&sosReport{}).DefaultOmissions())
+
+	// the rules are only added once, like when the report of a run is used as the config of the next
+	require.NoError(t, AddDefaultOmissions(config, &sosReport{}))
+	assert.Len(t, config.Config.Omit, 7)
+
+	// the secret rule of the must-gather layout is already part of the config
+	require.NoError(t, AddDefaultOmissions(config, &mustGather{}))
+	assert.Len(t, config.Config.Omit, 7)
+}
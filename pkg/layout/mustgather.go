@@ -0,0 +1,77 @@
+package layout
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/schema"
+)
+
+// mustGather is the layout of `oc adm must-gather`. The files of each gather image are in a folder named after the image, which can also
+// be the root of the bundle: the resources in namespaces/ and cluster-scoped-resources/, the logs of the pods in their namespace folders
+// and the logs of the nodes in host_service_logs/ and nodes/.
+type mustGather struct{}
+
+func (m *mustGather) Name() string {
+	return "must-gather"
+}
+
+// Matches is true for all inputs, the must-gather is the fallback when no other layout matches.
+func (m *mustGather) Matches(string) bool {
+	return true
+}
+
+func (m *mustGather) Classify(path string) Class {
+	if class, ok := classifyResourceOrPodLog(path); ok {
+		return class
+	}
+	segments := segmentsOf(path)
+	name := segments[len(segments)-1]
+	for i := 0; i < len(segments)-1; i++ {
+		if segments[i] == "host_service_logs" {
+			return ClassNodeLog
+		}
+		// nodes/<node>/ also contains information like the cpu affinities, only the journals and logs are node logs
+		if segments[i] == "nodes" && (strings.Contains(name, "journal") || strings.Contains(name, "_logs_") || strings.HasSuffix(name, ".log")) {
+			return ClassNodeLog
+		}
+	}
+	return ClassOther
+}
+
+func (m *mustGather) DefaultOmissions() []schema.Omit {
+	return []schema.Omit{
+		// Resource: the data of secrets can't be obfuscated reliably
+		kubernetesKind("Secret"),
+	}
+}
+
+// inspect is the layout of `oc adm inspect`, which writes the namespaces/ and cluster-scoped-resources/ folders of a must-gather image
+// directly into the root, but no logs of the nodes.
+type inspect struct{}
+
+func (i *inspect) Name() string {
+	return "inspect"
+}
+
+func (i *inspect) Matches(inputPath string) bool {
+	if !isDir(filepath.Join(inputPath, "namespaces")) && !isDir(filepath.Join(inputPath, "cluster-scoped-resources")) {
+		return false
+	}
+	// the root folder of a must-gather image has the same resource folders, it is recognized by the logs of its nodes
+	return !isDir(filepath.Join(inputPath, "host_service_logs")) && !isDir(filepath.Join(inputPath, "nodes"))
+}
+
+func (i *inspect) Classify(path string) Class {
+	if class, ok := classifyResourceOrPodLog(path); ok {
+		return class
+	}
+	return ClassOther
+}
+
+func (i *inspect) DefaultOmissions() []schema.Omit {
+	return []schema.Omit{
+		// Resource: inspecting a namespace or a secret directly includes secrets
+		kubernetesKind("Secret"),
+	}
+}
@@ -0,0 +1,47 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMustGatherClassify(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected Class
+	}{
+		{path: "quay-io-image/namespaces/default/core/pods.yaml", expected: ClassResource},
+		{path: "quay-io-image/cluster-scoped-resources/core/nodes/master-0.yaml", expected: ClassResource},
+		{path: "quay-io-image/namespaces/default/pods/web-0/web/web/logs/current.log", expected: ClassPodLog},
+		{path: "namespaces/default/pods/web-0/web/web/logs/previous.log", expected: ClassPodLog},
+		{path: "quay-io-image/namespaces/default/pods/web-0/web-0.yaml", expected: ClassResource},
+		{path: "quay-io-image/host_service_logs/masters/kubelet_service.log", expected: ClassNodeLog},
+		{path: "quay-io-image/nodes/master-0/master-0_logs_kubelet.gz", expected: ClassNodeLog},
+		{path: "quay-io-image/nodes/master-0/journal", expected: ClassNodeLog},
+		{path: "quay-io-image/nodes/master-0/lscpu", expected: ClassOther},
+		{path: "quay-io-image/etcd_info/endpoint_health.json", expected: ClassResource},
+		{path: "event-filter.html", expected: ClassOther},
+		{path: "timestamp", expected: ClassOther},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, (&mustGather{}).Classify(tc.path))
+		})
+	}
+}
+
+func TestInspectClassify(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected Class
+	}{
+		{path: "namespaces/default/core/secrets.yaml", expected: ClassResource},
+		{path: "namespaces/default/pods/web-0/web/web/logs/current.log", expected: ClassPodLog},
+		{path: "nodes/master-0/journal", expected: ClassOther},
+		{path: "event-filter.html", expected: ClassOther},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, (&inspect{}).Classify(tc.path))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package layout
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+)
+
+// sosFolders are the folders of a sosreport that are written by sos itself, everything else is copied from the host.
+var sosFolders = map[string]struct{}{
+	"sos_commands": {},
+	"sos_logs":     {},
+	"sos_reports":  {},
+	"sos_strings":  {},
+}
+
+// sosReport is the layout of a sosreport of a node. The files of the host are copied into the root, for example etc/ and var/log/, the
+// output of the commands that sos runs is in sos_commands/<plugin>/.
+type sosReport struct{}
+
+func (s *sosReport) Name() string {
+	return "sosreport"
+}
+
+func (s *sosReport) Matches(inputPath string) bool {
+	return isDir(filepath.Join(inputPath, "sos_commands"))
+}
+
+func (s *sosReport) Classify(path string) Class {
+	segments := segmentsOf(path)
+	name := segments[len(segments)-1]
+	if _, ok := sosFolders[segments[0]]; ok {
+		if segments[0] == "sos_commands" && len(segments) > 2 {
+			switch {
+			case segments[1] == "logs" && strings.HasPrefix(name, "journalctl"):
+				return ClassNodeLog
+			case segments[1] == "crio" && strings.HasPrefix(name, "crictl_logs"):
+				return ClassPodLog
+			}
+		}
+		return ClassOther
+	}
+
+	slashPath := strings.Join(segments, "/")
+	switch {
+	case strings.HasPrefix(slashPath, "var/log/pods/") || strings.HasPrefix(slashPath, "var/log/containers/"):
+		return ClassPodLog
+	case strings.HasPrefix(slashPath, "var/log/"):
+		return ClassNodeLog
+	// other yaml and json files of the host, like the configuration of the container runtime, are no kubernetes resources
+	case strings.HasPrefix(slashPath, "etc/kubernetes/") && kube.IsResourceFile(path):
+		return ClassResource
+	}
+	return ClassHostFile
+}
+
+func (s *sosReport) DefaultOmissions() []schema.Omit {
+	return []schema.Omit{
+		// HostFile: password hashes and private keys of the host
+		filePattern("etc/shadow*"),
+		filePattern("etc/gshadow*"),
+		filePattern("etc/ssh/ssh_host_*_key"),
+		filePattern("**/*.key"),
+		// NodeLog: binary journals can't be obfuscated, the journal is also collected as text by sos
+		filePattern("var/log/journal/**"),
+	}
+}
@@ -0,0 +1,33 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSosReportClassify(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected Class
+	}{
+		{path: "etc/hostname", expected: ClassHostFile},
+		{path: "etc/containers/policy.json", expected: ClassHostFile},
+		{path: "etc/kubernetes/manifests/kube-apiserver-pod.yaml", expected: ClassResource},
+		{path: "etc/kubernetes/kubelet-ca.crt", expected: ClassHostFile},
+		{path: "proc/cpuinfo", expected: ClassHostFile},
+		{path: "var/log/messages", expected: ClassNodeLog},
+		{path: "var/log/journal/0123/system.journal", expected: ClassNodeLog},
+		{path: "var/log/pods/default_web-0_0123/web/0.log", expected: ClassPodLog},
+		{path: "var/log/containers/web-0_default_web-0123.log", expected: ClassPodLog},
+		{path: "sos_commands/logs/journalctl_--no-pager", expected: ClassNodeLog},
+		{path: "sos_commands/crio/containers/crictl_logs_-t_0123", expected: ClassPodLog},
+		{path: "sos_commands/crio/containers/crictl_inspect_0123", expected: ClassOther},
+		{path: "sos_commands/networking/ip_-d_address", expected: ClassOther},
+		{path: "sos_reports/manifest.json", expected: ClassOther},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, (&sosReport{}).Classify(tc.path))
+		})
+	}
+}
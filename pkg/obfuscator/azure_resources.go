@@ -74,6 +74,7 @@ type azureResourceObfuscator struct {
 
 	// we always check all of them because more than one can match a line, but they are evaluated in order because some are more specific than others.
 	orderedPartialRegexReplacers []*partialRegexReplacer
+	petNameGen                   *PetNameGenerator
 }
 
 func (o *azureResourceObfuscator) Path(s string) string {
@@ -221,5 +222,6 @@ func NewAzureResourceObfuscator(replacementType schema.ObfuscateReplacementType,
 	return &azureResourceObfuscator{
 		ReplacementTracker:           tracker,
 		orderedPartialRegexReplacers: orderedPartialRegexReplacers,
+		petNameGen:                   petNameGen,
 	}, nil
 }
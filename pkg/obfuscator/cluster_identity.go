@@ -0,0 +1,205 @@
+package obfuscator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// shorter identities are likely to be found as substrings of unrelated words
+	minClusterIdentityLength = 5
+
+	maximumSupportedClusterIdentities = 9999999999
+)
+
+type clusterIdentityKind string
+
+const (
+	identityInfrastructureName clusterIdentityKind = "infrastructure name"
+	identityAPIServer          clusterIdentityKind = "api server"
+	identityClusterID          clusterIdentityKind = "cluster id"
+	identityBaseDomain         clusterIdentityKind = "base domain"
+	identityNode               clusterIdentityKind = "node"
+)
+
+// clusterIdentityTemplates contains the consistent template and the static replacement for each kind of identity.
+var clusterIdentityTemplates = map[clusterIdentityKind][2]string{
+	identityInfrastructureName: {"x-infra-id-%010d-x", "obfuscated-infra-id"},
+	identityAPIServer:          {"x-api-server-%010d-x", "obfuscated-api-server"},
+	identityClusterID:          {"x-cluster-id-%010d-x", "obfuscated-cluster-id"},
+	identityBaseDomain:         {"x-base-domain-%010d-x", "obfuscated-base-domain"},
+	identityNode:               {"x-node-%010d-x", "obfuscated-node"},
+}
+
+type clusterIdentityObfuscator struct {
+	ReplacementTracker
+
+	lock       sync.RWMutex
+	identities map[string]clusterIdentityKind
+	// sorted contains the keys of identities, longest first, so that e.g. the api server host is replaced before the base domain it contains
+	sorted     []string
+	generators map[clusterIdentityKind]*generator
+	// matcher finds all sorted identities, kinds are the kinds of its patterns. Both are created on first use after the identities changed.
+	matcher *literalMatcher
+	kinds   []clusterIdentityKind
+}
+
+func (c *clusterIdentityObfuscator) Path(s string) string {
+	return c.replace(s)
+}
+
+func (c *clusterIdentityObfuscator) Contents(s string) string {
+	return c.replace(s)
+}
+
+// ScanKubernetesResource learns the identities of the cluster from the Infrastructure, ClusterVersion, DNS and Node resources.
+func (c *clusterIdentityObfuscator) ScanKubernetesResource(resource kube.Object) {
+	switch resource.Kind() {
+	case "Infrastructure":
+		if resource.Name() != "cluster" {
+			return
+		}
+		c.add(resource.NestedString("status", "infrastructureName"), identityInfrastructureName)
+		c.add(resource.NestedURLHost("status", "apiServerURL"), identityAPIServer)
+		c.add(resource.NestedURLHost("status", "apiServerInternalURI"), identityAPIServer)
+	case "ClusterVersion":
+		if resource.Name() != "version" {
+			return
+		}
+		c.add(resource.NestedString("spec", "clusterID"), identityClusterID)
+	case "DNS":
+		if resource.Name() != "cluster" {
+			return
+		}
+		c.add(strings.TrimSuffix(resource.NestedString("spec", "baseDomain"), "."), identityBaseDomain)
+	case "Node":
+		c.add(resource.Name(), identityNode)
+	}
+}
+
+func (c *clusterIdentityObfuscator) add(identity string, kind clusterIdentityKind) {
+	if identity == "" {
+		return
+	}
+	if len(identity) < minClusterIdentityLength {
+		klog.Warningf("cluster identity obfuscator will skip %s '%s' because it's too short", kind, identity)
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.identities[identity]; ok {
+		return
+	}
+	klog.V(2).Infof("discovered %s '%s'", kind, identity)
+	c.identities[identity] = kind
+	c.matcher = nil
+	c.sorted = append(c.sorted, identity)
+	sort.Slice(c.sorted, func(i, j int) bool {
+		if len(c.sorted[i]) != len(c.sorted[j]) {
+			return len(c.sorted[i]) > len(c.sorted[j])
+		}
+		return c.sorted[i] < c.sorted[j]
+	})
+}
+
+func (c *clusterIdentityObfuscator) replace(s string) string {
+	return replaceMatches(s, c.matches(s))
+}
+
+// matches returns all occurrences of the identities, the longest identity is replaced where identities overlap.
+func (c *clusterIdentityObfuscator) matches(input string) []match {
+	matcher, kinds := c.literalMatcher()
+	occurrences := matcher.leftmostLongest(input, nil)
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	matches := make([]match, len(occurrences))
+	for i, occurrence := range occurrences {
+		identity := matcher.patterns[occurrence.pattern]
+		g := c.generators[kinds[occurrence.pattern]]
+		// the longest identities are numbered first
+		matches[i] = match{start: occurrence.start, end: occurrence.end, order: occurrence.pattern, replacement: func() string {
+			return g.generateReplacement(identity, identity, 1, c.ReplacementTracker)
+		}}
+	}
+	return matches
+}
+
+// literalMatcher returns the matcher of the currently known identities together with their kinds.
+func (c *clusterIdentityObfuscator) literalMatcher() (*literalMatcher, []clusterIdentityKind) {
+	c.lock.RLock()
+	matcher, kinds := c.matcher, c.kinds
+	c.lock.RUnlock()
+	if matcher != nil {
+		return matcher, kinds
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.matcher == nil {
+		c.kinds = make([]clusterIdentityKind, len(c.sorted))
+		for i, identity := range c.sorted {
+			c.kinds[i] = c.identities[identity]
+		}
+		c.matcher = newLiteralMatcher(append([]string(nil), c.sorted...))
+	}
+	return c.matcher, c.kinds
+}
+
+// NewClusterIdentityObfuscator creates an obfuscator that replaces the identities of the cluster it learned by scanning the kubernetes resources
+// of the input during the prescan.
+func NewClusterIdentityObfuscator(replacementType schema.ObfuscateReplacementType, tracker ReplacementTracker) (ScanningObfuscator, error) {
+	generators := map[clusterIdentityKind]*generator{}
+	for kind, t := range clusterIdentityTemplates {
+		g, err := newGenerator(t[0], t[1], maximumSupportedClusterIdentities, replacementType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create generator for %s: %w", kind, err)
+		}
+		generators[kind] = g
+	}
+
+	return &clusterIdentityObfuscator{
+		ReplacementTracker: tracker,
+		identities:         map[string]clusterIdentityKind{},
+		generators:         generators,
+	}, nil
+}
+
+type scanOnlyObfuscator struct {
+	scanner KubernetesResourceScanner
+}
+
+func (s *scanOnlyObfuscator) Path(input string) string {
+	return input
+}
+
+func (s *scanOnlyObfuscator) Contents(input string) string {
+	return input
+}
+
+func (s *scanOnlyObfuscator) matches(string) []match {
+	return nil
+}
+
+func (s *scanOnlyObfuscator) Report() ReplacementReport {
+	return ReplacementReport{}
+}
+
+func (s *scanOnlyObfuscator) ScanKubernetesResource(resource kube.Object) {
+	s.scanner.ScanKubernetesResource(resource)
+}
+
+// NewScanOnlyObfuscator wraps the scanner for the prescan, it only forwards the scanned kubernetes resources and leaves all input unchanged.
+// This avoids counting replacements twice when the same obfuscator is also used for the final pass.
+func NewScanOnlyObfuscator(scanner KubernetesResourceScanner) ScanningObfuscator {
+	return &scanOnlyObfuscator{scanner: scanner}
+}
@@ -0,0 +1,136 @@
+package obfuscator
+
+import (
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var clusterIdentityResources = []kube.Object{
+	{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "Infrastructure",
+		"metadata":   map[string]interface{}{"name": "cluster"},
+		"status": map[string]interface{}{
+			"infrastructureName":   "mycluster-x7k2p",
+			"apiServerURL":         "https://api.mycluster.example.com:6443",
+			"apiServerInternalURI": "https://api-int.mycluster.example.com:6443",
+		},
+	},
+	{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "ClusterVersion",
+		"metadata":   map[string]interface{}{"name": "version"},
+		"spec":       map[string]interface{}{"clusterID": "0b2d5e4a-1f3c-4d6e-9a8b-7c6d5e4f3a2b"},
+	},
+	{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "DNS",
+		"metadata":   map[string]interface{}{"name": "cluster"},
+		"spec":       map[string]interface{}{"baseDomain": "example.com"},
+	},
+	{
+		"apiVersion": "v1",
+		"kind":       "Node",
+		"metadata":   map[string]interface{}{"name": "mycluster-x7k2p-master-0"},
+	},
+	{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "DNS",
+		"metadata":   map[string]interface{}{"name": "other"},
+		"spec":       map[string]interface{}{"baseDomain": "ignored.com"},
+	},
+	{
+		"apiVersion": "v1",
+		"kind":       "Node",
+		"metadata":   map[string]interface{}{"name": "node"},
+	},
+}
+
+func TestClusterIdentityObfuscatorContents(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		replacementType schema.ObfuscateReplacementType
+		input           string
+		output          string
+		report          map[string]string
+	}{
+		{
+			name:            "nothing to replace",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			input:           "ignored.com and node are not replaced",
+			output:          "ignored.com and node are not replaced",
+			report:          map[string]string{},
+		},
+		{
+			name:            "node containing the infrastructure name",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			input:           "node mycluster-x7k2p-master-0 of mycluster-x7k2p",
+			output:          "node x-node-0000000001-x of x-infra-id-0000000001-x",
+			report: map[string]string{
+				"mycluster-x7k2p-master-0": "x-node-0000000001-x",
+				"mycluster-x7k2p":          "x-infra-id-0000000001-x",
+			},
+		},
+		{
+			name:            "api server urls before the base domain",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			input:           "server: https://api.mycluster.example.com:6443 and https://api-int.mycluster.example.com:6443 on console.apps.example.com",
+			output:          "server: https://x-api-server-0000000002-x:6443 and https://x-api-server-0000000001-x:6443 on console.apps.x-base-domain-0000000001-x",
+			report: map[string]string{
+				"api-int.mycluster.example.com": "x-api-server-0000000001-x",
+				"api.mycluster.example.com":     "x-api-server-0000000002-x",
+				"example.com":                   "x-base-domain-0000000001-x",
+			},
+		},
+		{
+			name:            "static cluster id",
+			replacementType: schema.ObfuscateReplacementTypeStatic,
+			input:           "clusterID: 0b2d5e4a-1f3c-4d6e-9a8b-7c6d5e4f3a2b",
+			output:          "clusterID: obfuscated-cluster-id",
+			report: map[string]string{
+				"0b2d5e4a-1f3c-4d6e-9a8b-7c6d5e4f3a2b": "obfuscated-cluster-id",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := NewClusterIdentityObfuscator(tc.replacementType, NewSimpleTracker())
+			require.NoError(t, err)
+			for _, r := range clusterIdentityResources {
+				o.ScanKubernetesResource(r)
+			}
+			assert.Equal(t, tc.output, o.Contents(tc.input))
+			assert.Equal(t, tc.report, o.Report().AsMap())
+		})
+	}
+}
+
+func TestClusterIdentityObfuscatorPath(t *testing.T) {
+	o, err := NewClusterIdentityObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(t, err)
+	for _, r := range clusterIdentityResources {
+		o.ScanKubernetesResource(r)
+	}
+	assert.Equal(t, "nodes/x-node-0000000001-x/x-node-0000000001-x.yaml", o.Path("nodes/mycluster-x7k2p-master-0/mycluster-x7k2p-master-0.yaml"))
+}
+
+func TestClusterIdentityObfuscatorInvalidReplacementType(t *testing.T) {
+	_, err := NewClusterIdentityObfuscator("Random", NewSimpleTracker())
+	require.Error(t, err)
+}
+
+func TestScanOnlyObfuscator(t *testing.T) {
+	o, err := NewClusterIdentityObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(t, err)
+	scanOnly := NewScanOnlyObfuscator(o)
+	for _, r := range clusterIdentityResources {
+		scanOnly.ScanKubernetesResource(r)
+	}
+
+	assert.Equal(t, "mycluster-x7k2p", scanOnly.Contents("mycluster-x7k2p"))
+	assert.Empty(t, scanOnly.Report().Replacements)
+	assert.Equal(t, "x-infra-id-0000000001-x", o.Contents("mycluster-x7k2p"))
+}
@@ -10,7 +10,8 @@ import (
 )
 
 const (
-	domainPattern                      = `([a-zA-Z0-9\.]*\.)?(%s)`
+	// the subdomain is matched lazily, so that the most specific of the domain names is preferred
+	domainPattern                      = `([a-zA-Z0-9\.]*?\.)?(%s)`
 	obfuscatedTemplate                 = "domain%010d"
 	staticDomainReplacement            = "obfuscated.com"
 	maximumSupportedObfuscationDomains = 9999999999
@@ -18,8 +19,10 @@ const (
 
 type domainObfuscator struct {
 	ReplacementTracker
-	domainPatterns []*regexp.Regexp
-	obfsGenerator  generator
+	// domainPattern matches all domain names at once, it only runs on inputs that contain one of the domains
+	domainPattern *regexp.Regexp
+	domains       *literalMatcher
+	obfsGenerator generator
 }
 
 func (d *domainObfuscator) Path(s string) string {
@@ -31,47 +34,45 @@ func (d *domainObfuscator) Contents(s string) string {
 }
 
 func (d *domainObfuscator) replaceDomains(input string) string {
-	output := input
-	for _, p := range d.domainPatterns {
-		matches := p.FindAllStringSubmatch(output, -1)
-		for _, m := range matches {
-			if len(m) != 3 {
-				continue
-			}
-			baseDomain := m[2]
-			subDomain := m[1]
-			obfuscatedBaseDomain := d.obfsGenerator.generateReplacement(baseDomain, m[0], 1, d.ReplacementTracker)
-			var replacement string
-			if subDomain != "" {
-				replacement = fmt.Sprintf("%s%s", subDomain, obfuscatedBaseDomain)
-			} else {
-				replacement = obfuscatedBaseDomain
-			}
-			// TODO(thomas): should just replace that one matching occurrence instead of all
-			output = strings.ReplaceAll(output, m[0], replacement)
+	return replaceMatches(input, d.matches(input))
+}
+
+func (d *domainObfuscator) matches(input string) []match {
+	if !d.domains.contains(input) {
+		return nil
+	}
+	var matches []match
+	for _, loc := range d.domainPattern.FindAllStringSubmatchIndex(input, -1) {
+		original := input[loc[0]:loc[1]]
+		baseDomain := input[loc[4]:loc[5]]
+		subDomain := ""
+		if loc[2] >= 0 {
+			subDomain = input[loc[2]:loc[3]]
 		}
+		matches = append(matches, match{start: loc[0], end: loc[1], replacement: func() string {
+			return subDomain + d.obfsGenerator.generateReplacement(baseDomain, original, 1, d.ReplacementTracker)
+		}})
 	}
-	return output
+	return matches
 }
 
 func NewDomainObfuscator(domains []string, replacementType schema.ObfuscateReplacementType, tracker ReplacementTracker) (ReportingObfuscator, error) {
 	if len(domains) == 0 {
 		return nil, fmt.Errorf("no domainNames supplied for the obfuscation type: Domain")
 	}
-	patterns := make([]*regexp.Regexp, len(domains))
+	escaped := make([]string, len(domains))
 	for i, d := range domains {
-		dd := strings.ReplaceAll(d, ".", "\\.")
-		p, err := regexp.Compile(fmt.Sprintf(domainPattern, dd))
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate regex for domain %s: %w", d, err)
-		}
-		patterns[i] = p
+		escaped[i] = strings.ReplaceAll(d, ".", "\\.")
 	}
 
-	// we are sorting descending to always match the most specific pattern first
-	sort.Slice(patterns, func(i, j int) bool {
-		return len(patterns[i].String()) > len(patterns[j].String())
+	// we are sorting descending to always match the most specific domain first
+	sort.SliceStable(escaped, func(i, j int) bool {
+		return len(escaped[i]) > len(escaped[j])
 	})
+	pattern, err := regexp.Compile(fmt.Sprintf(domainPattern, strings.Join(escaped, "|")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate regex for domains %v: %w", domains, err)
+	}
 
 	// creating a new generator object
 	generator, err := newGenerator(obfuscatedTemplate, staticDomainReplacement, maximumSupportedObfuscationDomains, replacementType)
@@ -80,7 +81,8 @@ func NewDomainObfuscator(domains []string, replacementType schema.ObfuscateRepla
 	}
 	return &domainObfuscator{
 		ReplacementTracker: tracker,
-		domainPatterns:     patterns,
+		domainPattern:      pattern,
+		domains:            newLiteralMatcher(domains),
 		obfsGenerator:      *generator,
 	}, nil
 }
@@ -1,14 +1,13 @@
 package obfuscator
 
 import (
-	"strings"
-
 	"github.com/openshift/must-gather-clean/pkg/schema"
 )
 
 type exactObfuscator struct {
 	ReplacementTracker
 	exactReplacements []ExactReplacement
+	matcher           *literalMatcher
 }
 
 type ExactReplacement struct {
@@ -25,24 +24,38 @@ func (r *exactObfuscator) Contents(s string) string {
 }
 
 func (r *exactObfuscator) replace(input string) string {
-	output := input
-	for _, e := range r.exactReplacements {
-		output = strings.ReplaceAll(output, e.Original, e.Replacement)
-	}
+	return replaceMatches(input, r.matches(input))
+}
 
-	return output
+// matches returns the occurrences of all originals, the longest original is replaced where originals overlap.
+func (r *exactObfuscator) matches(input string) []match {
+	occurrences := r.matcher.leftmostLongest(input, nil)
+	if len(occurrences) == 0 {
+		return nil
+	}
+	matches := make([]match, len(occurrences))
+	for i, occurrence := range occurrences {
+		replacement := r.exactReplacements[occurrence.pattern].Replacement
+		matches[i] = match{start: occurrence.start, end: occurrence.end, replacement: func() string {
+			return replacement
+		}}
+	}
+	return matches
 }
 
 func NewExactReplacementObfuscator(exactReplacements []schema.ObfuscateExactReplacementsElem, tracker ReplacementTracker) ReportingObfuscator {
 	ret := &exactObfuscator{
 		ReplacementTracker: tracker,
 	}
+	var originals []string
 	for _, curr := range exactReplacements {
 		ret.exactReplacements = append(ret.exactReplacements, ExactReplacement{
 			Original:    curr.Original,
 			Replacement: curr.Replacement,
 		})
+		originals = append(originals, curr.Original)
 	}
+	ret.matcher = newLiteralMatcher(originals)
 
 	return ret
 }
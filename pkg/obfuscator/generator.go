@@ -2,6 +2,7 @@ package obfuscator
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/openshift/must-gather-clean/pkg/schema"
 	"k8s.io/klog/v2"
@@ -9,24 +10,27 @@ import (
 
 // generator consists of the required fields for the consistent,static obfuscations and the count of the obfuscations
 // This implements the methods static, consistent inorder to return the required replacement based on the replacementType
-// This implementation is intentionally not thread-safe, but should be used under the locking of ReplacementTracker.GenerateIfAbsent to ensure
-// the results are correct across goroutines.
+// A generator is shared by all workers and chunk workers that obfuscate concurrently. The count is only changed atomically, so every consistent
+// replacement gets its own number and the count can be saved and restored while replacements are generated. The generator is called from
+// ReplacementTracker.GenerateIfAbsent, whose locking makes sure that an original gets a single replacement even when several goroutines
+// find it at once.
 type generator struct {
-	template        string
-	static          string
-	count           int
+	template string
+	static   string
+	// count is the number of the last consistent replacement, it is only accessed atomically
+	count           int64
 	max             int
 	exitFunc        func(string, int)
 	replacementType schema.ObfuscateReplacementType
 }
 
 func (g *generator) generateConsistentReplacement() string {
-	g.count++
-	if g.count > g.max {
+	count := atomic.AddInt64(&g.count, 1)
+	if count > int64(g.max) {
 		g.exitFunc(g.template, g.max)
 		return ""
 	}
-	r := fmt.Sprintf(g.template, g.count)
+	r := fmt.Sprintf(g.template, count)
 	return r
 }
 
@@ -46,6 +50,25 @@ func (g *generator) generateReplacement(key string, original string, count uint,
 	return replacement
 }
 
+// generateReplacementWithSuffix works like generateReplacement, but appends the suffix to a newly generated replacement
+func (g *generator) generateReplacementWithSuffix(key string, original string, suffix string, count uint, tracker ReplacementTracker) string {
+	if suffix == "" {
+		return g.generateReplacement(key, original, count, tracker)
+	}
+	var replacement string
+	switch g.replacementType {
+	case schema.ObfuscateReplacementTypeStatic:
+		replacement = tracker.GenerateIfAbsent(key, original, count, func() string {
+			return g.generateStaticReplacement() + suffix
+		})
+	case schema.ObfuscateReplacementTypeConsistent:
+		replacement = tracker.GenerateIfAbsent(key, original, count, func() string {
+			return g.generateConsistentReplacement() + suffix
+		})
+	}
+	return replacement
+}
+
 // newGenerator creates a generator objects and populates with the provided arguments
 func newGenerator(template, static string, maxSupported int, replacementType schema.ObfuscateReplacementType) (*generator, error) {
 	if replacementType != schema.ObfuscateReplacementTypeStatic && replacementType != schema.ObfuscateReplacementTypeConsistent {
@@ -0,0 +1,185 @@
+package obfuscator
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"k8s.io/klog/v2"
+)
+
+const (
+	hostnameTemplate                     = "x-host-%010d-x"
+	staticHostnameReplacement            = "obfuscated-host"
+	maximumSupportedObfuscationHostnames = 9999999999
+	// shorter hostnames are likely to be found as substrings of unrelated words
+	minHostnameLength = 5
+)
+
+var (
+	hostnameRolePattern = regexp.MustCompile(`(?:^|-)(master|worker|infra|control-plane|compute|arbiter)(?:-|$)`)
+	// matches availability zones like us-east-1a (AWS) or us-central1-a (GCP)
+	hostnameZonePattern = regexp.MustCompile(`(?:^|-)([a-z]{2}-[a-z]+-[0-9]+[a-z]|[a-z]{2}-[a-z]+[0-9]+-[a-z])(?:-|$)`)
+	// the address types of nodes and machines that contain hostnames rather than IPs
+	hostnameAddressTypes = map[string]struct{}{
+		"Hostname":    {},
+		"InternalDNS": {},
+		"ExternalDNS": {},
+	}
+)
+
+type hostnameObfuscator struct {
+	ReplacementTracker
+	keepStructure bool
+	obfsGenerator generator
+
+	lock      sync.RWMutex
+	hostnames map[string]struct{}
+	// sorted contains the keys of hostnames, longest first, so that e.g. "worker-10" is replaced before "worker-1"
+	sorted []string
+	// matcher finds all sorted hostnames, it is created on first use after the hostnames changed
+	matcher *literalMatcher
+}
+
+func (h *hostnameObfuscator) Path(s string) string {
+	return h.replace(s)
+}
+
+func (h *hostnameObfuscator) Contents(s string) string {
+	return h.replace(s)
+}
+
+// ScanKubernetesResource learns the hostnames from the Node, Machine and BareMetalHost resources.
+func (h *hostnameObfuscator) ScanKubernetesResource(resource kube.Object) {
+	switch resource.Kind() {
+	case "Node":
+		h.add(resource.Name())
+		h.addAddresses(resource.NestedObjects("status", "addresses"))
+	case "Machine":
+		h.add(resource.Name())
+		h.add(resource.NestedString("status", "nodeRef", "name"))
+		h.addAddresses(resource.NestedObjects("status", "addresses"))
+	case "BareMetalHost":
+		h.add(resource.Name())
+		h.add(resource.NestedString("status", "hardware", "hostname"))
+	}
+}
+
+func (h *hostnameObfuscator) addAddresses(addresses []kube.Object) {
+	for _, a := range addresses {
+		if _, ok := hostnameAddressTypes[a.NestedString("type")]; ok {
+			h.add(a.NestedString("address"))
+		}
+	}
+}
+
+// add records the first label of the hostname, the remaining domain is left to the Domain obfuscator.
+func (h *hostnameObfuscator) add(hostname string) {
+	hostname = strings.SplitN(hostname, ".", 2)[0]
+	if hostname == "" {
+		return
+	}
+	if len(hostname) < minHostnameLength {
+		klog.Warningf("hostname obfuscator will skip '%s' because it's too short", hostname)
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.hostnames[hostname]; ok {
+		return
+	}
+	klog.V(2).Infof("discovered hostname '%s'", hostname)
+	h.hostnames[hostname] = struct{}{}
+	h.matcher = nil
+	h.sorted = append(h.sorted, hostname)
+	sort.Slice(h.sorted, func(i, j int) bool {
+		if len(h.sorted[i]) != len(h.sorted[j]) {
+			return len(h.sorted[i]) > len(h.sorted[j])
+		}
+		return h.sorted[i] < h.sorted[j]
+	})
+}
+
+func (h *hostnameObfuscator) replace(s string) string {
+	return replaceMatches(s, h.matches(s))
+}
+
+// matches returns all occurrences of the hostnames that are not part of a longer alphanumeric word, the longest hostname is replaced where
+// hostnames overlap. Dashes are not treated as boundaries, since hostnames are commonly embedded in the names of static pods.
+func (h *hostnameObfuscator) matches(input string) []match {
+	matcher := h.literalMatcher()
+	occurrences := matcher.leftmostLongest(input, func(o literalOccurrence) bool {
+		return (o.start == 0 || !isAlphanumeric(input[o.start-1])) && (o.end == len(input) || !isAlphanumeric(input[o.end]))
+	})
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	matches := make([]match, len(occurrences))
+	for i, occurrence := range occurrences {
+		hostname := matcher.patterns[occurrence.pattern]
+		// the longest hostnames are numbered first
+		matches[i] = match{start: occurrence.start, end: occurrence.end, order: occurrence.pattern, replacement: func() string {
+			suffix := ""
+			if h.keepStructure {
+				suffix = hostnameStructure(hostname)
+			}
+			return h.obfsGenerator.generateReplacementWithSuffix(hostname, hostname, suffix, 1, h.ReplacementTracker)
+		}}
+	}
+	return matches
+}
+
+// literalMatcher returns the matcher of the currently known hostnames.
+func (h *hostnameObfuscator) literalMatcher() *literalMatcher {
+	h.lock.RLock()
+	matcher := h.matcher
+	h.lock.RUnlock()
+	if matcher != nil {
+		return matcher
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.matcher == nil {
+		h.matcher = newLiteralMatcher(append([]string(nil), h.sorted...))
+	}
+	return h.matcher
+}
+
+// hostnameStructure returns the role and the availability zone found in the hostname as a suffix, for example "-worker-us-east-1a".
+func hostnameStructure(hostname string) string {
+	var suffix string
+	if m := hostnameRolePattern.FindStringSubmatch(hostname); m != nil {
+		suffix += "-" + m[1]
+	}
+	if m := hostnameZonePattern.FindStringSubmatch(hostname); m != nil {
+		suffix += "-" + m[1]
+	}
+	return suffix
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// NewHostnameObfuscator creates an obfuscator that replaces the hostnames it learned by scanning the kubernetes resources of the input during
+// the prescan. With keepStructure, the role and availability zone of a hostname are appended to its replacement.
+func NewHostnameObfuscator(replacementType schema.ObfuscateReplacementType, keepStructure bool, tracker ReplacementTracker) (ScanningObfuscator, error) {
+	g, err := newGenerator(hostnameTemplate, staticHostnameReplacement, maximumSupportedObfuscationHostnames, replacementType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hostnameObfuscator{
+		ReplacementTracker: tracker,
+		keepStructure:      keepStructure,
+		obfsGenerator:      *g,
+		hostnames:          map[string]struct{}{},
+	}, nil
+}
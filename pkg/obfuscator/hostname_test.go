@@ -0,0 +1,143 @@
+package obfuscator
+
+import (
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var hostnameResources = []kube.Object{
+	{
+		"apiVersion": "v1",
+		"kind":       "Node",
+		"metadata":   map[string]interface{}{"name": "ip-10-0-1-23.ec2.internal"},
+		"status": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "InternalIP", "address": "10.0.1.23"},
+				map[string]interface{}{"type": "Hostname", "address": "ip-10-0-1-23.ec2.internal"},
+				map[string]interface{}{"type": "InternalDNS", "address": "ip-10-0-1-24.ec2.internal"},
+			},
+		},
+	},
+	{
+		"apiVersion": "machine.openshift.io/v1beta1",
+		"kind":       "Machine",
+		"metadata":   map[string]interface{}{"name": "mycluster-x7k2p-worker-us-east-1a-abcde"},
+		"status": map[string]interface{}{
+			"nodeRef": map[string]interface{}{"name": "worker-1"},
+		},
+	},
+	{
+		"apiVersion": "metal3.io/v1alpha1",
+		"kind":       "BareMetalHost",
+		"metadata":   map[string]interface{}{"name": "openshift-master-0"},
+		"status": map[string]interface{}{
+			"hardware": map[string]interface{}{"hostname": "master-0.lab.example.com"},
+		},
+	},
+	{
+		"apiVersion": "v1",
+		"kind":       "Node",
+		"metadata":   map[string]interface{}{"name": "node"},
+	},
+}
+
+func TestHostnameObfuscatorContents(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		replacementType schema.ObfuscateReplacementType
+		keepStructure   bool
+		input           string
+		output          string
+		report          map[string]string
+	}{
+		{
+			name:            "nothing to replace",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			input:           "node ip-10-0-1-230 is not known",
+			output:          "node ip-10-0-1-230 is not known",
+			report:          map[string]string{},
+		},
+		{
+			name:            "domain is kept",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			input:           "ip-10-0-1-23.ec2.internal and ip-10-0-1-24",
+			output:          "x-host-0000000001-x.ec2.internal and x-host-0000000002-x",
+			report: map[string]string{
+				"ip-10-0-1-23": "x-host-0000000001-x",
+				"ip-10-0-1-24": "x-host-0000000002-x",
+			},
+		},
+		{
+			name:            "static pod names",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			input:           "kube-apiserver-master-0 on master-0.lab.example.com",
+			output:          "kube-apiserver-x-host-0000000001-x on x-host-0000000001-x.lab.example.com",
+			report: map[string]string{
+				"master-0": "x-host-0000000001-x",
+			},
+		},
+		{
+			name:            "keep structure",
+			replacementType: schema.ObfuscateReplacementTypeConsistent,
+			keepStructure:   true,
+			input:           "machine mycluster-x7k2p-worker-us-east-1a-abcde runs worker-1, bmh openshift-master-0",
+			output:          "machine x-host-0000000001-x-worker-us-east-1a runs x-host-0000000003-x-worker, bmh x-host-0000000002-x-master",
+			report: map[string]string{
+				"mycluster-x7k2p-worker-us-east-1a-abcde": "x-host-0000000001-x-worker-us-east-1a",
+				"openshift-master-0":                      "x-host-0000000002-x-master",
+				"worker-1":                                "x-host-0000000003-x-worker",
+			},
+		},
+		{
+			name:            "static",
+			replacementType: schema.ObfuscateReplacementTypeStatic,
+			input:           "worker-1 and openshift-master-0",
+			output:          "obfuscated-host and obfuscated-host",
+			report: map[string]string{
+				"worker-1":           "obfuscated-host",
+				"openshift-master-0": "obfuscated-host",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := NewHostnameObfuscator(tc.replacementType, tc.keepStructure, NewSimpleTracker())
+			require.NoError(t, err)
+			for _, r := range hostnameResources {
+				o.ScanKubernetesResource(r)
+			}
+			assert.Equal(t, tc.output, o.Contents(tc.input))
+			assert.Equal(t, tc.report, o.Report().AsMap())
+		})
+	}
+}
+
+func TestHostnameObfuscatorPath(t *testing.T) {
+	o, err := NewHostnameObfuscator(schema.ObfuscateReplacementTypeConsistent, false, NewSimpleTracker())
+	require.NoError(t, err)
+	for _, r := range hostnameResources {
+		o.ScanKubernetesResource(r)
+	}
+	assert.Equal(t, "nodes/x-host-0000000001-x.ec2.internal/x-host-0000000001-x.ec2.internal.yaml", o.Path("nodes/ip-10-0-1-23.ec2.internal/ip-10-0-1-23.ec2.internal.yaml"))
+}
+
+func TestHostnameStructure(t *testing.T) {
+	for input, expected := range map[string]string{
+		"ip-10-0-1-23":                           "",
+		"mycluster-x7k2p-master-0":               "-master",
+		"mycluster-x7k2p-worker-us-east-1a-abcd": "-worker-us-east-1a",
+		"mycluster-x7k2p-worker-c-abcde":         "-worker",
+		"mycluster-x7k2p-infra-us-central1-a":    "-infra-us-central1-a",
+		"control-plane-1":                        "-control-plane",
+	} {
+		assert.Equal(t, expected, hostnameStructure(input), input)
+	}
+}
+
+func TestHostnameObfuscatorInvalidReplacementType(t *testing.T) {
+	_, err := NewHostnameObfuscator("Random", false, NewSimpleTracker())
+	require.Error(t, err)
+}
@@ -26,6 +26,8 @@ var (
 	ipv6re      = `(([a-f0-9]{0,4}[:]){1,8}([0-9a-fA-F]{1,4}|::)+)`
 	ipv6Pattern = regexp.MustCompile(ipv6re)
 	ipv4Pattern = regexp.MustCompile(ipv4re)
+	ipv4Filter  = newRunFilter(digits+"._-", "._-", len("1.1.1.1"))
+	ipv6Filter  = newRunFilter(hexDigits+":", ":", len(":a"))
 	excludedIPs = map[string]struct{}{
 		"127.0.0.1": {},
 		"0.0.0.0":   {},
@@ -40,6 +42,7 @@ type ipObfuscator struct {
 
 type replacementGenerator struct {
 	pattern   *regexp.Regexp
+	filter    *runFilter
 	generator *generator
 }
 
@@ -52,25 +55,34 @@ func (o *ipObfuscator) Contents(s string) string {
 }
 
 func (o *ipObfuscator) replace(s string) string {
-	output := s
+	return replaceMatches(s, o.matches(s))
+}
 
-	for _, r := range o.replacements {
-		ipMatches := r.pattern.FindAllString(output, -1)
-		for _, m := range ipMatches {
-			// if the match is in the exclude-list then do not replace.
-			if _, ok := excludedIPs[m]; ok {
-				continue
-			}
+// matches returns the IPv4 and IPv6 addresses of the input, the IPv4 addresses win where both overlap, for example in "::ffff:10.0.0.1".
+func (o *ipObfuscator) matches(input string) []match {
+	matchesByPattern := make([][]match, len(o.replacements))
+	for i := range o.replacements {
+		matchesByPattern[i] = o.replacements[i].matches(input, o.ReplacementTracker)
+	}
+	return resolveOverlaps(matchesByPattern...)
+}
 
-			cleaned := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(m, "_", "."), "-", "."))
-			if ip := net.ParseIP(cleaned); ip != nil {
-				replacement := r.generator.generateReplacement(cleaned, m, 1, o.ReplacementTracker)
-				// TODO(thomas): should just replace that one matching occurrence instead of all
-				output = strings.ReplaceAll(output, m, replacement)
-			}
+// matches returns the addresses of the pattern in the input.
+func (r *replacementGenerator) matches(input string, tracker ReplacementTracker) []match {
+	return regexMatches(r.pattern, r.filter, input, func(m string) func() string {
+		// if the match is in the exclude-list then do not replace.
+		if _, ok := excludedIPs[m]; ok {
+			return nil
 		}
-	}
-	return output
+
+		cleaned := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(m, "_", "."), "-", "."))
+		if ip := net.ParseIP(cleaned); ip == nil {
+			return nil
+		}
+		return func() string {
+			return r.generator.generateReplacement(cleaned, m, 1, tracker)
+		}
+	})
 }
 
 func NewIPObfuscator(replacementType schema.ObfuscateReplacementType, tracker ReplacementTracker) (ReportingObfuscator, error) {
@@ -85,8 +97,8 @@ func NewIPObfuscator(replacementType schema.ObfuscateReplacementType, tracker Re
 	return &ipObfuscator{
 		ReplacementTracker: tracker,
 		replacements: []replacementGenerator{
-			{pattern: ipv4Pattern, generator: genIPv4},
-			{pattern: ipv6Pattern, generator: genIPv6},
+			{pattern: ipv4Pattern, filter: ipv4Filter, generator: genIPv4},
+			{pattern: ipv6Pattern, filter: ipv6Filter, generator: genIPv6},
 		},
 	}, nil
 }
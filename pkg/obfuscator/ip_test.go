@@ -332,16 +332,12 @@ func TestIPv6CanonicalKeyConsistent(t *testing.T) {
 		{
 			name:   "mixed ipv4 and ipv6 logline",
 			input:  "2021-08-03T09:35:59.743794348Z ::ffff:10.130.0.1 - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:10.130.0.1 - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:10.130.0.1 - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:10.130.0.1 - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:10.130.0.1 - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:10.130.0.1 - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 -",
-			output: "2021-08-03T09:35:59.743794348Z x-ipv6-0000000001-x:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z x-ipv6-0000000001-x:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z x-ipv6-0000000001-x:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z x-ipv6-0000000001-x:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z x-ipv6-0000000001-x:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z x-ipv6-0000000001-x:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 -",
+			output: "2021-08-03T09:35:59.743794348Z ::ffff:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 - 2021-08-03T09:35:59.743794348Z ::ffff:x-ipv4-0000000001-x - - [03/Aug/2021 09:25:59] \"GET / HTTP/1.1\" 200 -",
 			report: ReplacementReport{[]Replacement{
 				{Canonical: "10.130.0.1", ReplacedWith: "x-ipv4-0000000001-x",
 					Counter: map[string]uint{
 						"10.130.0.1": 6,
 					}},
-				{Canonical: "::FFFF", ReplacedWith: "x-ipv6-0000000001-x",
-					Counter: map[string]uint{
-						"::ffff": 6,
-					}},
 			}},
 		},
 	} {
@@ -1,41 +1,58 @@
 package obfuscator
 
 import (
-	"strings"
+	"sort"
 )
 
 type keywordsObfuscator struct {
 	ReplacementTracker
 	replacements map[string]string
+	// keywords are the keys of replacements in the order of the patterns of the matcher
+	keywords []string
+	matcher  *literalMatcher
 }
 
 func (o *keywordsObfuscator) Path(name string) string {
-	return replace(name, o.replacements, o.ReplacementTracker)
+	return replaceMatches(name, o.matches(name))
 }
 
 func (o *keywordsObfuscator) Contents(contents string) string {
-	return replace(contents, o.replacements, o.ReplacementTracker)
+	return replaceMatches(contents, o.matches(contents))
 }
 
-func replace(name string, replacements map[string]string, reporter ReplacementTracker) string {
-	for keyword, replacement := range replacements {
-		if strings.Contains(name, keyword) {
-			cnt := uint(strings.Count(name, keyword))
-			_ = reporter.GenerateIfAbsent(keyword, keyword, cnt, func() string {
+// matches returns the occurrences of all keywords, the longest keyword is replaced where keywords overlap.
+func (o *keywordsObfuscator) matches(input string) []match {
+	occurrences := o.matcher.leftmostLongest(input, nil)
+	if len(occurrences) == 0 {
+		return nil
+	}
+	matches := make([]match, len(occurrences))
+	for i, occurrence := range occurrences {
+		keyword := o.keywords[occurrence.pattern]
+		replacement := o.replacements[keyword]
+		matches[i] = match{start: occurrence.start, end: occurrence.end, replacement: func() string {
+			_ = o.GenerateIfAbsent(keyword, keyword, 1, func() string {
 				return replacement
 			})
-			name = strings.Replace(name, keyword, replacement, -1)
-		}
+			return replacement
+		}}
 	}
-	return name
+	return matches
 }
 
 // NewKeywordsObfuscator returns an Obfuscator which replace all occurrences of keys in the map
 // passed to it with the value of the key.
 func NewKeywordsObfuscator(replacements map[string]string) ReportingObfuscator {
 	tracker := NewSimpleTrackerMap(replacements)
+	keywords := make([]string, 0, len(replacements))
+	for k := range replacements {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
 	return &keywordsObfuscator{
 		ReplacementTracker: tracker,
 		replacements:       replacements,
+		keywords:           keywords,
+		matcher:            newLiteralMatcher(keywords),
 	}
 }
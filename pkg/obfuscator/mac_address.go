@@ -18,6 +18,7 @@ const (
 type macAddressObfuscator struct {
 	ReplacementTracker
 	regex         *regexp.Regexp
+	filter        *runFilter
 	obfsGenerator generator
 }
 
@@ -26,14 +27,17 @@ func (m *macAddressObfuscator) Path(s string) string {
 }
 
 func (m *macAddressObfuscator) Contents(s string) string {
-	matches := m.regex.FindAllString(s, -1)
-	for _, mac := range matches {
-		// normalizing the MAC Address string to the Uppercase to avoid the duplicate reporting
-		match := strings.ToUpper(strings.ReplaceAll(mac, "-", ":"))
-		replacement := m.obfsGenerator.generateReplacement(match, mac, 1, m.ReplacementTracker)
-		s = strings.ReplaceAll(s, mac, replacement)
-	}
-	return s
+	return replaceMatches(s, m.matches(s))
+}
+
+func (m *macAddressObfuscator) matches(input string) []match {
+	return regexMatches(m.regex, m.filter, input, func(mac string) func() string {
+		return func() string {
+			// normalizing the MAC Address string to the Uppercase to avoid the duplicate reporting
+			canonical := strings.ToUpper(strings.ReplaceAll(mac, "-", ":"))
+			return m.obfsGenerator.generateReplacement(canonical, mac, 1, m.ReplacementTracker)
+		}
+	})
 }
 
 func NewMacAddressObfuscator(replacementType schema.ObfuscateReplacementType, tracker ReplacementTracker) (ReportingObfuscator, error) {
@@ -49,6 +53,7 @@ func NewMacAddressObfuscator(replacementType schema.ObfuscateReplacementType, tr
 	return &macAddressObfuscator{
 		ReplacementTracker: tracker,
 		regex:              regex,
+		filter:             newRunFilter(hexDigits+":-", ":-", len("00:00:00:00:00:00")),
 		obfsGenerator:      *generator,
 	}, nil
 }
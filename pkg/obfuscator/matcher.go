@@ -0,0 +1,307 @@
+package obfuscator
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// match is an occurrence of something to obfuscate in the input, start and end are the byte offsets of the occurrence.
+type match struct {
+	start int
+	end   int
+	// order ranks the generation of the replacements of a line, lower first. The numbers of consistent replacements follow this order.
+	order int
+	// replacement returns the replacement of the occurrence and records it in the tracker, it is only called for matches that are replaced
+	replacement func() string
+}
+
+// matchingObfuscator is implemented by obfuscators that report their occurrences in the input instead of rewriting it, so that the
+// MultiObfuscator can resolve the occurrences of all obfuscators on the original input at once.
+type matchingObfuscator interface {
+	// matches returns the occurrences in the input, sorted by their start and not overlapping.
+	matches(input string) []match
+}
+
+// replaceMatches replaces all given matches in a single pass, the matches must be sorted by their start and must not overlap.
+func replaceMatches(input string, matches []match) string {
+	if len(matches) == 0 {
+		return input
+	}
+
+	replacements := make([]string, len(matches))
+	generation := make([]int, len(matches))
+	ordered := true
+	for i := range generation {
+		generation[i] = i
+		ordered = ordered && (i == 0 || matches[i-1].order <= matches[i].order)
+	}
+	if !ordered {
+		sort.SliceStable(generation, func(i, j int) bool {
+			return matches[generation[i]].order < matches[generation[j]].order
+		})
+	}
+	for _, i := range generation {
+		replacements[i] = matches[i].replacement()
+	}
+
+	var b strings.Builder
+	b.Grow(len(input))
+	last := 0
+	for i, m := range matches {
+		b.WriteString(input[last:m.start])
+		b.WriteString(replacements[i])
+		last = m.end
+	}
+	b.WriteString(input[last:])
+	return b.String()
+}
+
+// resolveOverlaps merges the sorted matches of multiple sources. The earlier sources have priority, a match is dropped when it overlaps a
+// match of a source with a higher priority.
+func resolveOverlaps(matchesByPriority ...[]match) []match {
+	var resolved []match
+	for _, matches := range matchesByPriority {
+		if len(matches) == 0 {
+			continue
+		}
+		if len(resolved) == 0 {
+			resolved = matches
+			continue
+		}
+		merged := make([]match, 0, len(resolved)+len(matches))
+		i := 0
+		for _, m := range matches {
+			for i < len(resolved) && resolved[i].end <= m.start {
+				merged = append(merged, resolved[i])
+				i++
+			}
+			if i < len(resolved) && resolved[i].start < m.end {
+				continue
+			}
+			merged = append(merged, m)
+		}
+		resolved = append(merged, resolved[i:]...)
+	}
+	return resolved
+}
+
+// regexMatches returns the matches of the pattern, the replace function is called with each occurrence and may skip it by returning nil.
+// Empty occurrences are always skipped. With a filter, the pattern only runs on the runs of the input that the filter returns.
+func regexMatches(pattern *regexp.Regexp, filter *runFilter, input string, replace func(occurrence string) func() string) []match {
+	var matches []match
+	find := func(offset int, s string) {
+		for _, loc := range pattern.FindAllStringIndex(s, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if replacement := replace(s[loc[0]:loc[1]]); replacement != nil {
+				matches = append(matches, match{start: offset + loc[0], end: offset + loc[1], replacement: replacement})
+			}
+		}
+	}
+
+	if filter == nil {
+		find(0, input)
+		return matches
+	}
+	filter.forEachRun(input, func(start, end int) {
+		find(start, input[start:end])
+	})
+	return matches
+}
+
+// runFilter finds the runs of the input that a regular expression without anchors could match, because they only consist of the bytes the
+// expression consumes. Running the expression on these runs only finds the same matches, but much faster than scanning the whole input.
+type runFilter struct {
+	allowed [256]bool
+	// required are the bytes of which at least one must be part of the run
+	required  [256]bool
+	minLength int
+}
+
+func newRunFilter(allowed string, required string, minLength int) *runFilter {
+	f := &runFilter{minLength: minLength}
+	for i := 0; i < len(allowed); i++ {
+		f.allowed[allowed[i]] = true
+	}
+	for i := 0; i < len(required); i++ {
+		f.required[required[i]] = true
+	}
+	return f
+}
+
+func (f *runFilter) forEachRun(input string, fn func(start, end int)) {
+	start, hasRequired := -1, false
+	for i := 0; i <= len(input); i++ {
+		if i < len(input) && f.allowed[input[i]] {
+			if start < 0 {
+				start, hasRequired = i, false
+			}
+			hasRequired = hasRequired || f.required[input[i]]
+			continue
+		}
+		if start >= 0 && hasRequired && i-start >= f.minLength {
+			fn(start, i)
+		}
+		start = -1
+	}
+}
+
+const (
+	digits    = "0123456789"
+	hexDigits = digits + "abcdefABCDEF"
+)
+
+// literalMatcher finds a fixed set of strings in a single pass with the Aho-Corasick algorithm. The automaton is compiled into a table over the
+// bytes that occur in the patterns, all other bytes lead back to the root.
+type literalMatcher struct {
+	patterns []string
+	// classes maps every byte to its column in the transitions, 0 is used for all bytes that are not part of any pattern
+	classes    [256]int32
+	numClasses int32
+	// transitions is the state machine, the next state is transitions[state*numClasses+class]
+	transitions []int32
+	// outputs is the longest pattern that ends in a state, or -1
+	outputs []int32
+	// outputLinks is the next state along the failure links that has an output, or -1
+	outputLinks []int32
+}
+
+// literalOccurrence is an occurrence of the pattern at index in the input.
+type literalOccurrence struct {
+	start   int
+	end     int
+	pattern int
+}
+
+// newLiteralMatcher creates a matcher for the patterns, empty patterns are never matched.
+func newLiteralMatcher(patterns []string) *literalMatcher {
+	m := &literalMatcher{patterns: patterns, numClasses: 1}
+	for _, p := range patterns {
+		for i := 0; i < len(p); i++ {
+			if m.classes[p[i]] == 0 {
+				m.classes[p[i]] = m.numClasses
+				m.numClasses++
+			}
+		}
+	}
+
+	// build the trie, state 0 is the root
+	m.transitions = make([]int32, m.numClasses)
+	m.outputs = []int32{-1}
+	for index, p := range patterns {
+		if p == "" {
+			continue
+		}
+		state := int32(0)
+		for i := 0; i < len(p); i++ {
+			c := m.classes[p[i]]
+			next := m.transitions[state*m.numClasses+c]
+			if next == 0 {
+				next = int32(len(m.outputs))
+				m.transitions = append(m.transitions, make([]int32, m.numClasses)...)
+				m.outputs = append(m.outputs, -1)
+				m.transitions[state*m.numClasses+c] = next
+			}
+			state = next
+		}
+		// duplicated patterns keep the first index
+		if m.outputs[state] == -1 {
+			m.outputs[state] = int32(index)
+		}
+	}
+
+	// turn the trie into a state machine by following the failure links in breadth-first order
+	states := len(m.outputs)
+	failures := make([]int32, states)
+	m.outputLinks = make([]int32, states)
+	m.outputLinks[0] = -1
+	queue := make([]int32, 0, states)
+	for c := int32(1); c < m.numClasses; c++ {
+		if next := m.transitions[c]; next != 0 {
+			m.outputLinks[next] = -1
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c := int32(1); c < m.numClasses; c++ {
+			next := m.transitions[state*m.numClasses+c]
+			fallback := m.transitions[failures[state]*m.numClasses+c]
+			if next == 0 {
+				m.transitions[state*m.numClasses+c] = fallback
+				continue
+			}
+			failures[next] = fallback
+			if m.outputs[fallback] != -1 {
+				m.outputLinks[next] = fallback
+			} else {
+				m.outputLinks[next] = m.outputLinks[fallback]
+			}
+			queue = append(queue, next)
+		}
+	}
+	return m
+}
+
+// occurrences returns all occurrences of all patterns in the input, including overlapping ones, sorted by their end.
+func (m *literalMatcher) occurrences(input string) []literalOccurrence {
+	if len(m.outputs) == 1 {
+		return nil
+	}
+
+	var occurrences []literalOccurrence
+	state := int32(0)
+	for i := 0; i < len(input); i++ {
+		state = m.transitions[state*m.numClasses+m.classes[input[i]]]
+		for s := state; s > 0; s = m.outputLinks[s] {
+			if p := m.outputs[s]; p != -1 {
+				occurrences = append(occurrences, literalOccurrence{start: i + 1 - len(m.patterns[p]), end: i + 1, pattern: int(p)})
+			}
+		}
+	}
+	return occurrences
+}
+
+// contains returns whether any pattern occurs in the input.
+func (m *literalMatcher) contains(input string) bool {
+	if len(m.outputs) == 1 {
+		return false
+	}
+	state := int32(0)
+	for i := 0; i < len(input); i++ {
+		state = m.transitions[state*m.numClasses+m.classes[input[i]]]
+		if m.outputs[state] != -1 || m.outputLinks[state] != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// leftmostLongest returns the non-overlapping occurrences that the accept function agrees to, preferring the leftmost and then the longest
+// occurrence. The accept function may be nil.
+func (m *literalMatcher) leftmostLongest(input string, accept func(o literalOccurrence) bool) []literalOccurrence {
+	occurrences := m.occurrences(input)
+	if len(occurrences) == 0 {
+		return nil
+	}
+	sort.Slice(occurrences, func(i, j int) bool {
+		if occurrences[i].start != occurrences[j].start {
+			return occurrences[i].start < occurrences[j].start
+		}
+		return occurrences[i].end > occurrences[j].end
+	})
+
+	selected := occurrences[:0]
+	last := 0
+	for _, o := range occurrences {
+		if o.start < last || (accept != nil && !accept(o)) {
+			continue
+		}
+		selected = append(selected, o)
+		last = o.end
+	}
+	return selected
+}
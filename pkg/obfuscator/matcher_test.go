@@ -0,0 +1,120 @@
+package obfuscator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiteralMatcherOccurrences(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		input    string
+		expected []literalOccurrence
+	}{
+		{
+			name:     "no patterns",
+			input:    "some input",
+			expected: nil,
+		},
+		{
+			name:     "overlapping patterns",
+			patterns: []string{"he", "she", "his", "hers"},
+			input:    "ushers",
+			expected: []literalOccurrence{{start: 1, end: 4, pattern: 1}, {start: 2, end: 4, pattern: 0}, {start: 2, end: 6, pattern: 3}},
+		},
+		{
+			name:     "repeated occurrences",
+			patterns: []string{"aa"},
+			input:    "aaaa",
+			expected: []literalOccurrence{{start: 0, end: 2}, {start: 1, end: 3}, {start: 2, end: 4}},
+		},
+		{
+			name:     "empty and duplicated patterns",
+			patterns: []string{"", "ab", "ab"},
+			input:    "xabx",
+			expected: []literalOccurrence{{start: 1, end: 3, pattern: 1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newLiteralMatcher(tc.patterns)
+			assert.Equal(t, tc.expected, m.occurrences(tc.input))
+			assert.Equal(t, len(tc.expected) > 0, m.contains(tc.input))
+		})
+	}
+}
+
+func TestLiteralMatcherLeftmostLongest(t *testing.T) {
+	m := newLiteralMatcher([]string{"worker-1", "worker-10", "10.0"})
+	assert.Equal(t, []literalOccurrence{{start: 0, end: 9, pattern: 1}, {start: 12, end: 20, pattern: 0}},
+		m.leftmostLongest("worker-10.0 worker-1", nil))
+
+	// the shorter pattern is used when the accept function rejects the longer one
+	assert.Equal(t, []literalOccurrence{{start: 0, end: 8, pattern: 0}},
+		m.leftmostLongest("worker-10", func(o literalOccurrence) bool {
+			return o.pattern != 1
+		}))
+}
+
+func TestLiteralMatcherAgainstStringsIndex(t *testing.T) {
+	patterns := []string{"abc", "bcd", "cd", "d", "abcd", "bca"}
+	m := newLiteralMatcher(patterns)
+	input := "abcdabcabcdbcaddcbabcd"
+
+	var expected []literalOccurrence
+	for end := 1; end <= len(input); end++ {
+		for start := 0; start < end; start++ {
+			for i, p := range patterns {
+				if input[start:end] == p {
+					expected = append(expected, literalOccurrence{start: start, end: end, pattern: i})
+				}
+			}
+		}
+	}
+	assert.Equal(t, expected, m.occurrences(input))
+}
+
+func TestRegexMatchesWithRunFilter(t *testing.T) {
+	filter := newRunFilter(digits+"._-", "._-", len("1.1.1.1"))
+	for _, input := range []string{
+		"",
+		"no address",
+		"10.0.0.1",
+		"from 10.0.0.1:6443 to 192.168.1.10, not 1.2.3 or 2024-03-12 or ip-10-0-1-23",
+		strings.Repeat("1.2.3.4.", 10),
+	} {
+		var expected []string
+		for _, loc := range ipv4Pattern.FindAllStringIndex(input, -1) {
+			expected = append(expected, input[loc[0]:loc[1]])
+		}
+
+		var actual []string
+		for _, m := range regexMatches(ipv4Pattern, filter, input, func(occurrence string) func() string {
+			return func() string { return occurrence }
+		}) {
+			assert.Equal(t, input[m.start:m.end], m.replacement())
+			actual = append(actual, m.replacement())
+		}
+		assert.Equal(t, expected, actual, input)
+	}
+}
+
+func TestReplaceMatchesOrder(t *testing.T) {
+	var generated []string
+	replacement := func(s string) func() string {
+		return func() string {
+			generated = append(generated, s)
+			return strings.ToUpper(s)
+		}
+	}
+	output := replaceMatches("a b c", []match{
+		{start: 0, end: 1, order: 2, replacement: replacement("a")},
+		{start: 2, end: 3, order: 1, replacement: replacement("b")},
+		{start: 4, end: 5, order: 1, replacement: replacement("c")},
+	})
+	assert.Equal(t, "A B C", output)
+	assert.Equal(t, []string{"b", "c", "a"}, generated)
+	assert.Equal(t, "unchanged", replaceMatches("unchanged", nil))
+}
@@ -1,23 +1,46 @@
 package obfuscator
 
+import "github.com/openshift/must-gather-clean/pkg/schema"
+
 type MultiObfuscator struct {
 	obfuscators []ReportingObfuscator
 }
 
 func (m *MultiObfuscator) Path(s string) string {
-	for _, obfuscator := range m.obfuscators {
-		s = obfuscator.Path(s)
-	}
-
-	return s
+	return m.obfuscate(s, schema.ObfuscateTargetFilePath)
 }
 
 func (m *MultiObfuscator) Contents(s string) string {
-	for _, obfuscator := range m.obfuscators {
-		s = obfuscator.Contents(s)
+	return m.obfuscate(s, schema.ObfuscateTargetFileContents)
+}
+
+// obfuscate collects the matches of all obfuscators on the original input and replaces them at once. Where matches overlap, the obfuscator
+// that is defined first wins, so replacements are never matched again and every replaced occurrence is counted exactly once.
+// Obfuscators that rewrite the input themselves are applied to the output of all obfuscators defined before them.
+func (m *MultiObfuscator) obfuscate(s string, target schema.ObfuscateTarget) string {
+	var pending [][]match
+	for _, o := range m.obfuscators {
+		if t, ok := o.(*targetObfuscator); ok {
+			if !t.appliesTo(target) {
+				continue
+			}
+			o = t.obfuscator
+		}
+		if mo, ok := o.(matchingObfuscator); ok {
+			pending = append(pending, mo.matches(s))
+			continue
+		}
+
+		s = replaceMatches(s, resolveOverlaps(pending...))
+		pending = pending[:0]
+		if target == schema.ObfuscateTargetFilePath {
+			s = o.Path(s)
+		} else {
+			s = o.Contents(s)
+		}
 	}
 
-	return s
+	return replaceMatches(s, resolveOverlaps(pending...))
 }
 
 func (m *MultiObfuscator) Report() ReplacementReport {
@@ -39,6 +62,37 @@ func (m *MultiObfuscator) ReportPerObfuscator() []ReplacementReport {
 	return multiReport
 }
 
+// ReplacementCount returns the number of replacements of all obfuscators, it grows with every original that gets a new replacement.
+func (m *MultiObfuscator) ReplacementCount() int {
+	count := 0
+	for _, o := range m.obfuscators {
+		if t, ok := unwrapTarget(o).(ReplacementTracker); ok {
+			count += t.Len()
+		}
+	}
+	return count
+}
+
+// ResetCounts resets the counts of all replacements, for example after a pass that only assigned the replacements.
+func (m *MultiObfuscator) ResetCounts() {
+	for _, o := range m.obfuscators {
+		if r, ok := o.(countResetter); ok {
+			r.ResetCounts()
+		}
+	}
+}
+
+// KubernetesResourceScanners returns all obfuscators that want to scan the kubernetes resources of the input.
+func (m *MultiObfuscator) KubernetesResourceScanners() []KubernetesResourceScanner {
+	var scanners []KubernetesResourceScanner
+	for _, o := range m.obfuscators {
+		if s, ok := o.(KubernetesResourceScanner); ok {
+			scanners = append(scanners, s)
+		}
+	}
+	return scanners
+}
+
 func NewMultiObfuscator(o []ReportingObfuscator) *MultiObfuscator {
 	return &MultiObfuscator{obfuscators: o}
 }
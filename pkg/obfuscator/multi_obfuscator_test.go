@@ -1,10 +1,13 @@
 package obfuscator
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/openshift/must-gather-clean/pkg/schema"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type splitObfuscator struct {
@@ -103,3 +106,160 @@ func TestMultiObfuscationReportMulti(t *testing.T) {
 		{"must be split thrice": "be split thrice"},
 		{"be split thrice": "split thrice"}}, reportsAsMap)
 }
+
+func TestMultiObfuscationSpans(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		obfuscators func(t *testing.T) []ReportingObfuscator
+		input       string
+		output      string
+		reports     []map[string]string
+	}{
+		{
+			name: "replacements are not matched again",
+			obfuscators: func(t *testing.T) []ReportingObfuscator {
+				ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+				require.NoError(t, err)
+				regex, err := NewRegexObfuscator("ipv4", NewSimpleTracker())
+				require.NoError(t, err)
+				return []ReportingObfuscator{ip, regex}
+			},
+			input:  "ipv4 address 10.0.0.1",
+			output: "xxxx address x-ipv4-0000000001-x",
+			reports: []map[string]string{
+				{"10.0.0.1": "x-ipv4-0000000001-x"},
+				{"ipv4": "xxxx"},
+			},
+		},
+		{
+			name: "the first obfuscator wins an overlap",
+			obfuscators: func(t *testing.T) []ReportingObfuscator {
+				keywords := NewKeywordsObfuscator(map[string]string{"example.com": "domain"})
+				domain, err := NewDomainObfuscator([]string{"example.com"}, schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+				require.NoError(t, err)
+				return []ReportingObfuscator{keywords, domain}
+			},
+			input:  "api.example.com and example.com",
+			output: "api.domain and domain",
+			reports: []map[string]string{
+				{"example.com": "domain"},
+				{},
+			},
+		},
+		{
+			name: "targets are respected",
+			obfuscators: func(t *testing.T) []ReportingObfuscator {
+				regex, err := NewRegexObfuscator("secret", NewSimpleTracker())
+				require.NoError(t, err)
+				return []ReportingObfuscator{
+					NewTargetObfuscator(schema.ObfuscateTargetFilePath, NewKeywordsObfuscator(map[string]string{"secret": "path"})),
+					regex,
+				}
+			},
+			input:  "a secret",
+			output: "a xxxxxx",
+			reports: []map[string]string{
+				{"secret": "path"},
+				{"secret": "xxxxxx"},
+			},
+		},
+		{
+			name: "rewriting obfuscators see the output of the obfuscators before them",
+			obfuscators: func(t *testing.T) []ReportingObfuscator {
+				regex, err := NewRegexObfuscator("split", NewSimpleTracker())
+				require.NoError(t, err)
+				return []ReportingObfuscator{
+					NewKeywordsObfuscator(map[string]string{"this": "that"}),
+					&splitObfuscator{tracker: NewSimpleTracker()},
+					regex,
+				}
+			},
+			input:  "this must be split",
+			output: "must be xxxxx",
+			reports: []map[string]string{
+				{"this": "that"},
+				{"that must be split": "must be split"},
+				{"split": "xxxxx"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mo := NewMultiObfuscator(tc.obfuscators(t))
+			assert.Equal(t, tc.output, mo.Contents(tc.input))
+			var reports []map[string]string
+			for _, report := range mo.ReportPerObfuscator() {
+				reports = append(reports, report.AsMap())
+			}
+			assert.Equal(t, tc.reports, reports)
+		})
+	}
+}
+
+// benchmarkLines resemble the lines of audit and pod logs, most of them do not contain anything to obfuscate.
+var benchmarkLines = []string{
+	`{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"6ef5f2d6-0a8c-4b5b-9f0e-4bd2e0b6f0a1","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/openshift-etcd/pods?limit=500","verb":"list","user":{"username":"system:serviceaccount:openshift-etcd-operator:etcd-operator"},"sourceIPs":["10.0.1.23"],"userAgent":"cluster-etcd-operator/v0.0.0 (linux/amd64) kubernetes/$Format"}`,
+	`I0312 10:15:02.123456       1 controller.go:123] Successfully synced 'openshift-ingress/router-default' on node worker-1.mycluster.example.com`,
+	`2024-03-12T10:15:02.123Z INFO  reconciling machine mycluster-x7k2p-worker-us-east-1a-abcde in phase Running`,
+	`E0312 10:15:03.000001       1 reflector.go:138] failed to list *v1.ConfigMap: Get "https://api-int.mycluster.example.com:6443/api/v1/configmaps?limit=500": dial tcp 10.0.0.5:6443: connect: connection refused`,
+	`    link/ether 52:54:00:6b:2c:9f brd ff:ff:ff:ff:ff:ff`,
+	`I0312 10:15:04.654321       1 leaderelection.go:258] successfully acquired lease openshift-kube-scheduler/kube-scheduler`,
+	`I0312 10:15:05.000000       1 event.go:294] "Event occurred" object="openshift-monitoring/prometheus-k8s-0" kind="Pod" apiVersion="v1" type="Normal" reason="Scheduled"`,
+	`inet6 fe80::5054:ff:fe6b:2c9f/64 scope link`,
+}
+
+func newBenchmarkObfuscator(b *testing.B) *MultiObfuscator {
+	ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(b, err)
+	mac, err := NewMacAddressObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(b, err)
+	domain, err := NewDomainObfuscator([]string{"example.com", "mycluster.example.com", "ec2.internal"}, schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(b, err)
+	hostname, err := NewHostnameObfuscator(schema.ObfuscateReplacementTypeConsistent, false, NewSimpleTracker())
+	require.NoError(b, err)
+	identity, err := NewClusterIdentityObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(b, err)
+	for _, r := range append(hostnameResources, clusterIdentityResources...) {
+		hostname.ScanKubernetesResource(r)
+		identity.ScanKubernetesResource(r)
+	}
+	keywords := map[string]string{}
+	for i := 0; i < 100; i++ {
+		keywords[fmt.Sprintf("secret-project-%d", i)] = fmt.Sprintf("project-%d", i)
+	}
+	regex, err := NewRegexObfuscator(`sha256:[a-f0-9]{64}`, NewSimpleTracker())
+	require.NoError(b, err)
+
+	return NewMultiObfuscator([]ReportingObfuscator{
+		identity,
+		hostname,
+		domain,
+		ip,
+		mac,
+		NewKeywordsObfuscator(keywords),
+		regex,
+	})
+}
+
+func BenchmarkMultiObfuscatorContents(b *testing.B) {
+	mo := newBenchmarkObfuscator(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range benchmarkLines {
+			_ = mo.Contents(line)
+		}
+	}
+}
+
+func BenchmarkKeywordsContents(b *testing.B) {
+	keywords := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		keywords[fmt.Sprintf("secret-project-%d", i)] = fmt.Sprintf("project-%d", i)
+	}
+	o := NewKeywordsObfuscator(keywords)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range benchmarkLines {
+			_ = o.Contents(line)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package obfuscator
 
+import "github.com/openshift/must-gather-clean/pkg/kube"
+
 // Obfuscator is the interface which all obfuscators should implement
 type Obfuscator interface {
 	// Path takes a relative path (from the must-gather input root) as input and returns the obfuscated name
@@ -13,3 +15,15 @@ type ReportingObfuscator interface {
 	// Report returns a map of words and their Replacements
 	Report() ReplacementReport
 }
+
+// KubernetesResourceScanner is implemented by obfuscators that learn what needs to be obfuscated from the kubernetes resources in the input.
+type KubernetesResourceScanner interface {
+	// ScanKubernetesResource is called during the prescan for every kubernetes resource found in the input, before any obfuscation happens.
+	ScanKubernetesResource(resource kube.Object)
+}
+
+// ScanningObfuscator is a ReportingObfuscator that needs to scan the kubernetes resources in the input first.
+type ScanningObfuscator interface {
+	ReportingObfuscator
+	KubernetesResourceScanner
+}
@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"math/big"
 	"strings"
+	"sync/atomic"
 
 	"github.com/openshift/must-gather-clean/pkg/schema"
 )
@@ -26,6 +27,14 @@ func newPetNameReplacementGenerator(prefix, static string, petNameGen *PetNameGe
 	}
 }
 
+// skip generates and discards the given number of names with two words, which brings a seeded random source to the state it had after
+// generating the same number of replacements.
+func (p *PetNameGenerator) skip(names int64) {
+	for i := int64(0); i < names; i++ {
+		p.Generate(2)
+	}
+}
+
 func (g *petNameReplacementGenerator) generateConsistentReplacement() string {
 	return g.prefix + "-" + g.petNameGen.Generate(2)
 }
@@ -76,6 +85,8 @@ type PetNameGenerator struct {
 	names      []string
 
 	rand RandomSource
+	// generated is the number of names generated so far, it is accessed atomically
+	generated int64
 }
 
 func NewPetNameGenerator(separator string, rand RandomSource) *PetNameGenerator {
@@ -111,6 +122,7 @@ func (p *PetNameGenerator) name() string {
 // If three or more words are requested, a variable number of Adverb() and a Adjective and a Name() is returned.
 // The separator can be any character, string, or the empty string.
 func (p *PetNameGenerator) Generate(words int) string {
+	atomic.AddInt64(&p.generated, 1)
 	if words == 1 {
 		return p.name()
 	} else if words == 2 {
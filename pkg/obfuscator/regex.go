@@ -20,16 +20,19 @@ func (r *regexObfuscator) Contents(s string) string {
 }
 
 func (r *regexObfuscator) replace(input string) string {
-	output := input
-	matches := r.pattern.FindAllString(input, -1)
-	for _, m := range matches {
-		replacement := strings.Repeat("x", len(m))
-		r.GenerateIfAbsent(m, m, 1, func() string {
+	return replaceMatches(input, r.matches(input))
+}
+
+func (r *regexObfuscator) matches(input string) []match {
+	return regexMatches(r.pattern, nil, input, func(m string) func() string {
+		return func() string {
+			replacement := strings.Repeat("x", len(m))
+			r.GenerateIfAbsent(m, m, 1, func() string {
+				return replacement
+			})
 			return replacement
-		})
-		output = strings.ReplaceAll(output, m, replacement)
-	}
-	return output
+		}
+	})
 }
 
 func NewRegexObfuscator(pattern string, tracker ReplacementTracker) (ReportingObfuscator, error) {
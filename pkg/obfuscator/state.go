@@ -0,0 +1,243 @@
+package obfuscator
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// petNamesCounter is the key of the number of generated pet names in the counters of the azure resource obfuscator.
+const petNamesCounter = "petnames"
+
+// State is what an obfuscator needs to continue with the same consistent replacements after a restart.
+type State struct {
+	Replacements []Replacement `json:"replacements"`
+	// Counters are the counters of the replacement generators of the obfuscator by their template
+	Counters map[string]int64 `json:"counters,omitempty"`
+	// Discovered are the values that the obfuscator learned from scanning the input, mapped to their kind if any
+	Discovered map[string]string `json:"discovered,omitempty"`
+}
+
+// counterHolder is implemented by obfuscators that generate numbered or random replacements.
+type counterHolder interface {
+	counters() map[string]int64
+	restoreCounters(counters map[string]int64)
+}
+
+// scanner is implemented by obfuscators that learn what to obfuscate from scanning the input.
+type scanner interface {
+	discovered() map[string]string
+	restoreDiscovered(discovered map[string]string)
+}
+
+// State returns the states of all obfuscators in their order. Replacements may be generated concurrently, the counters of the state are
+// at least as high as the numbers of its replacements.
+func (m *MultiObfuscator) State() []State {
+	states := make([]State, len(m.obfuscators))
+	for i, o := range m.obfuscators {
+		o = unwrapTarget(o)
+		// the report is taken first, the counters only grow
+		states[i].Replacements = o.Report().Replacements
+		if c, ok := o.(counterHolder); ok {
+			states[i].Counters = c.counters()
+		}
+		if s, ok := o.(scanner); ok {
+			states[i].Discovered = s.discovered()
+		}
+	}
+	return states
+}
+
+// Restore initializes the obfuscators with the states returned by State for the same configuration. It must be called before any
+// replacement is generated, the input does not need to be scanned again afterwards.
+func (m *MultiObfuscator) Restore(states []State) error {
+	if len(states) != len(m.obfuscators) {
+		return fmt.Errorf("expected the states of %d obfuscators, but got %d", len(m.obfuscators), len(states))
+	}
+	for i, o := range m.obfuscators {
+		o = unwrapTarget(o)
+		if t, ok := o.(ReplacementTracker); ok {
+			t.Initialize(ReplacementReport{Replacements: states[i].Replacements})
+		}
+		if c, ok := o.(counterHolder); ok {
+			c.restoreCounters(states[i].Counters)
+		}
+		if s, ok := o.(scanner); ok {
+			s.restoreDiscovered(states[i].Discovered)
+		}
+	}
+	return nil
+}
+
+// SkipSeededReplacements advances the counters past the numbers of the replacements that the trackers were seeded with, like those of a
+// previous report, so that a new original never gets the replacement of a seeded one.
+func (m *MultiObfuscator) SkipSeededReplacements() {
+	for _, o := range m.obfuscators {
+		o = unwrapTarget(o)
+		c, ok := o.(counterHolder)
+		if !ok {
+			continue
+		}
+		counters := c.counters()
+		replacements := o.Report().Replacements
+		if count, ok := counters[petNamesCounter]; ok && int64(len(replacements)) > count {
+			// every pet name replacement generated one name, a seeded generator continues after them
+			counters[petNamesCounter] = int64(len(replacements))
+		}
+		for _, r := range replacements {
+			for template, count := range counters {
+				var number int64
+				_, err := fmt.Sscanf(r.ReplacedWith, template, &number)
+				if err == nil && number > count {
+					counters[template] = number
+				}
+			}
+		}
+		c.restoreCounters(counters)
+	}
+}
+
+// Unreplaced returns the sorted values that the scanning obfuscators discovered, but which have no replacement in the given states, like
+// those of a previous report. The run that produced those states did either not know them or did not find them outside of omitted content.
+func (m *MultiObfuscator) Unreplaced(states []State) []string {
+	var unreplaced []string
+	for i, o := range m.obfuscators {
+		s, ok := unwrapTarget(o).(scanner)
+		if !ok {
+			continue
+		}
+		replaced := map[string]bool{}
+		if i < len(states) {
+			for _, r := range states[i].Replacements {
+				for original := range r.Counter {
+					replaced[original] = true
+				}
+			}
+		}
+		for value := range s.discovered() {
+			if !replaced[value] {
+				unreplaced = append(unreplaced, value)
+			}
+		}
+	}
+	sort.Strings(unreplaced)
+	return unreplaced
+}
+
+func unwrapTarget(o ReportingObfuscator) ReportingObfuscator {
+	if t, ok := o.(*targetObfuscator); ok {
+		return t.obfuscator
+	}
+	return o
+}
+
+func generatorCounters(generators ...*generator) map[string]int64 {
+	counters := map[string]int64{}
+	for _, g := range generators {
+		counters[g.template] = atomic.LoadInt64(&g.count)
+	}
+	return counters
+}
+
+func restoreGeneratorCounters(counters map[string]int64, generators ...*generator) {
+	for _, g := range generators {
+		atomic.StoreInt64(&g.count, counters[g.template])
+	}
+}
+
+func (o *ipObfuscator) generators() []*generator {
+	generators := make([]*generator, len(o.replacements))
+	for i := range o.replacements {
+		generators[i] = o.replacements[i].generator
+	}
+	return generators
+}
+
+func (o *ipObfuscator) counters() map[string]int64 {
+	return generatorCounters(o.generators()...)
+}
+
+func (o *ipObfuscator) restoreCounters(counters map[string]int64) {
+	restoreGeneratorCounters(counters, o.generators()...)
+}
+
+func (m *macAddressObfuscator) counters() map[string]int64 {
+	return generatorCounters(&m.obfsGenerator)
+}
+
+func (m *macAddressObfuscator) restoreCounters(counters map[string]int64) {
+	restoreGeneratorCounters(counters, &m.obfsGenerator)
+}
+
+func (d *domainObfuscator) counters() map[string]int64 {
+	return generatorCounters(&d.obfsGenerator)
+}
+
+func (d *domainObfuscator) restoreCounters(counters map[string]int64) {
+	restoreGeneratorCounters(counters, &d.obfsGenerator)
+}
+
+func (h *hostnameObfuscator) counters() map[string]int64 {
+	return generatorCounters(&h.obfsGenerator)
+}
+
+func (h *hostnameObfuscator) restoreCounters(counters map[string]int64) {
+	restoreGeneratorCounters(counters, &h.obfsGenerator)
+}
+
+func (c *clusterIdentityObfuscator) counters() map[string]int64 {
+	var generators []*generator
+	for _, g := range c.generators {
+		generators = append(generators, g)
+	}
+	return generatorCounters(generators...)
+}
+
+func (c *clusterIdentityObfuscator) restoreCounters(counters map[string]int64) {
+	for _, g := range c.generators {
+		restoreGeneratorCounters(counters, g)
+	}
+}
+
+func (o *azureResourceObfuscator) counters() map[string]int64 {
+	return map[string]int64{petNamesCounter: atomic.LoadInt64(&o.petNameGen.generated)}
+}
+
+// restoreCounters generates the same number of pet names again, so that a seeded generator continues where it stopped.
+func (o *azureResourceObfuscator) restoreCounters(counters map[string]int64) {
+	o.petNameGen.skip(counters[petNamesCounter] - atomic.LoadInt64(&o.petNameGen.generated))
+}
+
+func (h *hostnameObfuscator) discovered() map[string]string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	discovered := map[string]string{}
+	for hostname := range h.hostnames {
+		discovered[hostname] = ""
+	}
+	return discovered
+}
+
+func (h *hostnameObfuscator) restoreDiscovered(discovered map[string]string) {
+	for hostname := range discovered {
+		h.add(hostname)
+	}
+}
+
+func (c *clusterIdentityObfuscator) discovered() map[string]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	discovered := map[string]string{}
+	for identity, kind := range c.identities {
+		discovered[identity] = string(kind)
+	}
+	return discovered
+}
+
+func (c *clusterIdentityObfuscator) restoreDiscovered(discovered map[string]string) {
+	for identity, kind := range discovered {
+		c.add(identity, clusterIdentityKind(kind))
+	}
+}
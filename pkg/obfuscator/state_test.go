@@ -0,0 +1,162 @@
+package obfuscator
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func newStateTestObfuscator(t *testing.T) *MultiObfuscator {
+	ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(t, err)
+	mac, err := NewMacAddressObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(t, err)
+	domain, err := NewDomainObfuscator([]string{"example.com"}, schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(t, err)
+	azure, err := NewAzureResourceObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker(), ptr.To(1))
+	require.NoError(t, err)
+	return NewMultiObfuscator([]ReportingObfuscator{
+		NewTargetObfuscator(schema.ObfuscateTargetAll, ip),
+		mac,
+		domain,
+		azure,
+		NewKeywordsObfuscator(map[string]string{"secret": "public"}),
+	})
+}
+
+func TestStateRestore(t *testing.T) {
+	before := []string{
+		"10.0.0.1 and 52:54:00:6b:2c:9f on api.example.com",
+		"/subscriptions/my-subscription/resourceGroups/my-group is a secret",
+		"fe80::1 and 10.0.0.2",
+	}
+	after := []string{
+		"10.0.0.2 and 10.0.0.3 and fe80::2",
+		"/subscriptions/my-subscription/resourceGroups/other-group on example.com",
+	}
+
+	uninterrupted := newStateTestObfuscator(t)
+	interrupted := newStateTestObfuscator(t)
+	for _, line := range before {
+		assert.Equal(t, uninterrupted.Contents(line), interrupted.Contents(line))
+	}
+
+	// the state must survive a round trip through a checkpoint
+	raw, err := json.Marshal(interrupted.State())
+	require.NoError(t, err)
+	var states []State
+	require.NoError(t, json.Unmarshal(raw, &states))
+
+	resumed := newStateTestObfuscator(t)
+	require.NoError(t, resumed.Restore(states))
+	var resumedOutput []string
+	for _, line := range after {
+		output := resumed.Contents(line)
+		assert.Equal(t, uninterrupted.Contents(line), output)
+		resumedOutput = append(resumedOutput, output)
+	}
+	assert.Equal(t, "x-ipv4-0000000002-x and x-ipv4-0000000003-x and x-ipv6-0000000002-x", resumedOutput[0])
+
+	for i, report := range uninterrupted.ReportPerObfuscator() {
+		replacementReportsMatch(t, report, resumed.ReportPerObfuscator()[i])
+	}
+}
+
+func TestStateCounters(t *testing.T) {
+	mo := newStateTestObfuscator(t)
+	mo.Contents("10.0.0.1 10.0.0.2 fe80::1 52:54:00:6b:2c:9f /subscriptions/my-subscription")
+
+	var counters []map[string]int64
+	for _, s := range mo.State() {
+		counters = append(counters, s.Counters)
+	}
+	assert.Equal(t, []map[string]int64{
+		{consistentIPv4Template: 2, consistentIPv6Template: 1},
+		{consistentMACTemplate: 1},
+		{obfuscatedTemplate: 0},
+		{petNamesCounter: 1},
+		nil,
+	}, counters)
+}
+
+func TestStateDiscovered(t *testing.T) {
+	newScanningObfuscator := func() *MultiObfuscator {
+		hostname, err := NewHostnameObfuscator(schema.ObfuscateReplacementTypeConsistent, false, NewSimpleTracker())
+		require.NoError(t, err)
+		identity, err := NewClusterIdentityObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+		require.NoError(t, err)
+		return NewMultiObfuscator([]ReportingObfuscator{hostname, identity})
+	}
+	input := "worker-1 and ip-10-0-1-23 in mycluster-x7k2p at api.mycluster.example.com"
+
+	scanned := newScanningObfuscator()
+	for _, r := range append(hostnameResources, clusterIdentityResources...) {
+		for _, s := range scanned.KubernetesResourceScanners() {
+			s.ScanKubernetesResource(r)
+		}
+	}
+	expected := scanned.Contents(input)
+	require.NotEqual(t, input, expected)
+
+	// the resumed obfuscator knows the hostnames and identities without scanning the input again
+	resumed := newScanningObfuscator()
+	require.NoError(t, resumed.Restore(scanned.State()))
+	assert.Equal(t, expected, resumed.Contents(input))
+	assert.Equal(t, "", resumed.State()[0].Discovered["worker-1"])
+	assert.Equal(t, string(identityInfrastructureName), resumed.State()[1].Discovered["mycluster-x7k2p"])
+}
+
+func TestUnreplaced(t *testing.T) {
+	hostname, err := NewHostnameObfuscator(schema.ObfuscateReplacementTypeConsistent, false, NewSimpleTracker())
+	require.NoError(t, err)
+	ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	require.NoError(t, err)
+	mo := NewMultiObfuscator([]ReportingObfuscator{hostname, ip})
+	for _, r := range hostnameResources {
+		for _, s := range mo.KubernetesResourceScanners() {
+			s.ScanKubernetesResource(r)
+		}
+	}
+	discovered := mo.State()[0].Discovered
+	require.Contains(t, discovered, "worker-1")
+
+	var all []string
+	for value := range discovered {
+		all = append(all, value)
+	}
+	sort.Strings(all)
+	assert.Equal(t, all, mo.Unreplaced(nil))
+	assert.Equal(t, all, mo.Unreplaced([]State{{}, {}}))
+
+	// a previous run that replaced worker-1 already obfuscated it
+	previous := []State{{Replacements: []Replacement{{Canonical: "worker-1", ReplacedWith: "x-hostname-0000000001-x", Counter: map[string]uint{"worker-1": 0}}}}, {}}
+	assert.NotContains(t, mo.Unreplaced(previous), "worker-1")
+	assert.Len(t, mo.Unreplaced(previous), len(all)-1)
+}
+
+func TestSkipSeededReplacements(t *testing.T) {
+	ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTrackerMap(map[string]string{
+		"10.0.0.1": "x-ipv4-0000000001-x",
+		"10.0.0.2": "x-ipv4-0000000007-x",
+		"fe80::1":  "x-ipv6-0000000003-x",
+	}))
+	require.NoError(t, err)
+	mac, err := NewMacAddressObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTrackerMap(map[string]string{"52:54:00:6b:2c:9f": "static"}))
+	require.NoError(t, err)
+	mo := NewMultiObfuscator([]ReportingObfuscator{NewTargetObfuscator(schema.ObfuscateTargetAll, ip), mac})
+
+	mo.SkipSeededReplacements()
+	assert.Equal(t, "x-ipv4-0000000007-x x-ipv4-0000000008-x x-ipv6-0000000004-x", mo.Contents("10.0.0.2 10.0.0.3 fe80::2"))
+	assert.Equal(t, "x-mac-0000000001-x", mo.Contents("52:54:00:6b:2c:9e"))
+}
+
+func TestRestoreFailsOnDifferentObfuscators(t *testing.T) {
+	mo := newStateTestObfuscator(t)
+	err := mo.Restore([]State{{}})
+	assert.EqualError(t, err, "expected the states of 5 obfuscators, but got 1")
+}
@@ -8,23 +8,34 @@ type targetObfuscator struct {
 }
 
 func (t *targetObfuscator) Path(s string) string {
-	if t.target == schema.ObfuscateTargetAll || t.target == schema.ObfuscateTargetFilePath {
+	if t.appliesTo(schema.ObfuscateTargetFilePath) {
 		return t.obfuscator.Path(s)
 	}
 	return s
 }
 
 func (t *targetObfuscator) Contents(s string) string {
-	if t.target == schema.ObfuscateTargetAll || t.target == schema.ObfuscateTargetFileContents {
+	if t.appliesTo(schema.ObfuscateTargetFileContents) {
 		return t.obfuscator.Contents(s)
 	}
 	return s
 }
 
+// appliesTo returns whether the obfuscator is used for the file paths or the file contents.
+func (t *targetObfuscator) appliesTo(target schema.ObfuscateTarget) bool {
+	return t.target == schema.ObfuscateTargetAll || t.target == target
+}
+
 func (t *targetObfuscator) Report() ReplacementReport {
 	return t.obfuscator.Report()
 }
 
+func (t *targetObfuscator) ResetCounts() {
+	if r, ok := t.obfuscator.(countResetter); ok {
+		r.ResetCounts()
+	}
+}
+
 func NewTargetObfuscator(target schema.ObfuscateTarget, obfuscator ReportingObfuscator) ReportingObfuscator {
 	return &targetObfuscator{
 		target:     target,
@@ -4,8 +4,6 @@ import (
 	"sync"
 )
 
-var onlyOneInit = make(chan struct{})
-
 type GenerateReplacement func() string
 
 type ReplacementReport struct {
@@ -23,9 +21,9 @@ func (s ReplacementReport) AsMap() (m map[string]string) {
 }
 
 type Replacement struct {
-	Canonical    string
-	ReplacedWith string
-	Counter      map[string]uint
+	Canonical    string          `json:"canonical"`
+	ReplacedWith string          `json:"replacedWith"`
+	Counter      map[string]uint `json:"counter"`
 }
 
 func (r *Replacement) Increment(original string, count uint) {
@@ -55,11 +53,23 @@ type ReplacementTracker interface {
 	// If the replacement is not present then it uses the GenerateReplacement function to generate a replacement.
 	// Canonical is used as the key to replace, a replacement of original with respective count will be recorded for reporting reasons.
 	GenerateIfAbsent(canonical string, original string, count uint, generator GenerateReplacement) string
+
+	// ResetCounts sets the count of every original to zero, the replacements themselves are kept.
+	ResetCounts()
+
+	// Len returns the number of replacements.
+	Len() int
+}
+
+// countResetter is implemented by every obfuscator that embeds a ReplacementTracker.
+type countResetter interface {
+	ResetCounts()
 }
 
 type SimpleTracker struct {
-	lock    sync.RWMutex
-	mapping map[string]*Replacement
+	lock        sync.RWMutex
+	mapping     map[string]*Replacement
+	initialized bool
 }
 
 func (s *SimpleTracker) Report() ReplacementReport {
@@ -87,12 +97,33 @@ func (s *SimpleTracker) GenerateIfAbsent(canonical string, original string, coun
 	return g
 }
 
-func (s *SimpleTracker) Initialize(report ReplacementReport) {
-	close(onlyOneInit) // panics when called twice
+func (s *SimpleTracker) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.mapping)
+}
 
+func (s *SimpleTracker) ResetCounts() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	for _, r := range s.mapping {
+		for original := range r.Counter {
+			r.Counter[original] = 0
+		}
+	}
+}
+
+func (s *SimpleTracker) Initialize(report ReplacementReport) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.initialized {
+		panic("replacement tracker is already initialized")
+	}
+	s.initialized = true
+
 	for _, r := range report.Replacements {
 		c := make(map[string]uint)
 		for keyCopy, valueCopy := range r.Counter {
@@ -4,6 +4,7 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/openshift/must-gather-clean/pkg/schema"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -84,6 +85,51 @@ func TestHappyPathInit(t *testing.T) {
 	}}, st.Report())
 }
 
+func TestResetCounts(t *testing.T) {
+	st := NewSimpleTracker()
+	st.GenerateIfAbsent("a-canonical", "a-original", 3, func() string {
+		return "a-replaced"
+	})
+	st.ResetCounts()
+
+	r := st.GenerateIfAbsent("a-canonical", "a-original", 1, func() string {
+		return "b-replaced"
+	})
+	assert.Equal(t, "a-replaced", r)
+	replacementReportsMatch(t, ReplacementReport{Replacements: []Replacement{
+		{
+			Canonical:    "a-canonical",
+			ReplacedWith: "a-replaced",
+			Counter:      map[string]uint{"a-original": 1},
+		},
+	}}, st.Report())
+}
+
+func TestResetCountsThroughTargetObfuscator(t *testing.T) {
+	ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	assert.NoError(t, err)
+	mo := NewMultiObfuscator([]ReportingObfuscator{NewTargetObfuscator(schema.ObfuscateTargetAll, ip)})
+	assert.Equal(t, "x-ipv4-0000000001-x", mo.Contents("10.0.0.1"))
+	mo.ResetCounts()
+
+	assert.Equal(t, map[string]uint{"10.0.0.1": 0}, mo.Report().Replacements[0].Counter)
+}
+
+func TestReplacementCount(t *testing.T) {
+	ip, err := NewIPObfuscator(schema.ObfuscateReplacementTypeConsistent, NewSimpleTracker())
+	assert.NoError(t, err)
+	mac, err := NewMacAddressObfuscator(schema.ObfuscateReplacementTypeStatic, NewSimpleTracker())
+	assert.NoError(t, err)
+	mo := NewMultiObfuscator([]ReportingObfuscator{NewTargetObfuscator(schema.ObfuscateTargetAll, ip), mac, NewKeywordsObfuscator(map[string]string{"secret": "public"})})
+	// the keywords are known from the start
+	assert.Equal(t, 1, mo.ReplacementCount())
+
+	mo.Contents("10.0.0.1 10.0.0.2 10.0.0.1 52:54:00:6b:2c:9f")
+	assert.Equal(t, 4, mo.ReplacementCount())
+	mo.Contents("10.0.0.2 52:54:00:6b:2c:9e")
+	assert.Equal(t, 5, mo.ReplacementCount())
+}
+
 func replacementReportsMatch(t *testing.T, want, got ReplacementReport) {
 	assert.Equal(t, len(want.Replacements), len(got.Replacements))
 	if len(want.Replacements) != len(got.Replacements) {
@@ -105,3 +151,14 @@ func replacementReportsMatch(t *testing.T, want, got ReplacementReport) {
 		assert.Equal(t, w.Counter, g.Counter)
 	}
 }
+
+func TestInitializeTwicePanics(t *testing.T) {
+	st := NewSimpleTracker()
+	st.Initialize(ReplacementReport{})
+	assert.Panics(t, func() {
+		st.Initialize(ReplacementReport{})
+	})
+
+	// other trackers can still be initialized
+	NewSimpleTracker().Initialize(ReplacementReport{})
+}
@@ -0,0 +1,249 @@
+package omitter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/schema"
+)
+
+// sizeUnits are the multipliers of the units accepted by ParseSize, the binary units are the powers of 1024.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+}
+
+var sizeExpression = regexp.MustCompile(`^(\d+)\s*([A-Za-z]*)$`)
+
+// ParseSize parses a number of bytes with an optional unit, for example "500KB", "10MB" or "1GiB".
+func ParseSize(size string) (int64, error) {
+	m := sizeExpression.FindStringSubmatch(strings.TrimSpace(size))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size '%s'", size)
+	}
+	multiplier, ok := sizeUnits[strings.ToUpper(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit '%s' of size '%s', expected one of B, KB, MB, GB, KiB, MiB or GiB", m[2], size)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %w", size, err)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size '%s', it exceeds the maximum of %d bytes", size, int64(math.MaxInt64))
+	}
+	return n * multiplier, nil
+}
+
+// openRegularFile returns nil without an error if the path is not a regular file, symbolic links are never followed.
+func openRegularFile(inputFolder string, path string) (*os.File, error) {
+	stat, err := os.Lstat(filepath.Join(inputFolder, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, nil
+	}
+	f, err := os.Open(filepath.Join(inputFolder, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+type maxSizeOmitter struct {
+	inputFolder string
+	maxSize     string
+	maxBytes    int64
+}
+
+func (m *maxSizeOmitter) OmitPath(path string) (bool, error) {
+	omit, _, err := m.omitPathWithReason(path)
+	return omit, err
+}
+
+func (m *maxSizeOmitter) omitPathWithReason(path string) (bool, string, error) {
+	stat, err := os.Lstat(filepath.Join(m.inputFolder, path))
+	if err != nil {
+		return false, "", fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	if !stat.Mode().IsRegular() || stat.Size() <= m.maxBytes {
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("%d bytes exceed the maximum size of %s", stat.Size(), m.maxSize), nil
+}
+
+// NewMaxSizeOmitter returns an omitter which omits all regular files larger than maxSize, see ParseSize for its format.
+func NewMaxSizeOmitter(inputFolder string, maxSize string) (FileOmitter, error) {
+	maxBytes, err := ParseSize(maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return &maxSizeOmitter{inputFolder: inputFolder, maxSize: maxSize, maxBytes: maxBytes}, nil
+}
+
+// sniffLength is the number of leading bytes used to detect the type of a file, it's the most net/http.DetectContentType considers.
+const sniffLength = 512
+
+var (
+	elfMagic = []byte("\x7fELF")
+	// pcapMagics are the micro- and nanosecond pcap formats in both byte orders, followed by the pcapng section header block
+	pcapMagics = [][]byte{
+		{0xd4, 0xc3, 0xb2, 0xa1},
+		{0xa1, 0xb2, 0xc3, 0xd4},
+		{0x4d, 0x3c, 0xb2, 0xa1},
+		{0xa1, 0xb2, 0x3c, 0x4d},
+		{0x0a, 0x0d, 0x0d, 0x0a},
+	}
+)
+
+// elfTypeCore is the e_type of core dumps
+const elfTypeCore = 4
+
+type fileTypeOmitter struct {
+	inputFolder string
+	fileTypes   map[schema.OmitFileTypesElem]bool
+}
+
+func (f *fileTypeOmitter) OmitPath(path string) (bool, error) {
+	omit, _, err := f.omitPathWithReason(path)
+	return omit, err
+}
+
+func (f *fileTypeOmitter) omitPathWithReason(path string) (bool, string, error) {
+	file, err := openRegularFile(f.inputFolder, path)
+	if err != nil || file == nil {
+		return false, "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	fileType, description := detectFileType(header[:n])
+	if fileType == "" || !f.fileTypes[fileType] {
+		return false, "", nil
+	}
+	return true, description, nil
+}
+
+// detectFileType returns the type of the file starting with the header together with a description for the report, or an empty type
+// when the file is none of the known types.
+func detectFileType(header []byte) (schema.OmitFileTypesElem, string) {
+	if bytes.HasPrefix(header, elfMagic) {
+		// e_type follows the 16 byte identification, its byte order is given by EI_DATA
+		if len(header) >= 18 {
+			eType := uint16(header[16]) | uint16(header[17])<<8
+			if header[5] == 2 {
+				eType = uint16(header[16])<<8 | uint16(header[17])
+			}
+			if eType == elfTypeCore {
+				return schema.OmitFileTypesElemCore, "core dump"
+			}
+		}
+		return schema.OmitFileTypesElemELF, "ELF binary"
+	}
+	for _, magic := range pcapMagics {
+		if bytes.HasPrefix(header, magic) {
+			return schema.OmitFileTypesElemPcap, "packet capture"
+		}
+	}
+	if mimeType := http.DetectContentType(header); strings.HasPrefix(mimeType, "image/") {
+		return schema.OmitFileTypesElemImage, mimeType + " image"
+	}
+	return "", ""
+}
+
+// NewFileTypeOmitter returns an omitter which omits files of the given types, detected by the signature at the beginning of the file.
+// Core dumps are ELF files as well, but they are only omitted when the Core type is given.
+func NewFileTypeOmitter(inputFolder string, fileTypes []schema.OmitFileTypesElem) (FileOmitter, error) {
+	if len(fileTypes) == 0 {
+		return nil, errors.New("no fileTypes specified for file type omitter")
+	}
+	types := map[schema.OmitFileTypesElem]bool{}
+	for _, t := range fileTypes {
+		switch t {
+		case schema.OmitFileTypesElemELF, schema.OmitFileTypesElemCore, schema.OmitFileTypesElemPcap, schema.OmitFileTypesElemImage:
+			types[t] = true
+		default:
+			return nil, fmt.Errorf("unknown file type '%s'", t)
+		}
+	}
+	return &fileTypeOmitter{inputFolder: inputFolder, fileTypes: types}, nil
+}
+
+type containsRegexOmitter struct {
+	inputFolder string
+	regex       *regexp.Regexp
+}
+
+func (c *containsRegexOmitter) OmitPath(path string) (bool, error) {
+	omit, _, err := c.omitPathWithReason(path)
+	return omit, err
+}
+
+// omitPathWithReason reads the file line by line until the first match, gzipped files are decompressed just like for obfuscation.
+// The matching line itself is never part of the reason, since it's most likely confidential.
+func (c *containsRegexOmitter) omitPathWithReason(path string) (bool, string, error) {
+	file, err := openRegularFile(c.inputFolder, path)
+	if err != nil || file == nil {
+		return false, "", err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to create a gzip reader when opening '%s': %w", path, err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	bufferedReader := bufio.NewReader(reader)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := bufferedReader.ReadString('\n')
+		if line != "" && c.regex.MatchString(line) {
+			return true, fmt.Sprintf("line %d matches '%s'", lineNumber, c.regex.String()), nil
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, "", nil
+			}
+			return false, "", fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+	}
+}
+
+// NewContainsRegexOmitter returns an omitter which omits files that have any line matching the regular expression.
+func NewContainsRegexOmitter(inputFolder string, containsRegex string) (FileOmitter, error) {
+	if containsRegex == "" {
+		return nil, errors.New("regex for contains omitter cannot be empty")
+	}
+	r, err := regexp.Compile(containsRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contains regex '%s': %w", containsRegex, err)
+	}
+	return &containsRegexOmitter{inputFolder: inputFolder, regex: r}, nil
+}
@@ -2,21 +2,121 @@ package omitter
 
 import (
 	"errors"
+	"fmt"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 )
 
-type filePatternOmitter struct {
-	filePattern string
+// negationPrefix turns a pattern or regex into an exception, the files matching it are never omitted by any other path omitter.
+const negationPrefix = "!"
+
+// pathOmitter omits files by matching their path, or keeps them from being omitted by other path omitters when it is negated.
+type pathOmitter struct {
+	match   func(path string) (bool, error)
+	negated bool
+	// description is the pattern or regex, it is reported as the reason of an omission
+	description string
+}
+
+func (p *pathOmitter) OmitPath(path string) (bool, error) {
+	if p.negated {
+		return false, nil
+	}
+	return p.match(path)
 }
 
-func (f *filePatternOmitter) OmitPath(path string) (bool, error) {
-	return filepath.Match(f.filePattern, path)
+func (p *pathOmitter) omitPathWithReason(path string) (bool, string, error) {
+	omit, err := p.OmitPath(path)
+	if err != nil || !omit {
+		return false, "", err
+	}
+	return true, fmt.Sprintf("matches '%s'", p.description), nil
 }
 
-// NewFilenamePatternOmitter return an omitter which omits files based on a globbing pattern.
+// keepPath returns whether the path is an exception of a negated omitter.
+func (p *pathOmitter) keepPath(path string) (bool, error) {
+	if !p.negated {
+		return false, nil
+	}
+	return p.match(path)
+}
+
+// NewFilenamePatternOmitter return an omitter which omits files based on a globbing pattern. In addition to the syntax of path.Match,
+// a "**" path segment matches any number of directories. A leading "!" negates the pattern.
 func NewFilenamePatternOmitter(pattern string) (FileOmitter, error) {
-	if pattern == "" {
+	glob, negated := strings.CutPrefix(pattern, negationPrefix)
+	if glob == "" {
 		return nil, errors.New("pattern for file omitter cannot be empty")
 	}
-	return &filePatternOmitter{filePattern: pattern}, nil
+	if err := ValidateGlob(glob); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	return &pathOmitter{
+		match: func(path string) (bool, error) {
+			return matchGlob(glob, path)
+		},
+		negated:     negated,
+		description: pattern,
+	}, nil
+}
+
+// NewPathRegexOmitter returns an omitter which omits files whose path matches the regular expression. A leading "!" negates the expression.
+func NewPathRegexOmitter(pathRegex string) (FileOmitter, error) {
+	expression, negated := strings.CutPrefix(pathRegex, negationPrefix)
+	if expression == "" {
+		return nil, errors.New("regex for path omitter cannot be empty")
+	}
+	r, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path regex '%s': %w", pathRegex, err)
+	}
+	return &pathOmitter{
+		match: func(path string) (bool, error) {
+			return r.MatchString(filepath.ToSlash(path)), nil
+		},
+		negated:     negated,
+		description: pathRegex,
+	}, nil
+}
+
+// matchGlob matches the path segment by segment with path.Match, a "**" segment matches zero or more segments.
+func matchGlob(pattern string, filePath string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filepath.ToSlash(filePath), "/"))
+}
+
+func matchSegments(pattern []string, segments []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				matched, err := matchSegments(pattern[1:], segments[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(segments) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(pattern[0], segments[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0, nil
+}
+
+// ValidateGlob returns path.ErrBadPattern if any segment of the pattern is malformed. The patterns of File omissions and Include rules are
+// matched per segment, so a pattern is only valid if each of its segments is.
+func ValidateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -37,6 +37,42 @@ func TestFileOmitter(t *testing.T) {
 			input:    "quay-io-openshift-release-dev-ocp-v4-0-art-dev-sha256-47c2f751ab0d5ee88e2826749f1372e6a24db3d0c0c942136ae84db17cb7f086/namespaces/openshift-sdn/pods/ovn-2vqtd/openvswitch/openvswitch/logs/current.log",
 			expected: true,
 		},
+		{
+			name:     "log files in any directory",
+			pattern:  "**/*.log",
+			input:    "namespaces/default/pods/a/a/logs/current.log",
+			expected: true,
+		},
+		{
+			name:     "double star matches no directory",
+			pattern:  "**/*.log",
+			input:    "application.log",
+			expected: true,
+		},
+		{
+			name:     "everything below a directory",
+			pattern:  "namespaces/*/pods/**",
+			input:    "namespaces/default/pods/a/a.yaml",
+			expected: true,
+		},
+		{
+			name:     "double star in the middle",
+			pattern:  "namespaces/**/logs/*.log",
+			input:    "namespaces/default/pods/a/a/a/logs/current.log",
+			expected: true,
+		},
+		{
+			name:     "double star does not skip segments of the rest of the pattern",
+			pattern:  "namespaces/**/logs/*.log",
+			input:    "namespaces/default/pods/a/a.log",
+			expected: false,
+		},
+		{
+			name:     "negated patterns never omit",
+			pattern:  "!namespaces/openshift-etcd/**",
+			input:    "namespaces/openshift-etcd/pods/etcd-0/etcd-0.yaml",
+			expected: false,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			omitter, err := NewFilenamePatternOmitter(tc.pattern)
@@ -51,4 +87,54 @@ func TestFileOmitter(t *testing.T) {
 func TestEmptyPattern(t *testing.T) {
 	_, err := NewFilenamePatternOmitter("")
 	require.Error(t, err)
+	_, err = NewFilenamePatternOmitter("!")
+	require.Error(t, err)
+}
+
+func TestInvalidPattern(t *testing.T) {
+	_, err := NewFilenamePatternOmitter("**/[a-/*.log")
+	require.EqualError(t, err, "invalid pattern '**/[a-/*.log': syntax error in pattern")
+}
+
+func TestPathRegexOmitter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		regex    string
+		input    string
+		expected bool
+	}{
+		{
+			name:     "log files in any directory",
+			regex:    `\.log$`,
+			input:    "namespaces/default/pods/a/a/logs/current.log",
+			expected: true,
+		},
+		{
+			name:     "anchored regex",
+			regex:    `^namespaces/[^/]+/pods/`,
+			input:    "quay-io-image/namespaces/default/pods/a/a.yaml",
+			expected: false,
+		},
+		{
+			name:     "negated regex never omits",
+			regex:    `!^namespaces/openshift-`,
+			input:    "namespaces/openshift-etcd/pods/etcd-0/etcd-0.yaml",
+			expected: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			omitter, err := NewPathRegexOmitter(tc.regex)
+			require.NoError(t, err)
+			omit, err := omitter.OmitPath(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, omit)
+		})
+	}
+}
+
+func TestInvalidPathRegex(t *testing.T) {
+	_, err := NewPathRegexOmitter("!")
+	require.EqualError(t, err, "regex for path omitter cannot be empty")
+	_, err = NewPathRegexOmitter("a(b")
+	require.EqualError(t, err, "invalid path regex 'a(b': error parsing regexp: missing closing ): `a(b`")
 }
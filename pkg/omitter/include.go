@@ -0,0 +1,98 @@
+package omitter
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+)
+
+type namespaceIncluder struct {
+	namespaces []string
+	patterns   []string
+}
+
+// IncludePath includes the files matching any of the patterns and all files within the "namespaces/<namespace>/" directory of
+// an included namespace, wherever that directory is located in the must-gather. The resource files directly within a "namespaces"
+// directory, like "cluster-scoped-resources/core/namespaces/<namespace>.yaml", contain the Namespace resources, they are included so that
+// IncludeKubeResource decides on each of them.
+func (n *namespaceIncluder) IncludePath(filePath string) (bool, error) {
+	for _, p := range n.patterns {
+		matched, err := matchGlob(p, filePath)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	if namespace, ok := namespaceOfPath(filePath); ok {
+		return n.includesNamespace(namespace), nil
+	}
+	return len(n.namespaces) > 0 && isNamespaceResourceFile(filePath), nil
+}
+
+// IncludeKubeResource includes all cluster scoped resources and the namespaced resources of an included namespace.
+// Namespace resources are treated as part of the namespace they define.
+func (n *namespaceIncluder) IncludeKubeResource(resource kube.Resource) bool {
+	if len(n.namespaces) == 0 {
+		return true
+	}
+
+	namespace := resource.Metadata.Namespace
+	if resource.Kind == "Namespace" && resource.ApiVersion == "v1" {
+		namespace = resource.Metadata.Name
+	}
+	if namespace == "" {
+		return true
+	}
+	return n.includesNamespace(namespace)
+}
+
+func (n *namespaceIncluder) includesNamespace(namespace string) bool {
+	return matchesAnyPattern(n.namespaces, namespace)
+}
+
+// namespaceOfPath returns the namespace of a path within a "namespaces/<namespace>/" directory.
+func namespaceOfPath(filePath string) (string, bool) {
+	segments := strings.Split(filepath.ToSlash(filePath), "/")
+	// the namespace must be a directory, "cluster-scoped-resources/core/namespaces/<namespace>.yaml" is not within a namespace
+	for i := 0; i+2 < len(segments); i++ {
+		if segments[i] == "namespaces" {
+			return segments[i+1], true
+		}
+	}
+	return "", false
+}
+
+// isNamespaceResourceFile returns whether the path is a kubernetes resource file directly within a "namespaces" directory.
+func isNamespaceResourceFile(filePath string) bool {
+	segments := strings.Split(filepath.ToSlash(filePath), "/")
+	return len(segments) >= 2 && segments[len(segments)-2] == "namespaces" && kube.IsResourceFile(filePath)
+}
+
+// NewNamespaceIncluder returns an Includer that keeps the given namespaces, which can be glob patterns, and the files matching any of
+// the given file patterns.
+func NewNamespaceIncluder(namespaces []string, patterns []string) (Includer, error) {
+	if len(namespaces) == 0 && len(patterns) == 0 {
+		return nil, errors.New("no namespaces or patterns specified in include")
+	}
+	for _, ns := range namespaces {
+		if _, err := path.Match(ns, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern '%s': %w", ns, err)
+		}
+	}
+	for _, p := range patterns {
+		if p == "" {
+			return nil, errors.New("pattern for include cannot be empty")
+		}
+		if err := ValidateGlob(p); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", p, err)
+		}
+	}
+	return &namespaceIncluder{namespaces: namespaces, patterns: patterns}, nil
+}
@@ -0,0 +1,160 @@
+package omitter
+
+import (
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNamespaceIncluder(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		namespaces    []string
+		patterns      []string
+		expectedError string
+	}{
+		{
+			name:          "nothing included",
+			expectedError: "no namespaces or patterns specified in include",
+		},
+		{
+			name:       "namespaces and patterns",
+			namespaces: []string{"openshift-*", "default"},
+			patterns:   []string{"cluster-scoped-resources/*/nodes/*"},
+		},
+		{
+			name:          "invalid namespace pattern",
+			namespaces:    []string{"[a-"},
+			expectedError: "invalid namespace pattern '[a-': syntax error in pattern",
+		},
+		{
+			name:          "empty pattern",
+			patterns:      []string{""},
+			expectedError: "pattern for include cannot be empty",
+		},
+		{
+			name:          "invalid pattern",
+			patterns:      []string{"[a-"},
+			expectedError: "invalid pattern '[a-': syntax error in pattern",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewNamespaceIncluder(tc.namespaces, tc.patterns)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNamespaceIncluderPaths(t *testing.T) {
+	includer, err := NewNamespaceIncluder([]string{"openshift-*", "default"}, []string{"cluster-scoped-resources/*/nodes/*"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		path     string
+		expected bool
+	}{
+		{path: "namespaces/default/core/pods.yaml", expected: true},
+		{path: "quay-io-image/namespaces/openshift-etcd/pods/etcd-0/etcd-0.yaml", expected: true},
+		{path: "namespaces/customer-app/core/secrets.yaml", expected: false},
+		{path: "cluster-scoped-resources/core/nodes/master-0.yaml", expected: true},
+		{path: "cluster-scoped-resources/core/namespaces/default.yaml", expected: true},
+		{path: "cluster-scoped-resources/core/namespaces/customer-app.yaml", expected: true},
+		{path: "cluster-scoped-resources/core/namespaces/customer-app.log", expected: false},
+		{path: "host_service_logs/masters/kubelet_service.log", expected: false},
+		{path: "namespaces", expected: false},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			include, err := includer.IncludePath(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, include)
+		})
+	}
+}
+
+func TestNamespaceIncluderResources(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		namespaces []string
+		resource   kube.Resource
+		expected   bool
+	}{
+		{
+			name:       "included namespace",
+			namespaces: []string{"openshift-*"},
+			resource:   kube.Resource{ApiVersion: "v1", Kind: "Pod", Metadata: kube.Metadata{Name: "etcd-0", Namespace: "openshift-etcd"}},
+			expected:   true,
+		},
+		{
+			name:       "other namespace",
+			namespaces: []string{"openshift-*"},
+			resource:   kube.Resource{ApiVersion: "v1", Kind: "Pod", Metadata: kube.Metadata{Name: "app", Namespace: "customer-app"}},
+			expected:   false,
+		},
+		{
+			name:       "cluster scoped resource",
+			namespaces: []string{"openshift-*"},
+			resource:   kube.Resource{ApiVersion: "v1", Kind: "Node", Metadata: kube.Metadata{Name: "master-0"}},
+			expected:   true,
+		},
+		{
+			name:       "namespace of another namespace",
+			namespaces: []string{"openshift-*"},
+			resource:   kube.Resource{ApiVersion: "v1", Kind: "Namespace", Metadata: kube.Metadata{Name: "customer-app"}},
+			expected:   false,
+		},
+		{
+			name:       "included namespace resource",
+			namespaces: []string{"openshift-*"},
+			resource:   kube.Resource{ApiVersion: "v1", Kind: "Namespace", Metadata: kube.Metadata{Name: "openshift-etcd"}},
+			expected:   true,
+		},
+		{
+			name:     "only patterns keep all resources",
+			resource: kube.Resource{ApiVersion: "v1", Kind: "Pod", Metadata: kube.Metadata{Name: "app", Namespace: "customer-app"}},
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			includer, err := NewNamespaceIncluder(tc.namespaces, []string{"*.log"})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, includer.IncludeKubeResource(tc.resource))
+		})
+	}
+}
+
+func TestNamespaceIncluderNamespaceFiles(t *testing.T) {
+	includer, err := NewNamespaceIncluder([]string{"openshift-*"}, nil)
+	require.NoError(t, err)
+	omitter := NewMultiReportingOmitter("", includer, nil, nil)
+
+	for _, tc := range []struct {
+		namespace string
+		omitted   bool
+	}{
+		{namespace: "openshift-etcd", omitted: false},
+		{namespace: "customer-app", omitted: true},
+	} {
+		t.Run(tc.namespace, func(t *testing.T) {
+			path := "cluster-scoped-resources/core/namespaces/" + tc.namespace + ".yaml"
+			omit, err := omitter.OmitPath(path)
+			require.NoError(t, err)
+			assert.False(t, omit, "the file is read to decide on its Namespace resource")
+
+			omit, err = omitter.OmitKubeResourceItem(path, kube.Resource{ApiVersion: "v1", Kind: "Namespace", Metadata: kube.Metadata{Name: tc.namespace}})
+			require.NoError(t, err)
+			assert.Equal(t, tc.omitted, omit)
+		})
+	}
+
+	onlyPatterns, err := NewNamespaceIncluder(nil, []string{"*.log"})
+	require.NoError(t, err)
+	include, err := onlyPatterns.IncludePath("cluster-scoped-resources/core/namespaces/openshift-etcd.yaml")
+	require.NoError(t, err)
+	assert.False(t, include)
+}
@@ -2,48 +2,128 @@ package omitter
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/openshift/must-gather-clean/pkg/kube"
 )
 
 type kubernetesResourceOmitter struct {
-	apiVersion   string
-	resourceKind string
-	namespaces   map[string]struct{}
+	apiVersion    string
+	resourceKind  string
+	namespaces    map[string]struct{}
+	names         []string
+	labelSelector kube.LabelSelector
+	// selector is the label selector as it was configured, it is reported as part of the reason of an omission
+	selector    string
+	annotations map[string]string
 }
 
 func (k *kubernetesResourceOmitter) OmitKubeResource(resourceList *kube.ResourceListWithPath) (bool, error) {
-	if len(resourceList.Items) == 0 {
-		return false, nil
-	}
+	omit, _, err := k.omitKubeResourceWithReason(resourceList)
+	return omit, err
+}
 
-	var found bool
-	// loop over the resources and if one of them matches the criteria then set the `found` flag.
+// omitKubeResourceWithReason returns whether any of the resources matches the criteria, the reason describes the first resource that does.
+func (k *kubernetesResourceOmitter) omitKubeResourceWithReason(resourceList *kube.ResourceListWithPath) (bool, string, error) {
 	for _, r := range resourceList.Items {
-		// if namespaces are specified then verify that the resource belongs to one of the namespaces
-		if len(k.namespaces) > 0 {
-			if _, ok := k.namespaces[r.Metadata.Namespace]; !ok {
-				continue
-			}
+		if k.matches(r) {
+			return true, k.reason(r), nil
 		}
+	}
+	return false, "", nil
+}
 
-		// if not of the specified kind then return
-		if k.resourceKind != r.Kind {
-			continue
+// reason lists the criteria that the matching resource fulfills, only the criteria that were configured are included. The name is
+// reported with the pattern that matched it.
+func (k *kubernetesResourceOmitter) reason(r kube.Resource) string {
+	kind := k.resourceKind
+	if k.apiVersion != "" {
+		kind = k.apiVersion + "/" + kind
+	}
+	criteria := []string{fmt.Sprintf("kind '%s'", kind)}
+	if len(k.namespaces) > 0 {
+		criteria = append(criteria, fmt.Sprintf("namespace '%s'", r.Metadata.Namespace))
+	}
+	if pattern, ok := matchingPattern(k.names, r.Metadata.Name); ok {
+		criteria = append(criteria, fmt.Sprintf("name '%s'", pattern))
+	}
+	if k.selector != "" {
+		criteria = append(criteria, fmt.Sprintf("label selector '%s'", k.selector))
+	}
+	keys := make([]string, 0, len(k.annotations))
+	for key := range k.annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		criteria = append(criteria, fmt.Sprintf("annotation '%s=%s'", key, k.annotations[key]))
+	}
+	return "matches " + strings.Join(criteria, ", ")
+}
+
+func (k *kubernetesResourceOmitter) matches(r kube.Resource) bool {
+	// if namespaces are specified then verify that the resource belongs to one of the namespaces
+	if len(k.namespaces) > 0 {
+		if _, ok := k.namespaces[r.Metadata.Namespace]; !ok {
+			return false
 		}
+	}
+
+	// if not of the specified kind then return
+	if k.resourceKind != r.Kind {
+		return false
+	}
+
+	// if apiVersion is specified and does not match resource apiVersion then return
+	if k.apiVersion != "" && k.apiVersion != r.ApiVersion {
+		return false
+	}
+
+	return k.matchesMetadata(r.Metadata)
+}
 
-		// if apiVersion is specified and does not match resource apiVersion then return
-		if k.apiVersion != "" && k.apiVersion != r.ApiVersion {
-			continue
+// matchesMetadata verifies the name, labels and annotations of the resource, all of them match when not specified.
+func (k *kubernetesResourceOmitter) matchesMetadata(metadata kube.Metadata) bool {
+	if len(k.names) > 0 && !matchesAnyPattern(k.names, metadata.Name) {
+		return false
+	}
+
+	if !k.labelSelector.Matches(metadata.Labels) {
+		return false
+	}
+
+	for key, pattern := range k.annotations {
+		value, ok := metadata.Annotations[key]
+		if !ok {
+			return false
+		}
+		// the patterns were validated on creation, the error can be ignored
+		if matched, _ := path.Match(pattern, value); !matched {
+			return false
 		}
+	}
+	return true
+}
 
-		found = true
-		break
+func matchesAnyPattern(patterns []string, name string) bool {
+	_, ok := matchingPattern(patterns, name)
+	return ok
+}
+
+// matchingPattern returns the first of the patterns that matches the name.
+func matchingPattern(patterns []string, name string) (string, bool) {
+	for _, p := range patterns {
+		if matched, _ := path.Match(p, name); matched {
+			return p, true
+		}
 	}
-	return found, nil
+	return "", false
 }
 
-func NewKubernetesResourceOmitter(apiVersion, resourceKind *string, namespaces []string) (KubernetesResourceOmitter, error) {
+func NewKubernetesResourceOmitter(apiVersion, resourceKind *string, namespaces []string, names []string, labelSelector *string, annotations map[string]string) (KubernetesResourceOmitter, error) {
 	if resourceKind == nil || *resourceKind == "" {
 		return nil, errors.New("no resourceKind specified in omit")
 	}
@@ -55,5 +135,36 @@ func NewKubernetesResourceOmitter(apiVersion, resourceKind *string, namespaces [
 	if apiVersion != nil {
 		version = *apiVersion
 	}
-	return &kubernetesResourceOmitter{apiVersion: version, resourceKind: *resourceKind, namespaces: ns}, nil
+
+	for _, n := range names {
+		if _, err := path.Match(n, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern '%s': %w", n, err)
+		}
+	}
+	for key, pattern := range annotations {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s' for annotation '%s': %w", pattern, key, err)
+		}
+	}
+
+	var selector kube.LabelSelector
+	var selectorString string
+	if labelSelector != nil {
+		var err error
+		selector, err = kube.ParseLabelSelector(*labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		selectorString = *labelSelector
+	}
+
+	return &kubernetesResourceOmitter{
+		apiVersion:    version,
+		resourceKind:  *resourceKind,
+		namespaces:    ns,
+		names:         names,
+		labelSelector: selector,
+		selector:      selectorString,
+		annotations:   annotations,
+	}, nil
 }
@@ -1,7 +1,8 @@
 package omitter
 
 import (
-	"os"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/openshift/must-gather-clean/pkg/kube"
@@ -15,6 +16,9 @@ func TestNewKubernetesResourceOmitter(t *testing.T) {
 		apiVersion    string
 		kind          string
 		namespaces    []string
+		names         []string
+		labelSelector *string
+		annotations   map[string]string
 		expectedError string
 	}{
 		{
@@ -57,9 +61,27 @@ func TestNewKubernetesResourceOmitter(t *testing.T) {
 			namespaces: []string{"kube-system", "oepnshift"},
 			kind:       "Machine",
 		},
+		{
+			name:          "invalid name pattern",
+			kind:          "Secret",
+			names:         []string{"[a-"},
+			expectedError: "invalid name pattern '[a-': syntax error in pattern",
+		},
+		{
+			name:          "invalid label selector",
+			kind:          "Secret",
+			labelSelector: pString("a in (b c)"),
+			expectedError: "invalid label selector 'a in (b c)': invalid label value 'b c' for key 'a'",
+		},
+		{
+			name:          "invalid annotation pattern",
+			kind:          "Secret",
+			annotations:   map[string]string{"owner": "[a-"},
+			expectedError: "invalid pattern '[a-' for annotation 'owner': syntax error in pattern",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := NewKubernetesResourceOmitter(&tc.apiVersion, &tc.kind, tc.namespaces)
+			_, err := NewKubernetesResourceOmitter(&tc.apiVersion, &tc.kind, tc.namespaces, tc.names, tc.labelSelector, tc.annotations)
 			if tc.expectedError != "" {
 				assert.EqualError(t, err, tc.expectedError)
 			} else {
@@ -71,12 +93,15 @@ func TestNewKubernetesResourceOmitter(t *testing.T) {
 
 func TestKubernetesResourceOmitter(t *testing.T) {
 	for _, tc := range []struct {
-		name       string
-		resource   string
-		omit       bool
-		apiVersion string
-		kind       string
-		namespaces []string
+		name          string
+		resource      string
+		omit          bool
+		apiVersion    string
+		kind          string
+		namespaces    []string
+		names         []string
+		labelSelector *string
+		annotations   map[string]string
 	}{
 		{
 			name: "all match",
@@ -208,23 +233,119 @@ items:
 			apiVersion: "v1",
 			omit:       false,
 		},
+		{
+			name: "name glob match",
+			resource: `apiVersion: v1
+kind: Secret
+metadata:
+    name: customer-db
+    namespace: default
+`,
+			kind:  "Secret",
+			names: []string{"platform", "customer-*"},
+			omit:  true,
+		},
+		{
+			name: "name mismatch",
+			resource: `apiVersion: v1
+kind: Secret
+metadata:
+    name: platform-tls
+    namespace: default
+`,
+			kind:  "Secret",
+			names: []string{"customer-*"},
+			omit:  false,
+		},
+		{
+			name: "label selector match",
+			resource: `apiVersion: v1
+kind: Secret
+metadata:
+    name: db
+    labels:
+        customer-data: "true"
+        tier: backend
+`,
+			kind:          "Secret",
+			labelSelector: pString("customer-data=true,tier in (frontend, backend)"),
+			omit:          true,
+		},
+		{
+			name: "label selector mismatch",
+			resource: `apiVersion: v1
+kind: Secret
+metadata:
+    name: platform
+    labels:
+        tier: backend
+`,
+			kind:          "Secret",
+			labelSelector: pString("customer-data=true"),
+			omit:          false,
+		},
+		{
+			name: "annotations match",
+			resource: `apiVersion: v1
+kind: Secret
+metadata:
+    name: db
+    annotations:
+        owner: team-a
+        description: contains customer records
+`,
+			kind:        "Secret",
+			annotations: map[string]string{"owner": "team-*", "description": "*"},
+			omit:        true,
+		},
+		{
+			name: "annotation missing",
+			resource: `apiVersion: v1
+kind: Secret
+metadata:
+    name: db
+    annotations:
+        owner: team-a
+`,
+			kind:        "Secret",
+			annotations: map[string]string{"owner": "team-*", "description": "*"},
+			omit:        false,
+		},
+		{
+			name: "resource list label selector match",
+			resource: `---
+apiVersion: v1
+kind: SecretList
+items:
+    - apiVersion: v1
+      kind: Secret
+      metadata:
+          name: platform
+    - apiVersion: v1
+      kind: Secret
+      metadata:
+          name: customer
+          labels:
+              customer-data: "true"
+`,
+			kind:          "Secret",
+			labelSelector: pString("customer-data"),
+			omit:          true,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			file, err := os.CreateTemp("", "resource-omit-*.yaml")
-			require.NoError(t, err)
-			defer func(name string) {
-				_ = os.Remove(name)
-			}(file.Name())
-			_, err = file.Write([]byte(tc.resource))
-			require.NoError(t, err)
-			err = file.Close()
+			omitter, err := NewKubernetesResourceOmitter(&tc.apiVersion, &tc.kind, tc.namespaces, tc.names, tc.labelSelector, tc.annotations)
 			require.NoError(t, err)
 
-			omitter, err := NewKubernetesResourceOmitter(&tc.apiVersion, &tc.kind, tc.namespaces)
-			require.NoError(t, err)
-
-			resourceList, err := kube.ReadKubernetesResourceFromPath(file.Name())
+			decoder, err := kube.NewResourceDecoder("resource.yaml", strings.NewReader(tc.resource))
 			require.NoError(t, err)
+			resourceList := &kube.ResourceListWithPath{Path: "resource.yaml"}
+			for part, err := decoder.Next(); err != io.EOF; part, err = decoder.Next() {
+				require.NoError(t, err)
+				if resource, err := part.Resource(); err == nil {
+					resourceList.Items = append(resourceList.Items, resource)
+				}
+			}
 
 			omit, err := omitter.OmitKubeResource(resourceList)
 			require.NoError(t, err)
@@ -233,3 +354,47 @@ items:
 	}
 
 }
+
+func pString(s string) *string {
+	return &s
+}
+
+func TestKubernetesResourceOmitterReason(t *testing.T) {
+	resource := kube.Resource{ApiVersion: "v1", Kind: "Secret", Metadata: kube.Metadata{
+		Name:        "pull-secret",
+		Namespace:   "openshift-config",
+		Labels:      map[string]string{"customer-data": "true"},
+		Annotations: map[string]string{"owner": "team-a", "tier": "backend"},
+	}}
+	for _, tc := range []struct {
+		name          string
+		apiVersion    string
+		namespaces    []string
+		names         []string
+		labelSelector *string
+		annotations   map[string]string
+		reason        string
+	}{
+		{name: "kind", reason: "matches kind 'Secret'"},
+		{name: "apiVersion", apiVersion: "v1", reason: "matches kind 'v1/Secret'"},
+		{name: "namespace", namespaces: []string{"default", "openshift-config"}, reason: "matches kind 'Secret', namespace 'openshift-config'"},
+		{name: "name pattern", names: []string{"other", "pull-*"}, reason: "matches kind 'Secret', name 'pull-*'"},
+		{name: "label selector", labelSelector: pString("customer-data=true"), reason: "matches kind 'Secret', label selector 'customer-data=true'"},
+		{
+			name:        "annotations",
+			annotations: map[string]string{"tier": "back*", "owner": "team-a"},
+			reason:      "matches kind 'Secret', annotation 'owner=team-a', annotation 'tier=back*'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kind := "Secret"
+			omitter, err := NewKubernetesResourceOmitter(&tc.apiVersion, &kind, tc.namespaces, tc.names, tc.labelSelector, tc.annotations)
+			require.NoError(t, err)
+
+			omit, reason, err := omitKubeResourceWithReason(omitter, &kube.ResourceListWithPath{ResourceList: kube.ResourceList{Items: []kube.Resource{resource}}})
+			require.NoError(t, err)
+			assert.True(t, omit)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+}
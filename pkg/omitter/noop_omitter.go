@@ -13,11 +13,30 @@ func (n *NoopOmitter) OmitPath(path string) (bool, error) {
 	return false, nil
 }
 
-func (n *NoopOmitter) OmitKubeResource(resourceList *kube.ResourceListWithPath) (bool, error) {
-	n.Paths = append(n.Paths, resourceList.Path)
+func (n *NoopOmitter) OmitKubeResourceItem(path string, resource kube.Resource) (bool, error) {
 	return false, nil
 }
 
-func (n *NoopOmitter) Report() []string {
-	return n.Paths
+func (n *NoopOmitter) ReportOmittedItems(path string, omitted []kube.Resource, total int) {
+}
+
+func (n *NoopOmitter) LineFilter(path string) LineFilter {
+	return nil
+}
+
+func (n *NoopOmitter) ReportOmittedLines(path string, count int) {
+}
+
+func (n *NoopOmitter) Restore(omissions []Omission) {
+	for _, o := range omissions {
+		n.Paths = append(n.Paths, o.Path)
+	}
+}
+
+func (n *NoopOmitter) Report() []Omission {
+	var omissions []Omission
+	for _, p := range n.Paths {
+		omissions = append(omissions, Omission{Path: p})
+	}
+	return omissions
 }
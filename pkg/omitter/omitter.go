@@ -14,16 +14,58 @@ type KubernetesResourceOmitter interface {
 	OmitKubeResource(resourceList *kube.ResourceListWithPath) (bool, error)
 }
 
+// KubernetesResourceItemOmitter is the interface for a type which determines whether the individual resources of a file should be omitted,
+// these are the items of a List or the documents of a multi-document yaml, or the single resource of any other file.
+type KubernetesResourceItemOmitter interface {
+	// OmitKubeResourceItem takes a single resource of the file at path and returns whether it should be omitted, without reporting it.
+	OmitKubeResourceItem(path string, resource kube.Resource) (bool, error)
+	// ReportOmittedItems reports the omitted resources once the whole file at path was read, total is the number of resources in the file.
+	ReportOmittedItems(path string, omitted []kube.Resource, total int)
+}
+
+// Includer is the interface for a type which determines the files and k8s resources that are kept, everything else is omitted.
+type Includer interface {
+	// IncludePath takes the relative path of the file and its return indicates if the file is kept.
+	IncludePath(path string) (bool, error)
+	// IncludeKubeResource returns whether the resource is kept.
+	IncludeKubeResource(resource kube.Resource) bool
+}
+
+// LineOmitter is the interface for a type which determines whether individual lines of a text file should be omitted.
+type LineOmitter interface {
+	// LineFilter returns a filter for the lines of the file at path, or nil if none of its lines should be omitted.
+	LineFilter(path string) LineFilter
+}
+
+// LineFilter decides on the lines of a single file, which must be passed in order. It is not safe for concurrent use.
+type LineFilter interface {
+	// OmitLine returns whether the line should be omitted.
+	OmitLine(line string) bool
+}
+
+// OmissionLookup is implemented by omitters that can tell why a file was omitted entirely, for example to write a placeholder for it.
+type OmissionLookup interface {
+	// OmissionOf returns the omission of the whole file at path, the path must be given as it was reported.
+	OmissionOf(path string) (Omission, bool)
+}
+
 // Omitter is the interface for all kinds of omissions.
 type Omitter interface {
 	FileOmitter
-	KubernetesResourceOmitter
+	KubernetesResourceItemOmitter
+	LineOmitter
+
+	// ReportOmittedLines reports the number of lines omitted from the file at path once it was read entirely.
+	ReportOmittedLines(path string, count int)
 }
 
 // ReportingOmitter adds reporting functionality to all omitters.
 type ReportingOmitter interface {
 	Omitter
 
-	// Report should return all paths that were omitted
-	Report() []string
+	// Report should return all files, resources and lines that were omitted
+	Report() []Omission
+
+	// Restore adds the omissions of an earlier run to the report, like those of the files that were completed before a run was resumed.
+	Restore(omissions []Omission)
 }
@@ -1,73 +1,415 @@
 package omitter
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
 )
 
+// Rule identifies the omit rule of the configuration an omitter was created from.
+type Rule struct {
+	// Index is the position of the rule in the omit section of the configuration.
+	Index int             `json:"index"`
+	Type  schema.OmitType `json:"type"`
+}
+
+const notIncludedReason = "not included by any Include rule"
+
+// Omission is a single entry of the omission report, it is either a whole file, a resource of a file or a number of lines of a file.
+type Omission struct {
+	Path string `json:"path"`
+	// Item is the namespace/name of an omitted resource, when the other resources of its file were kept.
+	Item string `json:"item,omitempty"`
+	// Kind is the apiVersion/kind of the omitted resource, it is only set together with Item.
+	Kind string `json:"kind,omitempty"`
+	// Lines is the number of omitted lines, when the rest of the file was kept.
+	Lines int `json:"lines,omitempty"`
+	// Rule is the rule that caused the omission, it is nil when the omitter was not created from a rule or no single rule is responsible.
+	Rule *Rule `json:"rule,omitempty"`
+	// Reason describes why the rule matched, for example the pattern or the detected file type.
+	Reason string `json:"reason,omitempty"`
+	// Size is the size of an omitted file in bytes.
+	Size int64 `json:"size,omitempty"`
+}
+
+// String formats the omission as "path", "path#namespace/name" or "path (n lines)", followed by the reason in brackets if any.
+func (o Omission) String() string {
+	s := o.Path
+	switch {
+	case o.Item != "":
+		s += "#" + o.Item
+	case o.Lines > 0:
+		s += fmt.Sprintf(" (%d lines)", o.Lines)
+	}
+	if o.Reason != "" {
+		s += fmt.Sprintf(" (%s)", o.Reason)
+	}
+	return s
+}
+
+type ruleFileOmitter struct {
+	FileOmitter
+	rule Rule
+}
+
+type ruleKubernetesResourceOmitter struct {
+	KubernetesResourceOmitter
+	rule Rule
+}
+
+// WithFileRule attaches the rule to a file omitter, it is reported with all omissions of the omitter.
+func WithFileRule(o FileOmitter, rule Rule) FileOmitter {
+	return &ruleFileOmitter{FileOmitter: o, rule: rule}
+}
+
+// WithKubernetesResourceRule attaches the rule to a k8s omitter, it is reported with all omissions of the omitter.
+func WithKubernetesResourceRule(o KubernetesResourceOmitter, rule Rule) KubernetesResourceOmitter {
+	return &ruleKubernetesResourceOmitter{KubernetesResourceOmitter: o, rule: rule}
+}
+
+type ruleIncluder struct {
+	Includer
+	rule Rule
+}
+
+// WithIncluderRule attaches the rule to an includer, it is reported with everything that is not included. As all Include rules are
+// combined into a single includer, the first of them should be given.
+func WithIncluderRule(includer Includer, rule Rule) Includer {
+	return &ruleIncluder{Includer: includer, rule: rule}
+}
+
+type fileOmitterWithRule struct {
+	omitter FileOmitter
+	rule    *Rule
+}
+
+type k8sOmitterWithRule struct {
+	omitter KubernetesResourceOmitter
+	rule    *Rule
+}
+
+// MultiReportingOmitter combines all omitters, the first one to omit a file or resource is the final decision. When an Includer is set,
+// everything that it doesn't include is omitted before any of the omitters are asked, so the omitters can only narrow down further
+// what is included. Negated path omitters are exceptions, a file matching any of them is never omitted by a path omitter.
 type MultiReportingOmitter struct {
-	fileOmitters []FileOmitter
-	k8sOmitters  []KubernetesResourceOmitter
+	inputFolder    string
+	includer       Includer
+	includerRule   *Rule
+	fileOmitters   []fileOmitterWithRule
+	pathExceptions []*pathOmitter
+	k8sOmitters    []k8sOmitterWithRule
 
-	omittedPathsLock sync.Mutex
-	omittedPaths     []string
+	omissionsLock sync.Mutex
+	omissions     []Omission
+	// fileOmissions are the indices of the omissions of whole files by their path
+	fileOmissions map[string]int
+	// pendingItems are the omissions of the resources of a file until the whole file was read
+	pendingItems map[string][]Omission
+	// lineFilters are the filters of the files that are currently read, they count the omitted lines per rule
+	lineFilters map[string][]*countingLineFilter
 }
 
 func (m *MultiReportingOmitter) OmitPath(path string) (bool, error) {
+	if m.includer != nil {
+		include, err := m.includer.IncludePath(path)
+		if err != nil {
+			return false, err
+		}
+
+		if !include {
+			m.appendUnderLock(Omission{Path: path, Rule: m.includerRule, Reason: notIncludedReason, Size: m.fileSize(filepath.Join(m.inputFolder, path))})
+			return true, nil
+		}
+	}
+
 	for _, o := range m.fileOmitters {
-		omit, err := o.OmitPath(path)
+		omit, reason, err := omitPathWithReason(o.omitter, path)
 		if err != nil {
 			return false, err
 		}
 
 		if omit {
-			m.appendUnderLock(path)
+			if _, ok := o.omitter.(*pathOmitter); ok {
+				keep, err := m.isPathException(path)
+				if err != nil {
+					return false, err
+				}
+				if keep {
+					continue
+				}
+			}
+			m.appendUnderLock(Omission{Path: path, Rule: o.rule, Reason: reason, Size: m.fileSize(filepath.Join(m.inputFolder, path))})
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func (m *MultiReportingOmitter) OmitKubeResource(resourceList *kube.ResourceListWithPath) (bool, error) {
+// reasoningFileOmitter is implemented by file omitters that can explain their decision, like the pattern that matched or the size of a
+// file. The reason is recorded in the report next to the omitted path.
+type reasoningFileOmitter interface {
+	omitPathWithReason(path string) (bool, string, error)
+}
+
+func omitPathWithReason(o FileOmitter, path string) (bool, string, error) {
+	if r, ok := o.(reasoningFileOmitter); ok {
+		return r.omitPathWithReason(path)
+	}
+	omit, err := o.OmitPath(path)
+	return omit, "", err
+}
+
+func (m *MultiReportingOmitter) isPathException(path string) (bool, error) {
+	for _, e := range m.pathExceptions {
+		keep, err := e.keepPath(path)
+		if err != nil || keep {
+			return keep, err
+		}
+	}
+	return false, nil
+}
+
+// fileSize returns the size of the file, or zero if it can't be determined.
+func (m *MultiReportingOmitter) fileSize(path string) int64 {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
+
+// OmitKubeResourceItem returns whether the resource is omitted by any of the k8s omitters. The rule that omitted it is remembered until
+// the omitted items of the whole file are reported.
+func (m *MultiReportingOmitter) OmitKubeResourceItem(path string, resource kube.Resource) (bool, error) {
+	if m.includer != nil && !m.includer.IncludeKubeResource(resource) {
+		m.appendPendingItem(path, Omission{Rule: m.includerRule, Reason: notIncludedReason})
+		return true, nil
+	}
+
+	item := &kube.ResourceListWithPath{
+		ResourceList: kube.ResourceList{Items: []kube.Resource{resource}},
+		Path:         path,
+	}
 	for _, o := range m.k8sOmitters {
-		omit, err := o.OmitKubeResource(resourceList)
+		omit, reason, err := omitKubeResourceWithReason(o.omitter, item)
 		if err != nil {
 			return false, err
 		}
 
 		if omit {
-			m.appendUnderLock(resourceList.Path)
+			m.appendPendingItem(path, Omission{Rule: o.rule, Reason: reason})
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func (m *MultiReportingOmitter) Report() []string {
-	m.omittedPathsLock.Lock()
-	defer m.omittedPathsLock.Unlock()
+// reasoningKubernetesResourceOmitter is implemented by k8s omitters that can explain their decision, like the kind and name that matched.
+// The reason is recorded in the report next to the omitted resource.
+type reasoningKubernetesResourceOmitter interface {
+	omitKubeResourceWithReason(resourceList *kube.ResourceListWithPath) (bool, string, error)
+}
+
+func omitKubeResourceWithReason(o KubernetesResourceOmitter, resourceList *kube.ResourceListWithPath) (bool, string, error) {
+	if r, ok := o.(reasoningKubernetesResourceOmitter); ok {
+		return r.omitKubeResourceWithReason(resourceList)
+	}
+	omit, err := o.OmitKubeResource(resourceList)
+	return omit, "", err
+}
+
+// ReportOmittedItems reports each omitted resource with its namespace/name when only some of the resources of the file were omitted.
+// When all of them were omitted, only the path is reported. The omitted resources must be given in the order they were omitted.
+func (m *MultiReportingOmitter) ReportOmittedItems(path string, omitted []kube.Resource, total int) {
+	m.omissionsLock.Lock()
+	pending := m.pendingItems[path]
+	delete(m.pendingItems, path)
+	m.omissionsLock.Unlock()
+
+	if len(omitted) == 0 {
+		return
+	}
+
+	if len(omitted) == total {
+		omission := Omission{Path: path, Size: m.fileSize(filepath.Join(m.inputFolder, path))}
+		// the rule is only reported if it is the same for all resources, its reason only if that is the same as well
+		for i := range pending {
+			if i == 0 {
+				omission.Rule, omission.Reason = pending[i].Rule, pending[i].Reason
+			} else if !sameRule(omission.Rule, pending[i].Rule) {
+				omission.Rule, omission.Reason = nil, ""
+				break
+			} else if omission.Reason != pending[i].Reason {
+				omission.Reason = ""
+			}
+		}
+		m.appendUnderLock(omission)
+		return
+	}
+	for i, r := range omitted {
+		omission := Omission{Path: path, Item: r.ID(), Kind: r.ApiVersion + "/" + r.Kind}
+		if i < len(pending) {
+			omission.Rule, omission.Reason = pending[i].Rule, pending[i].Reason
+		}
+		m.appendUnderLock(omission)
+	}
+}
+
+func sameRule(a, b *Rule) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// LineFilter combines the line filters of all k8s omitters that also omit lines, like the time range omitter does for log files.
+func (m *MultiReportingOmitter) LineFilter(path string) LineFilter {
+	var filters multiLineFilter
+	for _, o := range m.k8sOmitters {
+		if lineOmitter, ok := o.omitter.(LineOmitter); ok {
+			if f := lineOmitter.LineFilter(path); f != nil {
+				filters = append(filters, &countingLineFilter{filter: f, rule: o.rule})
+			}
+		}
+	}
+
+	if len(filters) == 0 {
+		return nil
+	}
+	m.omissionsLock.Lock()
+	defer m.omissionsLock.Unlock()
+	m.lineFilters[path] = filters
+	return filters
+}
+
+// ReportOmittedLines reports the file with the number of lines that each rule omitted, when any of its lines were omitted.
+func (m *MultiReportingOmitter) ReportOmittedLines(path string, count int) {
+	m.omissionsLock.Lock()
+	filters := m.lineFilters[path]
+	delete(m.lineFilters, path)
+	m.omissionsLock.Unlock()
+
+	if count <= 0 {
+		return
+	}
+	if len(filters) == 0 {
+		m.appendUnderLock(Omission{Path: path, Lines: count})
+		return
+	}
+	for _, f := range filters {
+		if f.omitted > 0 {
+			m.appendUnderLock(Omission{Path: path, Lines: f.omitted, Rule: f.rule})
+		}
+	}
+}
+
+func (m *MultiReportingOmitter) Report() []Omission {
+	m.omissionsLock.Lock()
+	defer m.omissionsLock.Unlock()
 
-	var copySlice []string
-	for i := 0; i < len(m.omittedPaths); i++ {
-		copySlice = append(copySlice, m.omittedPaths[i])
+	var copySlice []Omission
+	for i := 0; i < len(m.omissions); i++ {
+		copySlice = append(copySlice, m.omissions[i])
 	}
 
 	return copySlice
 }
 
-func (m *MultiReportingOmitter) appendUnderLock(path string) {
-	m.omittedPathsLock.Lock()
-	defer m.omittedPathsLock.Unlock()
+func (m *MultiReportingOmitter) Restore(omissions []Omission) {
+	for _, o := range omissions {
+		m.appendUnderLock(o)
+	}
+}
+
+func (m *MultiReportingOmitter) appendUnderLock(omission Omission) {
+	m.omissionsLock.Lock()
+	defer m.omissionsLock.Unlock()
+
+	if omission.Item == "" && omission.Lines == 0 {
+		m.fileOmissions[omission.Path] = len(m.omissions)
+	}
+	m.omissions = append(m.omissions, omission)
+}
+
+// OmissionOf returns the omission of the whole file at path.
+func (m *MultiReportingOmitter) OmissionOf(path string) (Omission, bool) {
+	m.omissionsLock.Lock()
+	defer m.omissionsLock.Unlock()
+
+	i, ok := m.fileOmissions[path]
+	if !ok {
+		return Omission{}, false
+	}
+	return m.omissions[i], true
+}
+
+func (m *MultiReportingOmitter) appendPendingItem(path string, omission Omission) {
+	m.omissionsLock.Lock()
+	defer m.omissionsLock.Unlock()
 
-	m.omittedPaths = append(m.omittedPaths, path)
+	m.pendingItems[path] = append(m.pendingItems[path], omission)
 }
 
-func NewMultiReportingOmitter(fileOmitters []FileOmitter, k8sOmitters []KubernetesResourceOmitter) ReportingOmitter {
-	return &MultiReportingOmitter{
-		fileOmitters:     fileOmitters,
-		k8sOmitters:      k8sOmitters,
-		omittedPathsLock: sync.Mutex{},
-		omittedPaths:     []string{},
+// countingLineFilter counts the lines omitted by a single rule.
+type countingLineFilter struct {
+	filter  LineFilter
+	rule    *Rule
+	omitted int
+}
+
+type multiLineFilter []*countingLineFilter
+
+// OmitLine passes the line to every filter, so that all of them can keep track of their state. The line is counted for the first
+// filter that omits it.
+func (m multiLineFilter) OmitLine(line string) bool {
+	omit := false
+	for _, f := range m {
+		if f.filter.OmitLine(line) {
+			if !omit {
+				f.omitted++
+			}
+			omit = true
+		}
+	}
+	return omit
+}
+
+// NewMultiReportingOmitter creates an omitter from all given omitters, the includer is optional and can be nil to include everything.
+// The inputFolder is used to determine the size of omitted files. Omitters created with WithFileRule or WithKubernetesResourceRule
+// report their rule with each omission.
+func NewMultiReportingOmitter(inputFolder string, includer Includer, fileOmitters []FileOmitter, k8sOmitters []KubernetesResourceOmitter) ReportingOmitter {
+	m := &MultiReportingOmitter{
+		inputFolder:   inputFolder,
+		includer:      includer,
+		omissionsLock: sync.Mutex{},
+		omissions:     []Omission{},
+		pendingItems:  map[string][]Omission{},
+		lineFilters:   map[string][]*countingLineFilter{},
+		fileOmissions: map[string]int{},
+	}
+	if r, ok := includer.(*ruleIncluder); ok {
+		m.includer, m.includerRule = r.Includer, &r.rule
+	}
+	for _, o := range fileOmitters {
+		entry := fileOmitterWithRule{omitter: o}
+		if r, ok := o.(*ruleFileOmitter); ok {
+			entry = fileOmitterWithRule{omitter: r.FileOmitter, rule: &r.rule}
+		}
+		if p, ok := entry.omitter.(*pathOmitter); ok && p.negated {
+			m.pathExceptions = append(m.pathExceptions, p)
+		}
+		m.fileOmitters = append(m.fileOmitters, entry)
+	}
+	for _, o := range k8sOmitters {
+		entry := k8sOmitterWithRule{omitter: o}
+		if r, ok := o.(*ruleKubernetesResourceOmitter); ok {
+			entry = k8sOmitterWithRule{omitter: r.KubernetesResourceOmitter, rule: &r.rule}
+		}
+		m.k8sOmitters = append(m.k8sOmitters, entry)
 	}
+	return m
 }
@@ -1,15 +1,18 @@
 package omitter
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestOmitPathSingle(t *testing.T) {
-	omitter := NewMultiReportingOmitter([]FileOmitter{testingFileOmitterWithPattern(t, "*.log")}, []KubernetesResourceOmitter{})
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{testingFileOmitterWithPattern(t, "*.log")}, []KubernetesResourceOmitter{})
 
 	omit, err := omitter.OmitPath("some.log")
 	require.NoError(t, err)
@@ -19,11 +22,11 @@ func TestOmitPathSingle(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, omit, "some.dog should not be omitted")
 
-	assert.Equal(t, []string{"some.log"}, omitter.Report())
+	assert.Equal(t, []string{"some.log (matches '*.log')"}, reportStrings(omitter))
 }
 
 func TestOmitPathMulti(t *testing.T) {
-	omitter := NewMultiReportingOmitter([]FileOmitter{
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{
 		testingFileOmitterWithPattern(t, "something/not/quite/*/log"),
 		testingFileOmitterWithPattern(t, "something/not/quite/b/*"),
 	}, []KubernetesResourceOmitter{})
@@ -43,43 +46,147 @@ func TestOmitPathMulti(t *testing.T) {
 	omit, err = omitter.OmitPath("something/not/quite/*/logs")
 	require.NoError(t, err)
 	assert.False(t, omit, "\"something/not/quite/*/logs\" should not be omitted")
-	assert.Equal(t, []string{"something/not/quite/a/log", "something/not/quite/b/anything"}, omitter.Report())
+	assert.Equal(t, []string{"something/not/quite/a/log (matches 'something/not/quite/*/log')", "something/not/quite/b/anything (matches 'something/not/quite/b/*')"}, reportStrings(omitter))
 }
 
-func TestOmitK8s(t *testing.T) {
-	omitter := NewMultiReportingOmitter([]FileOmitter{}, []KubernetesResourceOmitter{testingK8sResourceOmitter(t)})
+func TestOmitPathWithExceptions(t *testing.T) {
+	pathRegex, err := NewPathRegexOmitter(`!^namespaces/openshift-etcd/`)
+	require.NoError(t, err)
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{
+		testingFileOmitterWithPattern(t, "namespaces/*/pods/**"),
+		testingFileOmitterWithPattern(t, "!namespaces/openshift-apiserver/**"),
+		testingFileOmitterWithPattern(t, "**/*.log"),
+		pathRegex,
+	}, []KubernetesResourceOmitter{})
 
-	omit, err := omitter.OmitKubeResource(&kube.ResourceListWithPath{
-		ResourceList: kube.ResourceList{
-			Items: []kube.Resource{
-				{
-					ApiVersion: "v1",
-					Kind:       "kind",
-				},
-			},
-		},
-		Path: "some.path",
-	})
+	for _, tc := range []struct {
+		path     string
+		expected bool
+	}{
+		{path: "namespaces/default/pods/a/a.yaml", expected: true},
+		{path: "namespaces/openshift-etcd/pods/etcd-0/etcd-0.yaml", expected: false},
+		{path: "namespaces/openshift-etcd/pods/etcd-0/etcd/etcd/logs/current.log", expected: false},
+		{path: "namespaces/openshift-apiserver/pods/apiserver-0/apiserver-0.yaml", expected: false},
+		{path: "namespaces/default/core/configmaps.yaml", expected: false},
+		{path: "host_service_logs/masters/kubelet_service.log", expected: true},
+	} {
+		omit, err := omitter.OmitPath(tc.path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, omit, tc.path)
+	}
+	assert.Equal(t, []string{"namespaces/default/pods/a/a.yaml (matches 'namespaces/*/pods/**')", "host_service_logs/masters/kubelet_service.log (matches '**/*.log')"}, reportStrings(omitter))
+}
 
+func TestOmitPathWithReason(t *testing.T) {
+	tmpInputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, "core.1234"), make([]byte, 2048), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, "small.log"), []byte("text"), 0644))
+	maxSize, err := NewMaxSizeOmitter(tmpInputDir, "1KiB")
 	require.NoError(t, err)
-	assert.True(t, omit, "v1 resource should be omitted")
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{testingFileOmitterWithPattern(t, "*.log"), maxSize}, []KubernetesResourceOmitter{})
+
+	for _, path := range []string{"core.1234", "small.log"} {
+		omit, err := omitter.OmitPath(path)
+		require.NoError(t, err)
+		assert.True(t, omit, path)
+	}
+	assert.Equal(t, []string{"core.1234 (2048 bytes exceed the maximum size of 1KiB)", "small.log (matches '*.log')"}, reportStrings(omitter))
+}
 
-	omit, err = omitter.OmitKubeResource(&kube.ResourceListWithPath{
-		ResourceList: kube.ResourceList{
-			Items: []kube.Resource{
-				{
-					ApiVersion: "v2",
-					Kind:       "kind",
-				},
-			},
-		},
-		Path: "some.other.path",
-	})
+func TestOmitK8sItems(t *testing.T) {
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{}, []KubernetesResourceOmitter{testingK8sResourceOmitter(t)})
 
+	items := []kube.Resource{
+		{ApiVersion: "v1", Kind: "kind", Metadata: kube.Metadata{Name: "first", Namespace: "default"}},
+		{ApiVersion: "v2", Kind: "kind", Metadata: kube.Metadata{Name: "second"}},
+		{ApiVersion: "v1", Kind: "kind", Metadata: kube.Metadata{Name: "third"}},
+	}
+	var omitted []kube.Resource
+	for _, r := range items {
+		omit, err := omitter.OmitKubeResourceItem("some.path", r)
+		require.NoError(t, err)
+		if omit {
+			omitted = append(omitted, r)
+		}
+	}
+	assert.Equal(t, []kube.Resource{items[0], items[2]}, omitted)
+	assert.Empty(t, reportStrings(omitter), "items must not be reported before the whole file was read")
+	omitter.ReportOmittedItems("some.path", omitted, len(items))
+
+	single := kube.Resource{ApiVersion: "v1", Kind: "kind", Metadata: kube.Metadata{Name: "first"}}
+	omit, err := omitter.OmitKubeResourceItem("some.other.path", single)
 	require.NoError(t, err)
-	assert.False(t, omit, "v2 resource should not be omitted")
+	assert.True(t, omit)
+	omitter.ReportOmittedItems("some.other.path", []kube.Resource{single}, 1)
+	omitter.ReportOmittedItems("not.omitted.path", nil, 3)
 
-	assert.Equal(t, []string{"some.path"}, omitter.Report())
+	assert.Equal(t, []string{
+		"some.path#default/first (matches kind 'v1/kind')", "some.path#third (matches kind 'v1/kind')", "some.other.path (matches kind 'v1/kind')",
+	}, reportStrings(omitter))
+}
+
+func TestOmitLines(t *testing.T) {
+	since, err := NewTimeRangeOmitter(pString("2023-04-12T10:00:00Z"), nil, nil)
+	require.NoError(t, err)
+	until, err := NewTimeRangeOmitter(nil, pString("2023-04-12T12:00:00Z"), nil)
+	require.NoError(t, err)
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{}, []KubernetesResourceOmitter{testingK8sResourceOmitter(t), since, until})
+
+	assert.Nil(t, omitter.LineFilter("some.yaml"))
+	filter := omitter.LineFilter("some.log")
+	require.NotNil(t, filter)
+	assert.True(t, filter.OmitLine("2023-04-12T09:00:00Z before\n"))
+	assert.False(t, filter.OmitLine("2023-04-12T11:00:00Z within\n"))
+	assert.True(t, filter.OmitLine("2023-04-12T13:00:00Z after\n"))
+
+	omitter.ReportOmittedLines("some.log", 2)
+	omitter.ReportOmittedLines("other.log", 0)
+	assert.Equal(t, []string{"some.log (1 lines)", "some.log (1 lines)"}, reportStrings(omitter), "each filter reports the lines it omitted")
+}
+
+func TestOmitRestore(t *testing.T) {
+	omitter := NewMultiReportingOmitter("", nil, []FileOmitter{testingFileOmitterWithPattern(t, "*.log")}, []KubernetesResourceOmitter{})
+	omitter.Restore([]Omission{{Path: "earlier.log", Reason: "matches '*.log'"}, {Path: "earlier.txt", Lines: 3}})
+
+	omit, err := omitter.OmitPath("some.log")
+	require.NoError(t, err)
+	assert.True(t, omit)
+	assert.Equal(t, []string{"earlier.log (matches '*.log')", "earlier.txt (3 lines)", "some.log (matches '*.log')"}, reportStrings(omitter))
+}
+
+func TestOmitWithIncluder(t *testing.T) {
+	includer, err := NewNamespaceIncluder([]string{"default"}, []string{"*.log"})
+	require.NoError(t, err)
+	omitter := NewMultiReportingOmitter("", includer, []FileOmitter{testingFileOmitterWithPattern(t, "namespaces/default/core/secrets.yaml")}, []KubernetesResourceOmitter{testingK8sResourceOmitter(t)})
+
+	for _, tc := range []struct {
+		path     string
+		expected bool
+	}{
+		{path: "namespaces/default/core/pods.yaml", expected: false},
+		{path: "namespaces/default/core/secrets.yaml", expected: true},
+		{path: "namespaces/other/core/pods.yaml", expected: true},
+		{path: "kubelet.log", expected: false},
+	} {
+		omit, err := omitter.OmitPath(tc.path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, omit, tc.path)
+	}
+
+	for _, tc := range []struct {
+		resource kube.Resource
+		expected bool
+	}{
+		{resource: kube.Resource{ApiVersion: "v2", Kind: "kind", Metadata: kube.Metadata{Name: "a", Namespace: "default"}}, expected: false},
+		{resource: kube.Resource{ApiVersion: "v1", Kind: "kind", Metadata: kube.Metadata{Name: "b", Namespace: "default"}}, expected: true},
+		{resource: kube.Resource{ApiVersion: "v2", Kind: "kind", Metadata: kube.Metadata{Name: "c", Namespace: "other"}}, expected: true},
+	} {
+		omit, err := omitter.OmitKubeResourceItem("some.path", tc.resource)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, omit, tc.resource.Metadata.Name)
+	}
+
+	assert.Equal(t, []string{"namespaces/default/core/secrets.yaml (matches 'namespaces/default/core/secrets.yaml')", "namespaces/other/core/pods.yaml (not included by any Include rule)"}, reportStrings(omitter))
 }
 
 func testingFileOmitterWithPattern(t *testing.T, pattern string) FileOmitter {
@@ -91,7 +198,68 @@ func testingFileOmitterWithPattern(t *testing.T, pattern string) FileOmitter {
 func testingK8sResourceOmitter(t *testing.T) KubernetesResourceOmitter {
 	v1 := "v1"
 	rKind := "kind"
-	omitter, err := NewKubernetesResourceOmitter(&v1, &rKind, []string{})
+	omitter, err := NewKubernetesResourceOmitter(&v1, &rKind, []string{}, nil, nil, nil)
 	require.NoError(t, err)
 	return omitter
 }
+
+func TestOmissionRules(t *testing.T) {
+	tmpInputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, "some.log"), []byte("text"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpInputDir, "secrets.yaml"), []byte("kind: List"), 0644))
+	includer, err := NewNamespaceIncluder([]string{"default"}, []string{"*"})
+	require.NoError(t, err)
+	since, err := NewTimeRangeOmitter(pString("2023-04-12T10:00:00Z"), nil, nil)
+	require.NoError(t, err)
+	omitter := NewMultiReportingOmitter(tmpInputDir, WithIncluderRule(includer, Rule{Index: 0, Type: schema.OmitTypeInclude}),
+		[]FileOmitter{WithFileRule(testingFileOmitterWithPattern(t, "*.log"), Rule{Index: 1, Type: schema.OmitTypeFile})},
+		[]KubernetesResourceOmitter{
+			WithKubernetesResourceRule(testingK8sResourceOmitter(t), Rule{Index: 2, Type: schema.OmitTypeKubernetes}),
+			WithKubernetesResourceRule(since, Rule{Index: 3, Type: schema.OmitTypeTimeRange}),
+		})
+
+	omit, err := omitter.OmitPath("some.log")
+	require.NoError(t, err)
+	assert.True(t, omit)
+
+	items := []kube.Resource{
+		{ApiVersion: "v1", Kind: "kind", Metadata: kube.Metadata{Name: "a", Namespace: "default"}},
+		{ApiVersion: "v1", Kind: "Pod", Metadata: kube.Metadata{Name: "b", Namespace: "other"}},
+		{ApiVersion: "v1", Kind: "Pod", Metadata: kube.Metadata{Name: "c", Namespace: "default"}},
+	}
+	var omitted []kube.Resource
+	for _, r := range items {
+		omit, err := omitter.OmitKubeResourceItem("secrets.yaml", r)
+		require.NoError(t, err)
+		if omit {
+			omitted = append(omitted, r)
+		}
+	}
+	omitter.ReportOmittedItems("secrets.yaml", omitted, len(items))
+
+	omit, err = omitter.OmitKubeResourceItem("secrets.yaml", items[0])
+	require.NoError(t, err)
+	assert.True(t, omit)
+	omitter.ReportOmittedItems("secrets.yaml", items[:1], 1)
+
+	filter := omitter.LineFilter("other.log")
+	require.NotNil(t, filter)
+	assert.True(t, filter.OmitLine("2023-04-12T09:00:00Z before\n"))
+	omitter.ReportOmittedLines("other.log", 1)
+
+	assert.Equal(t, []Omission{
+		{Path: "some.log", Rule: &Rule{Index: 1, Type: schema.OmitTypeFile}, Reason: "matches '*.log'", Size: 4},
+		{Path: "secrets.yaml", Item: "default/a", Kind: "v1/kind", Rule: &Rule{Index: 2, Type: schema.OmitTypeKubernetes}, Reason: "matches kind 'v1/kind'"},
+		{Path: "secrets.yaml", Item: "other/b", Kind: "v1/Pod", Rule: &Rule{Index: 0, Type: schema.OmitTypeInclude}, Reason: "not included by any Include rule"},
+		{Path: "secrets.yaml", Rule: &Rule{Index: 2, Type: schema.OmitTypeKubernetes}, Reason: "matches kind 'v1/kind'", Size: 10},
+		{Path: "other.log", Lines: 1, Rule: &Rule{Index: 3, Type: schema.OmitTypeTimeRange}},
+	}, omitter.Report())
+}
+
+func reportStrings(omitter ReportingOmitter) []string {
+	var report []string
+	for _, o := range omitter.Report() {
+		report = append(report, o.String())
+	}
+	return report
+}
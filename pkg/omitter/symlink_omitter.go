@@ -20,6 +20,14 @@ func (s symlinkOmitter) OmitPath(path string) (bool, error) {
 	return fsutil.IsSymbolicLink(stat), nil
 }
 
+func (s symlinkOmitter) omitPathWithReason(path string) (bool, string, error) {
+	omit, err := s.OmitPath(path)
+	if err != nil || !omit {
+		return false, "", err
+	}
+	return true, "symbolic link", nil
+}
+
 func NewSymlinkOmitter(inputFolder string) FileOmitter {
 	return symlinkOmitter{inputFolder: inputFolder}
 }
@@ -0,0 +1,169 @@
+package omitter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+)
+
+const auditTimestampKey = `"requestReceivedTimestamp":"`
+
+var (
+	// klog lines start with the severity and "mmdd hh:mm:ss.uuuuuu", for example "I0412 10:20:30.123456"
+	klogTimestamp = regexp.MustCompile(`^[IWEF](\d{4} \d{2}:\d{2}:\d{2}(?:\.\d+)?)\s`)
+	// journald lines in the default short format start with "Mmm dd hh:mm:ss", for example "Apr 12 10:20:30"
+	journaldTimestamp = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?)\s`)
+	// rfc3339Layouts includes the numeric zone without a colon as used by journald's short-iso format
+	rfc3339Layouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999-0700"}
+)
+
+type timeRangeOmitter struct {
+	since *time.Time
+	until *time.Time
+	// reference is used to determine the year of timestamps that don't contain it
+	reference time.Time
+	// isLog is optional, it tells about the log files that are neither .log nor .gz files
+	isLog func(path string) bool
+}
+
+// OmitKubeResource omits Events that only occurred outside the time range, all other resources are kept.
+func (t *timeRangeOmitter) OmitKubeResource(resourceList *kube.ResourceListWithPath) (bool, error) {
+	for _, r := range resourceList.Items {
+		if r.Kind != "Event" {
+			continue
+		}
+		if last, err := time.Parse(time.RFC3339Nano, r.LastOccurrence()); err == nil && t.since != nil && last.Before(*t.since) {
+			return true, nil
+		}
+		if first, err := time.Parse(time.RFC3339Nano, r.FirstOccurrence()); err == nil && t.until != nil && first.After(*t.until) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// LineFilter returns a filter for .log and .gz files and all other log files that omits the lines with a timestamp outside the time range.
+func (t *timeRangeOmitter) LineFilter(path string) LineFilter {
+	if !strings.HasSuffix(path, ".log") && !strings.HasSuffix(path, ".gz") && (t.isLog == nil || !t.isLog(path)) {
+		return nil
+	}
+	return &timeRangeLineFilter{omitter: t}
+}
+
+func (t *timeRangeOmitter) contains(timestamp time.Time) bool {
+	if t.since != nil && timestamp.Before(*t.since) {
+		return false
+	}
+	if t.until != nil && timestamp.After(*t.until) {
+		return false
+	}
+	return true
+}
+
+// timestamp returns the time at the beginning of a log line, or the time the request of an audit log line was received.
+func (t *timeRangeOmitter) timestamp(line string) (time.Time, bool) {
+	if i := strings.Index(line, auditTimestampKey); i >= 0 {
+		value := line[i+len(auditTimestampKey):]
+		if end := strings.IndexByte(value, '"'); end >= 0 {
+			if ts, err := time.Parse(time.RFC3339Nano, value[:end]); err == nil {
+				return ts, true
+			}
+		}
+	}
+
+	line = strings.TrimLeft(line, " \t")
+	if field, _, _ := strings.Cut(line, " "); field != "" {
+		for _, layout := range rfc3339Layouts {
+			if ts, err := time.Parse(layout, strings.TrimRight(field, "\r\n")); err == nil {
+				return ts, true
+			}
+		}
+	}
+
+	if m := klogTimestamp.FindStringSubmatch(line); m != nil {
+		if ts, err := time.Parse("0102 15:04:05.999999999", m[1]); err == nil {
+			return t.withYear(ts), true
+		}
+	}
+	if m := journaldTimestamp.FindStringSubmatch(line); m != nil {
+		if ts, err := time.Parse("Jan _2 15:04:05.999999999", m[1]); err == nil {
+			return t.withYear(ts), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// withYear sets the year of a timestamp without one, out of the adjacent years the one closest to the time range is chosen.
+func (t *timeRangeOmitter) withYear(ts time.Time) time.Time {
+	var closest time.Time
+	var closestDistance time.Duration
+	for year := t.reference.Year() - 1; year <= t.reference.Year()+1; year++ {
+		candidate := ts.AddDate(year, 0, 0)
+		distance := candidate.Sub(t.reference)
+		if distance < 0 {
+			distance = -distance
+		}
+		if closest.IsZero() || distance < closestDistance {
+			closest, closestDistance = candidate, distance
+		}
+	}
+	return closest
+}
+
+// timeRangeLineFilter keeps lines without a timestamp, for example stack traces, together with the preceding line.
+type timeRangeLineFilter struct {
+	omitter *timeRangeOmitter
+	omit    bool
+}
+
+func (f *timeRangeLineFilter) OmitLine(line string) bool {
+	if ts, ok := f.omitter.timestamp(line); ok {
+		f.omit = !f.omitter.contains(ts)
+	}
+	return f.omit
+}
+
+// NewTimeRangeOmitter returns an omitter which omits log lines and Events outside the time range between since and until,
+// both are RFC3339 timestamps and either of them can be left open. The lines of .log and .gz files are filtered, isLog is optional and
+// can tell about further log files, like the journals of a sosreport. It is given the same path as LineFilter.
+func NewTimeRangeOmitter(since, until *string, isLog func(path string) bool) (KubernetesResourceOmitter, error) {
+	parse := func(name string, value *string) (*time.Time, error) {
+		if value == nil || *value == "" {
+			return nil, nil
+		}
+		ts, err := time.Parse(time.RFC3339Nano, *value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s timestamp '%s': %w", name, *value, err)
+		}
+		return &ts, nil
+	}
+
+	sinceTime, err := parse("since", since)
+	if err != nil {
+		return nil, err
+	}
+	untilTime, err := parse("until", until)
+	if err != nil {
+		return nil, err
+	}
+
+	var reference time.Time
+	switch {
+	case sinceTime == nil && untilTime == nil:
+		return nil, errors.New("no since or until specified in timeRange omit")
+	case sinceTime == nil:
+		reference = *untilTime
+	case untilTime == nil:
+		reference = *sinceTime
+	case sinceTime.After(*untilTime):
+		return nil, fmt.Errorf("since '%s' is after until '%s'", *since, *until)
+	default:
+		reference = *sinceTime
+	}
+
+	return &timeRangeOmitter{since: sinceTime, until: untilTime, reference: reference, isLog: isLog}, nil
+}
@@ -0,0 +1,217 @@
+package omitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTimeRangeOmitter(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		since         *string
+		until         *string
+		expectedError string
+	}{
+		{
+			name:          "no bounds",
+			expectedError: "no since or until specified in timeRange omit",
+		},
+		{
+			name:          "empty bounds",
+			since:         pString(""),
+			until:         pString(""),
+			expectedError: "no since or until specified in timeRange omit",
+		},
+		{
+			name:  "only since",
+			since: pString("2023-04-12T10:00:00Z"),
+		},
+		{
+			name:  "only until",
+			until: pString("2023-04-12T10:00:00.123+02:00"),
+		},
+		{
+			name:          "invalid since",
+			since:         pString("2023-04-12"),
+			expectedError: "invalid since timestamp '2023-04-12': parsing time \"2023-04-12\" as \"2006-01-02T15:04:05.999999999Z07:00\": cannot parse \"\" as \"T\"",
+		},
+		{
+			name:          "since after until",
+			since:         pString("2023-04-12T10:00:00Z"),
+			until:         pString("2023-04-12T09:00:00Z"),
+			expectedError: "since '2023-04-12T10:00:00Z' is after until '2023-04-12T09:00:00Z'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTimeRangeOmitter(tc.since, tc.until, nil)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTimeRangeOmitterLines(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		since    *string
+		until    *string
+		lines    []string
+		expected []bool
+	}{
+		{
+			name:  "rfc3339 container logs",
+			since: pString("2023-04-12T10:00:00Z"),
+			until: pString("2023-04-12T12:00:00Z"),
+			lines: []string{
+				"2023-04-12T09:59:59.999999999Z stdout F before\n",
+				"2023-04-12T10:00:00.000000001Z stdout F within\n",
+				"2023-04-12T14:00:00+02:00 within with offset\n",
+				"2023-04-12T12:00:01Z after\n",
+			},
+			expected: []bool{true, false, false, true},
+		},
+		{
+			name:  "klog with continuation lines",
+			since: pString("2023-04-12T10:00:00Z"),
+			lines: []string{
+				"I0412 09:00:00.123456       1 controller.go:12] before\n",
+				"goroutine 1 [running]:\n",
+				"E0412 10:30:00.000000       1 controller.go:12] within\n",
+				"\tmain.go:12\n",
+			},
+			expected: []bool{true, true, false, false},
+		},
+		{
+			name:  "journald short and short-iso",
+			until: pString("2023-04-12T10:00:00Z"),
+			lines: []string{
+				"Apr 12 09:59:00 master-0 kubenswrapper[1234]: within\n",
+				"Apr  2 10:00:00.5 master-0 kubenswrapper[1234]: within\n",
+				"2023-04-12T10:00:01+0000 master-0 crio[12]: after\n",
+				"Apr 12 10:00:01 master-0 kubenswrapper[1234]: after\n",
+			},
+			expected: []bool{false, false, true, true},
+		},
+		{
+			name:  "audit log",
+			since: pString("2023-04-12T10:00:00Z"),
+			until: pString("2023-04-12T12:00:00Z"),
+			lines: []string{
+				`{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","requestReceivedTimestamp":"2023-04-12T09:00:00.000000Z","stageTimestamp":"2023-04-12T10:00:01.000000Z"}` + "\n",
+				`{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","requestReceivedTimestamp":"2023-04-12T11:00:00.000000Z"}` + "\n",
+			},
+			expected: []bool{true, false},
+		},
+		{
+			name:  "year of klog timestamps around new year",
+			since: pString("2022-12-31T23:00:00Z"),
+			until: pString("2023-01-01T01:00:00Z"),
+			lines: []string{
+				"I1231 22:59:59.000000       1 a.go:1] before\n",
+				"I1231 23:30:00.000000       1 a.go:1] within\n",
+				"I0101 00:30:00.000000       1 a.go:1] within\n",
+				"I0101 01:30:00.000000       1 a.go:1] after\n",
+			},
+			expected: []bool{true, false, false, true},
+		},
+		{
+			name:     "lines without any timestamp are kept",
+			since:    pString("2023-04-12T10:00:00Z"),
+			lines:    []string{"some text\n", "Starting server on 2023-04-12T09:00:00Z\n"},
+			expected: []bool{false, false},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			omitter, err := NewTimeRangeOmitter(tc.since, tc.until, nil)
+			require.NoError(t, err)
+
+			filter := omitter.(LineOmitter).LineFilter("namespaces/default/pods/a/a/logs/current.log")
+			require.NotNil(t, filter)
+			var actual []bool
+			for _, line := range tc.lines {
+				actual = append(actual, filter.OmitLine(line))
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestTimeRangeOmitterFiles(t *testing.T) {
+	omitter, err := NewTimeRangeOmitter(pString("2023-04-12T10:00:00Z"), nil, nil)
+	require.NoError(t, err)
+
+	lineOmitter := omitter.(LineOmitter)
+	assert.NotNil(t, lineOmitter.LineFilter("audit_logs/kube-apiserver/audit.log.gz"))
+	assert.NotNil(t, lineOmitter.LineFilter("nodes/master-0/journal.log"))
+	assert.Nil(t, lineOmitter.LineFilter("namespaces/default/core/pods.yaml"))
+	assert.Nil(t, lineOmitter.LineFilter("etcd_info/member_list.json"))
+	assert.Nil(t, lineOmitter.LineFilter("sos_commands/logs/journalctl_--no-pager"))
+
+	isLog := func(path string) bool {
+		return strings.HasPrefix(path, "sos_commands/logs/journalctl")
+	}
+	omitter, err = NewTimeRangeOmitter(pString("2023-04-12T10:00:00Z"), nil, isLog)
+	require.NoError(t, err)
+	lineOmitter = omitter.(LineOmitter)
+	assert.NotNil(t, lineOmitter.LineFilter("sos_commands/logs/journalctl_--no-pager"))
+	assert.NotNil(t, lineOmitter.LineFilter("var/log/pods/default_pod/container/0.log"))
+	assert.Nil(t, lineOmitter.LineFilter("sos_commands/networking/ip_-d_address"))
+}
+
+func TestTimeRangeOmitterEvents(t *testing.T) {
+	omitter, err := NewTimeRangeOmitter(pString("2023-04-12T10:00:00Z"), pString("2023-04-12T12:00:00Z"), nil)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		resource kube.Resource
+		expected bool
+	}{
+		{
+			name:     "event before",
+			resource: kube.Resource{ApiVersion: "v1", Kind: "Event", EventTimes: kube.EventTimes{FirstTimestamp: "2023-04-12T08:00:00Z", LastTimestamp: "2023-04-12T09:00:00Z"}},
+			expected: true,
+		},
+		{
+			name:     "event spanning the range",
+			resource: kube.Resource{ApiVersion: "v1", Kind: "Event", EventTimes: kube.EventTimes{FirstTimestamp: "2023-04-12T08:00:00Z", LastTimestamp: "2023-04-12T13:00:00Z"}},
+			expected: false,
+		},
+		{
+			name:     "events.k8s.io series within",
+			resource: kube.Resource{ApiVersion: "events.k8s.io/v1", Kind: "Event", EventTimes: kube.EventTimes{EventTime: "2023-04-12T08:00:00.000000Z", Series: kube.EventSeries{LastObservedTime: "2023-04-12T10:30:00.000000Z"}}},
+			expected: false,
+		},
+		{
+			name:     "event after by creation timestamp",
+			resource: kube.Resource{ApiVersion: "v1", Kind: "Event", Metadata: kube.Metadata{CreationTimestamp: "2023-04-12T12:00:01Z"}},
+			expected: true,
+		},
+		{
+			name:     "event without timestamps",
+			resource: kube.Resource{ApiVersion: "v1", Kind: "Event"},
+			expected: false,
+		},
+		{
+			name:     "other kinds are kept",
+			resource: kube.Resource{ApiVersion: "v1", Kind: "Pod", Metadata: kube.Metadata{CreationTimestamp: "2023-04-12T08:00:00Z"}},
+			expected: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			omit, err := omitter.OmitKubeResource(&kube.ResourceListWithPath{
+				ResourceList: kube.ResourceList{Items: []kube.Resource{tc.resource}},
+				Path:         "events.yaml",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, omit)
+		})
+	}
+}
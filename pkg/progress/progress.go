@@ -0,0 +1,124 @@
+// Package progress keeps track of the files of a traversal and periodically reports how far it got.
+package progress
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxInFlight is the number of the largest files in flight that are part of a snapshot.
+const maxInFlight = 3
+
+// Tracker counts the files and bytes of a traversal that are done, it is safe for concurrent use.
+type Tracker struct {
+	phase string
+	now   func() time.Time
+	start time.Time
+
+	mu         sync.Mutex
+	sizes      map[string]int64
+	inFlight   map[string]int64
+	totalBytes int64
+	files      int
+	bytes      int64
+}
+
+// File is a file in flight.
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Snapshot is the state of a traversal at a point in time.
+type Snapshot struct {
+	Phase          string  `json:"phase"`
+	Done           bool    `json:"done"`
+	Files          int     `json:"files"`
+	TotalFiles     int     `json:"totalFiles"`
+	Bytes          int64   `json:"bytes"`
+	TotalBytes     int64   `json:"totalBytes"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	// ETASeconds is only known once the first bytes are done
+	ETASeconds *float64 `json:"etaSeconds,omitempty"`
+	// InFlight are the largest files that are currently processed
+	InFlight []File `json:"inFlight"`
+}
+
+// NewTracker creates a tracker for a phase of the run, like the prescan or the actual cleaning.
+func NewTracker(phase string) *Tracker {
+	return newTracker(phase, time.Now)
+}
+
+func newTracker(phase string, now func() time.Time) *Tracker {
+	return &Tracker{
+		phase:    phase,
+		now:      now,
+		start:    now(),
+		sizes:    map[string]int64{},
+		inFlight: map[string]int64{},
+	}
+}
+
+// Add adds a file to the total, all files should be added before the first one starts.
+func (t *Tracker) Add(path string, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sizes[path] = size
+	t.totalBytes += size
+}
+
+// Start marks the file as in flight.
+func (t *Tracker) Start(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight[path] = t.sizes[path]
+}
+
+// Finish marks the file as done.
+func (t *Tracker) Finish(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.inFlight, path)
+	t.files++
+	t.bytes += t.sizes[path]
+}
+
+// Snapshot returns the current state, the throughput and the ETA are based on the bytes done since the tracker was created.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elapsed := t.now().Sub(t.start).Seconds()
+	s := Snapshot{
+		Phase:          t.phase,
+		Files:          t.files,
+		TotalFiles:     len(t.sizes),
+		Bytes:          t.bytes,
+		TotalBytes:     t.totalBytes,
+		ElapsedSeconds: elapsed,
+		InFlight:       []File{},
+	}
+	if elapsed > 0 {
+		s.BytesPerSecond = float64(t.bytes) / elapsed
+	}
+	if s.BytesPerSecond > 0 {
+		eta := float64(t.totalBytes-t.bytes) / s.BytesPerSecond
+		s.ETASeconds = &eta
+	}
+
+	for path, size := range t.inFlight {
+		s.InFlight = append(s.InFlight, File{Path: path, Size: size})
+	}
+	sort.Slice(s.InFlight, func(i, j int) bool {
+		if s.InFlight[i].Size != s.InFlight[j].Size {
+			return s.InFlight[i].Size > s.InFlight[j].Size
+		}
+		return s.InFlight[i].Path < s.InFlight[j].Path
+	})
+	if len(s.InFlight) > maxInFlight {
+		s.InFlight = s.InFlight[:maxInFlight]
+	}
+	return s
+}
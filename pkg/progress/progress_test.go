@@ -0,0 +1,161 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func pFloat(f float64) *float64 {
+	return &f
+}
+
+func TestTrackerSnapshot(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tracker := newTracker("clean", clock.Now)
+	tracker.Add("a.log", 1000)
+	tracker.Add("b.log", 3000)
+	tracker.Add("c.yaml", 500)
+	tracker.Add("d.yaml", 500)
+	tracker.Add("e.yaml", 5000)
+
+	assert.Equal(t, Snapshot{Phase: "clean", TotalFiles: 5, TotalBytes: 10000, InFlight: []File{}}, tracker.Snapshot())
+
+	for _, path := range []string{"a.log", "b.log", "c.yaml", "d.yaml"} {
+		tracker.Start(path)
+	}
+	tracker.Finish("a.log")
+	clock.now = clock.now.Add(2 * time.Second)
+
+	assert.Equal(t, Snapshot{
+		Phase:          "clean",
+		Files:          1,
+		TotalFiles:     5,
+		Bytes:          1000,
+		TotalBytes:     10000,
+		ElapsedSeconds: 2,
+		BytesPerSecond: 500,
+		ETASeconds:     pFloat(18),
+		InFlight: []File{
+			{Path: "b.log", Size: 3000},
+			{Path: "c.yaml", Size: 500},
+			{Path: "d.yaml", Size: 500},
+		},
+	}, tracker.Snapshot())
+}
+
+func TestFormatText(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		snapshot Snapshot
+		expected string
+	}{
+		{
+			name:     "no bytes done yet",
+			snapshot: Snapshot{Phase: "prescan", TotalFiles: 10, TotalBytes: 2500000, InFlight: []File{}},
+			expected: "prescan: 0/10 files, 0 B/2.5 MB (0%), 0 B/s",
+		},
+		{
+			name: "in progress",
+			snapshot: Snapshot{
+				Phase:          "clean",
+				Files:          120,
+				TotalFiles:     4711,
+				Bytes:          1200000000,
+				TotalBytes:     8900000000,
+				ElapsedSeconds: 26.5,
+				BytesPerSecond: 45300000,
+				ETASeconds:     pFloat(169.8),
+				InFlight:       []File{{Path: "audit/audit.log", Size: 800000000}, {Path: "b.log", Size: 1500}},
+			},
+			expected: "clean: 120/4711 files, 1.2 GB/8.9 GB (13%), 45.3 MB/s, ETA 2m50s, in flight: audit/audit.log (800.0 MB), b.log (1.5 kB)",
+		},
+		{
+			name:     "done",
+			snapshot: Snapshot{Phase: "clean", Done: true, Files: 2, TotalFiles: 2, Bytes: 999, TotalBytes: 999, ElapsedSeconds: 61.2, BytesPerSecond: 16.3, ETASeconds: pFloat(0), InFlight: []File{}},
+			expected: "clean: 2/2 files, 999 B/999 B (100%), 16 B/s, done in 1m1s",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatText(tc.snapshot))
+		})
+	}
+}
+
+func TestNewReporterFailsOnInvalidMode(t *testing.T) {
+	_, err := NewReporter("yaml", &bytes.Buffer{})
+	assert.EqualError(t, err, "invalid progress mode 'yaml', expected one of none, text or json")
+}
+
+func TestReporterTrack(t *testing.T) {
+	for _, tc := range []struct {
+		mode Mode
+		// check is called with the lines that were written
+		check func(t *testing.T, lines []string)
+	}{
+		{
+			mode: ModeNone,
+			check: func(t *testing.T, lines []string) {
+				assert.Empty(t, lines)
+			},
+		},
+		{
+			mode: ModeText,
+			check: func(t *testing.T, lines []string) {
+				require.NotEmpty(t, lines)
+				assert.Regexp(t, `^clean: 1/1 files, 42 B/42 B \(100%\), .*, done in 0s$`, lines[len(lines)-1])
+			},
+		},
+		{
+			mode: ModeJSON,
+			check: func(t *testing.T, lines []string) {
+				require.NotEmpty(t, lines)
+				for i, line := range lines {
+					var s Snapshot
+					require.NoError(t, json.Unmarshal([]byte(line), &s))
+					assert.Equal(t, i == len(lines)-1, s.Done)
+				}
+				var last map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+				assert.Equal(t, "clean", last["phase"])
+				assert.Equal(t, float64(1), last["files"])
+				assert.Equal(t, float64(42), last["totalBytes"])
+				assert.Equal(t, []interface{}{}, last["inFlight"])
+			},
+		},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			out := &bytes.Buffer{}
+			reporter, err := NewReporter(tc.mode, out)
+			require.NoError(t, err)
+			reporter.interval = time.Millisecond
+
+			tracker := NewTracker("clean")
+			tracker.Add("a.log", 42)
+			stop := reporter.Track(tracker)
+			tracker.Start("a.log")
+			time.Sleep(5 * time.Millisecond)
+			tracker.Finish("a.log")
+			stop()
+
+			var lines []string
+			if out.Len() > 0 {
+				lines = strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			}
+			tc.check(t, lines)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mode string
+
+const (
+	// ModeNone reports nothing.
+	ModeNone Mode = "none"
+	// ModeText reports a human-readable line, which is updated in place on a terminal.
+	ModeText Mode = "text"
+	// ModeJSON reports a JSON object per line for wrapping tools and UIs, the last one of each phase is marked as done.
+	ModeJSON Mode = "json"
+)
+
+const (
+	interval = time.Second
+	// logInterval is used for text that doesn't go to a terminal, to not flood the logs of long runs
+	logInterval = 10 * time.Second
+)
+
+// Reporter periodically writes the snapshots of trackers.
+type Reporter struct {
+	mode     Mode
+	out      io.Writer
+	terminal bool
+	interval time.Duration
+}
+
+// NewReporter creates a reporter that writes to out in the given mode.
+func NewReporter(mode Mode, out io.Writer) (*Reporter, error) {
+	r := &Reporter{mode: mode, out: out, interval: interval}
+	switch mode {
+	case ModeNone, ModeJSON:
+	case ModeText:
+		if f, ok := out.(*os.File); ok {
+			stat, err := f.Stat()
+			r.terminal = err == nil && stat.Mode()&os.ModeCharDevice != 0
+		}
+		if !r.terminal {
+			r.interval = logInterval
+		}
+	default:
+		return nil, fmt.Errorf("invalid progress mode '%s', expected one of %s, %s or %s", mode, ModeNone, ModeText, ModeJSON)
+	}
+	return r, nil
+}
+
+// Track reports the tracker until the returned function is called, which writes a last report that is marked as done.
+func (r *Reporter) Track(tracker *Tracker) func() {
+	if r.mode == ModeNone {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.write(tracker.Snapshot())
+			case <-stop:
+				s := tracker.Snapshot()
+				s.Done = true
+				r.write(s)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// write reports the snapshot, errors are ignored as the progress must never fail a run.
+func (r *Reporter) write(s Snapshot) {
+	if r.mode == ModeJSON {
+		line, err := json.Marshal(s)
+		if err == nil {
+			_, _ = fmt.Fprintf(r.out, "%s\n", line)
+		}
+		return
+	}
+
+	switch {
+	case !r.terminal:
+		_, _ = fmt.Fprintf(r.out, "%s\n", formatText(s))
+	case s.Done:
+		_, _ = fmt.Fprintf(r.out, "\r%s\033[K\n", formatText(s))
+	default:
+		_, _ = fmt.Fprintf(r.out, "\r%s\033[K", formatText(s))
+	}
+}
+
+// formatText formats a snapshot as a single line, for example:
+//
+//	clean: 120/4711 files, 1.2 GB/8.9 GB (13%), 45.3 MB/s, ETA 2m50s, in flight: audit.log (800.0 MB)
+func formatText(s Snapshot) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d/%d files, %s/%s", s.Phase, s.Files, s.TotalFiles, formatBytes(s.Bytes), formatBytes(s.TotalBytes))
+	if s.TotalBytes > 0 {
+		fmt.Fprintf(&b, " (%d%%)", s.Bytes*100/s.TotalBytes)
+	}
+	fmt.Fprintf(&b, ", %s/s", formatBytes(int64(s.BytesPerSecond)))
+	switch {
+	case s.Done:
+		fmt.Fprintf(&b, ", done in %s", seconds(s.ElapsedSeconds))
+	case s.ETASeconds != nil:
+		fmt.Fprintf(&b, ", ETA %s", seconds(*s.ETASeconds))
+	}
+	for i, f := range s.InFlight {
+		if i == 0 {
+			b.WriteString(", in flight: ")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s (%s)", f.Path, formatBytes(f.Size))
+	}
+	return b.String()
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	for _, suffix := range []string{"kB", "MB", "GB", "TB"} {
+		value /= unit
+		if value < unit || suffix == "TB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return ""
+}
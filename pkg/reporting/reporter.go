@@ -2,10 +2,13 @@ package reporting
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
 	"github.com/openshift/must-gather-clean/pkg/schema"
 	"gopkg.in/yaml.v3"
 	"k8s.io/klog/v2"
@@ -22,9 +25,25 @@ type Occurrence struct {
 	Count    uint   `yaml:"count,omitempty"`
 }
 
+// Omission is an omitted file, resource or number of lines together with the omit rule that caused it.
+type Omission struct {
+	Path string `yaml:"path"`
+	// Item is the namespace/name of an omitted resource of a file that was otherwise kept.
+	Item string `yaml:"item,omitempty"`
+	Kind string `yaml:"kind,omitempty"`
+	// Lines is the number of omitted lines of a file that was otherwise kept.
+	Lines int `yaml:"lines,omitempty"`
+	// Rule is the index of the omit rule in the configuration.
+	Rule   *int            `yaml:"rule,omitempty"`
+	Type   schema.OmitType `yaml:"type,omitempty"`
+	Reason string          `yaml:"reason,omitempty"`
+	// Size is the size of an omitted file in bytes.
+	Size int64 `yaml:"size,omitempty"`
+}
+
 type Report struct {
 	Replacements [][]Replacement         `yaml:"replacements,omitempty"`
-	Omissions    []string                `yaml:"omissions,omitempty"`
+	Omissions    []Omission              `yaml:"omissions,omitempty"`
 	Config       schema.SchemaJsonConfig `yaml:"config,omitempty"`
 }
 
@@ -32,8 +51,11 @@ type Reporter interface {
 	// WriteReport writes the final report into the given path, will create folders if necessary.
 	WriteReport(path string) error
 
+	// WriteReportTo writes the final report as yaml to the given writer.
+	WriteReportTo(w io.Writer) error
+
 	// CollectOmitterReport collects the omitter's omission results.
-	CollectOmitterReport(omitter []string)
+	CollectOmitterReport(omissions []omitter.Omission)
 
 	// CollectObfuscatorReport will call the Report method on the obfuscator and collect the individual obfuscation results.
 	CollectObfuscatorReport(obfuscatorReport []obfuscator.ReplacementReport)
@@ -41,7 +63,7 @@ type Reporter interface {
 
 type SimpleReporter struct {
 	replacements [][]Replacement
-	omissions    []string
+	omissions    []Omission
 	config       *schema.SchemaJson
 }
 
@@ -59,13 +81,11 @@ func (s *SimpleReporter) WriteReport(path string) error {
 	if err != nil {
 		return fmt.Errorf("failed to open report file %s: %w", path, err)
 	}
+	defer func() {
+		_ = reportFile.Close()
+	}()
 
-	rEncoder := yaml.NewEncoder(reportFile)
-	err = rEncoder.Encode(Report{
-		Replacements: s.replacements,
-		Omissions:    s.omissions,
-		Config:       s.config.Config,
-	})
+	err = s.WriteReportTo(reportFile)
 	if err != nil {
 		return fmt.Errorf("failed to write report at %s: %w", path, err)
 	}
@@ -75,8 +95,50 @@ func (s *SimpleReporter) WriteReport(path string) error {
 	return nil
 }
 
-func (s *SimpleReporter) CollectOmitterReport(report []string) {
-	s.omissions = append(s.omissions, report...)
+func (s *SimpleReporter) WriteReportTo(w io.Writer) error {
+	return yaml.NewEncoder(w).Encode(Report{
+		Replacements: s.replacements,
+		Omissions:    s.omissions,
+		Config:       s.config.Config,
+	})
+}
+
+func (s *SimpleReporter) CollectOmitterReport(omissions []omitter.Omission) {
+	for _, o := range omissions {
+		omission := Omission{
+			Path:   o.Path,
+			Item:   o.Item,
+			Kind:   o.Kind,
+			Lines:  o.Lines,
+			Reason: o.Reason,
+			Size:   o.Size,
+		}
+		if o.Rule != nil {
+			index := o.Rule.Index
+			omission.Rule, omission.Type = &index, o.Rule.Type
+		}
+		s.omissions = append(s.omissions, omission)
+	}
+
+	// the omissions are reported in the order the workers found them, sorting keeps the report stable across runs
+	sort.SliceStable(s.omissions, func(i, j int) bool {
+		a, b := s.omissions[i], s.omissions[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Item != b.Item {
+			return a.Item < b.Item
+		}
+		return ruleIndex(a) < ruleIndex(b)
+	})
+}
+
+// ruleIndex returns the index of the rule of the omission, omissions without a rule are sorted first.
+func ruleIndex(o Omission) int {
+	if o.Rule == nil {
+		return -1
+	}
+	return *o.Rule
 }
 
 func (s *SimpleReporter) CollectObfuscatorReport(obfuscatorReport []obfuscator.ReplacementReport) {
@@ -90,12 +152,18 @@ func (s *SimpleReporter) CollectObfuscatorReport(obfuscatorReport []obfuscator.R
 					Count:    cnt,
 				})
 			}
+			sort.Slice(occurrences, func(i, j int) bool {
+				return occurrences[i].Original < occurrences[j].Original
+			})
 			replacements = append(replacements, Replacement{
 				Canonical:    r.Canonical,
 				ReplacedWith: r.ReplacedWith,
 				Occurrences:  occurrences,
 			})
 		}
+		sort.Slice(replacements, func(i, j int) bool {
+			return replacements[i].Canonical < replacements[j].Canonical
+		})
 		s.replacements = append(s.replacements, replacements)
 	}
 
@@ -112,10 +180,66 @@ func (s *SimpleReporter) CollectObfuscatorReport(obfuscatorReport []obfuscator.R
 	}
 }
 
+// ReadReport reads a report that was written by WriteReport.
+func ReadReport(path string) (*Report, error) {
+	reportFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open report file %s: %w", path, err)
+	}
+	defer reportFile.Close()
+
+	report := &Report{}
+	err = yaml.NewDecoder(reportFile).Decode(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report at %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// OmitterOmissions returns the omissions of the report like they were reported by the omitters.
+func (r *Report) OmitterOmissions() []omitter.Omission {
+	var omissions []omitter.Omission
+	for _, o := range r.Omissions {
+		omission := omitter.Omission{
+			Path:   o.Path,
+			Item:   o.Item,
+			Kind:   o.Kind,
+			Lines:  o.Lines,
+			Reason: o.Reason,
+			Size:   o.Size,
+		}
+		if o.Rule != nil {
+			omission.Rule = &omitter.Rule{Index: *o.Rule, Type: o.Type}
+		}
+		omissions = append(omissions, omission)
+	}
+	return omissions
+}
+
+// ObfuscatorStates returns the replacements of the report as the states of the obfuscators, to continue with the same replacements. The
+// counts of all occurrences are zero.
+func (r *Report) ObfuscatorStates() []obfuscator.State {
+	states := make([]obfuscator.State, len(r.Replacements))
+	for i, replacements := range r.Replacements {
+		for _, replacement := range replacements {
+			counter := map[string]uint{}
+			for _, o := range replacement.Occurrences {
+				counter[o.Original] = 0
+			}
+			states[i].Replacements = append(states[i].Replacements, obfuscator.Replacement{
+				Canonical:    replacement.Canonical,
+				ReplacedWith: replacement.ReplacedWith,
+				Counter:      counter,
+			})
+		}
+	}
+	return states
+}
+
 func NewSimpleReporter(config *schema.SchemaJson) Reporter {
 	return &SimpleReporter{
 		replacements: [][]Replacement{},
-		omissions:    []string{},
+		omissions:    []Omission{},
 		config:       config,
 	}
 }
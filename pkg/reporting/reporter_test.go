@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
 	"github.com/openshift/must-gather-clean/pkg/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,7 +33,10 @@ func TestReportingHappyPath(t *testing.T) {
 		},
 	}
 	r := NewSimpleReporter(config)
-	r.CollectOmitterReport([]string{"some path"})
+	r.CollectOmitterReport([]omitter.Omission{
+		{Path: "some path", Rule: &omitter.Rule{Index: 0, Type: schema.OmitTypeFile}, Reason: "matches 'random-pattern'", Size: 1234},
+		{Path: "some.log", Lines: 12},
+	})
 	multiObfuscator := obfuscator.NewMultiObfuscator([]obfuscator.ReportingObfuscator{
 		obfuscator.NoopObfuscator{Replacements: map[string]string{
 			"this": "that",
@@ -58,11 +62,34 @@ func TestReportingHappyPath(t *testing.T) {
 			{Replacement{Canonical: "this", ReplacedWith: "that", Occurrences: []Occurrence{{Original: "this", Count: 1}}}},
 			{Replacement{Canonical: "another", ReplacedWith: "something", Occurrences: []Occurrence{{Original: "another", Count: 1}}}},
 		},
-		Omissions: []string{"some path"},
-		Config:    config.Config,
+		Omissions: []Omission{
+			{Path: "some path", Rule: pInt(0), Type: schema.OmitTypeFile, Reason: "matches 'random-pattern'", Size: 1234},
+			{Path: "some.log", Lines: 12},
+		},
+		Config: config.Config,
 	})
 }
 
+func TestReadReport(t *testing.T) {
+	omissions := []omitter.Omission{
+		{Path: "some path", Rule: &omitter.Rule{Index: 1, Type: schema.OmitTypeFile}, Reason: "matches 'random-pattern'", Size: 1234},
+		{Path: "some.log", Lines: 12},
+		{Path: "some.yaml", Item: "default/secret", Kind: "v1/Secret", Rule: &omitter.Rule{Index: 0, Type: schema.OmitTypeKubernetes}},
+	}
+	r := NewSimpleReporter(&schema.SchemaJson{})
+	r.CollectOmitterReport(omissions)
+	r.CollectObfuscatorReport(nil)
+	reportFile := filepath.Join(t.TempDir(), "report.yaml")
+	require.NoError(t, r.WriteReport(reportFile))
+
+	report, err := ReadReport(reportFile)
+	require.NoError(t, err)
+	assert.Equal(t, omissions, report.OmitterOmissions())
+
+	_, err = ReadReport(filepath.Join(t.TempDir(), "report.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func assertReportMatches(t *testing.T, file string, expectedReport Report) {
 	bytes, err := os.ReadFile(file)
 	require.NoError(t, err)
@@ -75,3 +102,7 @@ func assertReportMatches(t *testing.T, file string, expectedReport Report) {
 	assert.Equal(t, expectedReport.Replacements, actualReport.Replacements)
 	assert.Equal(t, expectedReport.Config, actualReport.Config)
 }
+
+func pInt(i int) *int {
+	return &i
+}
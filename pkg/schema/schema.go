@@ -15,6 +15,11 @@ type Obfuscate struct {
 	// order.
 	ExactReplacements []ObfuscateExactReplacementsElem `json:"exactReplacements,omitempty" yaml:"exactReplacements,omitempty"`
 
+	// When enabled, the role (e.g. master or worker) and the availability zone
+	// contained in a hostname are kept in its replacement, only used with the type
+	// Hostname obfuscator.
+	KeepHostnameStructure *bool `json:"keepHostnameStructure,omitempty" yaml:"keepHostnameStructure,omitempty"`
+
 	// when replacementType 'Regex' is used, the supplied Golang regexp
 	// (https://pkg.go.dev/regexp) will be used to detect the string that should be
 	// replaced. The regex is line based, spanning multi-line regex statements is not
@@ -44,6 +49,11 @@ type Obfuscate struct {
 	// static replacement where a detected mac address will be replaced by 'x'. Regex
 	// should be used with the 'regex' property that will define the regex, here the
 	// replacement also will be static by 'x'-ing out the matched string.
+	// ClusterIdentity discovers the infrastructure name, API server hosts, cluster
+	// ID, base domain and node names from the resources in the must-gather and
+	// replaces them wherever they occur. Hostname discovers the names of nodes,
+	// machines and bare metal hosts from the resources in the must-gather and
+	// replaces them wherever they occur.
 	Type ObfuscateType `json:"type" yaml:"type"`
 }
 
@@ -77,26 +87,89 @@ const ObfuscateTargetFilePath ObfuscateTarget = "FilePath"
 type ObfuscateType string
 
 const ObfuscateTypeAzureResources ObfuscateType = "AzureResources"
+const ObfuscateTypeClusterIdentity ObfuscateType = "ClusterIdentity"
 const ObfuscateTypeDomain ObfuscateType = "Domain"
 const ObfuscateTypeExact ObfuscateType = "Exact"
+const ObfuscateTypeHostname ObfuscateType = "Hostname"
 const ObfuscateTypeIP ObfuscateType = "IP"
 const ObfuscateTypeKeywords ObfuscateType = "Keywords"
 const ObfuscateTypeMAC ObfuscateType = "MAC"
 const ObfuscateTypeRegex ObfuscateType = "Regex"
 
 type Omit struct {
+	// A regular expression as described in https://pkg.go.dev/regexp/syntax, files
+	// with any line matching it are omitted. For example 'BEGIN .*PRIVATE KEY'.
+	ContainsRegex *string `json:"containsRegex,omitempty" yaml:"containsRegex,omitempty"`
+
+	// The types of files which are omitted, detected by the content of the file
+	// regardless of its name. ELF are executables and libraries, Core are ELF core
+	// dumps, Pcap are network captures in the pcap and pcapng format and Image are
+	// all files with an image MIME type.
+	FileTypes []OmitFileTypesElem `json:"fileTypes,omitempty" yaml:"fileTypes,omitempty"`
+
+	// Everything that is not included by any rule of type Include is omitted.
+	// Included files and resources can still be omitted by all other rules.
+	Include *OmitInclude `json:"include,omitempty" yaml:"include,omitempty"`
+
 	// KubernetesResource corresponds to the JSON schema field "kubernetesResource".
 	KubernetesResource *OmitKubernetesResource `json:"kubernetesResource,omitempty" yaml:"kubernetesResource,omitempty"`
 
+	// Files larger than this size are omitted. The size is a number of bytes with an
+	// optional unit, for example '500KB', '10MB' or '1GiB'.
+	MaxSize *string `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
+
+	// A regular expression on file paths relative to the must-gather root, as
+	// described in https://pkg.go.dev/regexp/syntax. A leading '!' negates the
+	// expression, matching files are never omitted by rules of type File or
+	// PathRegex.
+	PathRegex *string `json:"pathRegex,omitempty" yaml:"pathRegex,omitempty"`
+
 	// A file glob pattern on file paths relative to the must-gather root. The pattern
-	// should be as described in https://pkg.go.dev/path/filepath#Match
+	// should be as described in https://pkg.go.dev/path#Match, additionally a '**'
+	// path segment matches any number of directories. A leading '!' negates the
+	// pattern, matching files are never omitted by rules of type File or PathRegex.
 	Pattern *string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
 
+	// TimeRange corresponds to the JSON schema field "timeRange".
+	TimeRange *OmitTimeRange `json:"timeRange,omitempty" yaml:"timeRange,omitempty"`
+
 	// Type corresponds to the JSON schema field "type".
 	Type OmitType `json:"type" yaml:"type"`
 }
 
+type OmitFileTypesElem string
+
+const OmitFileTypesElemCore OmitFileTypesElem = "Core"
+const OmitFileTypesElemELF OmitFileTypesElem = "ELF"
+const OmitFileTypesElemImage OmitFileTypesElem = "Image"
+const OmitFileTypesElemPcap OmitFileTypesElem = "Pcap"
+
+// Everything that is not included by any rule of type Include is omitted. Included
+// files and resources can still be omitted by all other rules.
+type OmitInclude struct {
+	// This defines the namespaces which are kept, including their
+	// 'namespaces/<namespace>/' directories and pod logs. The namespaces can be file
+	// glob patterns as described in https://pkg.go.dev/path#Match, for example
+	// 'openshift-*'.
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+
+	// File glob patterns on file paths relative to the must-gather root which are
+	// kept, for example for cluster scoped resources. The patterns support the same
+	// syntax as the 'pattern' of type File.
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns,omitempty"`
+}
+
+// This defines annotations the resources must have to be omitted. The values can
+// be file glob patterns as described in https://pkg.go.dev/path#Match, use '*' to
+// match any value.
+type OmitKubernetesResourceAnnotations map[string]string
+
 type OmitKubernetesResource struct {
+	// This defines annotations the resources must have to be omitted. The values can
+	// be file glob patterns as described in https://pkg.go.dev/path#Match, use '*' to
+	// match any value.
+	Annotations OmitKubernetesResourceAnnotations `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+
 	// This defines the apiVersion of the kubernetes resource. That can be used to
 	// further refine specific versions of a resource that should be omitted.
 	ApiVersion *string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
@@ -105,12 +178,33 @@ type OmitKubernetesResource struct {
 	// be further specified with the apiVersion and namespaces.
 	Kind *string `json:"kind,omitempty" yaml:"kind,omitempty"`
 
+	// This defines a label selector the resources must match to be omitted.
+	// Equality-based and set-based requirements can be combined with commas like in
+	// kubectl, for example 'customer-data=true,environment in (production,
+	// staging),!keep'.
+	LabelSelector *string `json:"labelSelector,omitempty" yaml:"labelSelector,omitempty"`
+
+	// This defines the names of the resources which are supposed to be omitted. The
+	// names can be file glob patterns as described in https://pkg.go.dev/path#Match,
+	// for example 'customer-*'.
+	Names []string `json:"names,omitempty" yaml:"names,omitempty"`
+
 	// This defines the namespaces which are supposed to be omitted. When used
 	// together with kind and apiVersions, it becomes a filter. Standalone it will be
 	// used as a filter for all resources in a given namespace.
 	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
 }
 
+type OmitTimeRange struct {
+	// RFC3339 timestamp, for example '2023-04-01T10:00:00Z'. Log lines and events
+	// before this time are omitted.
+	Since *string `json:"since,omitempty" yaml:"since,omitempty"`
+
+	// RFC3339 timestamp, for example '2023-04-01T12:00:00Z'. Log lines and events
+	// after this time are omitted.
+	Until *string `json:"until,omitempty" yaml:"until,omitempty"`
+}
+
 type OmitType string
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -135,8 +229,10 @@ func (j *OmitType) UnmarshalJSON(b []byte) error {
 
 var enumValues_ObfuscateType = []interface{}{
 	"AzureResources",
+	"ClusterIdentity",
 	"Domain",
 	"Exact",
+	"Hostname",
 	"IP",
 	"Keywords",
 	"MAC",
@@ -221,6 +317,12 @@ var enumValues_OmitType = []interface{}{
 	"Kubernetes",
 	"File",
 	"SymbolicLink",
+	"TimeRange",
+	"Include",
+	"PathRegex",
+	"MaxSize",
+	"FileType",
+	"ContainsRegex",
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -246,6 +348,39 @@ func (j *ObfuscateType) UnmarshalJSON(b []byte) error {
 const OmitTypeKubernetes OmitType = "Kubernetes"
 const OmitTypeFile OmitType = "File"
 const OmitTypeSymbolicLink OmitType = "SymbolicLink"
+const OmitTypeInclude OmitType = "Include"
+const OmitTypeTimeRange OmitType = "TimeRange"
+const OmitTypePathRegex OmitType = "PathRegex"
+const OmitTypeMaxSize OmitType = "MaxSize"
+const OmitTypeFileType OmitType = "FileType"
+const OmitTypeContainsRegex OmitType = "ContainsRegex"
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *OmitFileTypesElem) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var ok bool
+	for _, expected := range enumValues_OmitFileTypesElem {
+		if reflect.DeepEqual(v, expected) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("invalid value (expected one of %#v): %#v", enumValues_OmitFileTypesElem, v)
+	}
+	*j = OmitFileTypesElem(v)
+	return nil
+}
+
+var enumValues_OmitFileTypesElem = []interface{}{
+	"ELF",
+	"Core",
+	"Pcap",
+	"Image",
+}
 
 // UnmarshalJSON implements json.Unmarshaler.
 func (j *ObfuscateExactReplacementsElem) UnmarshalJSON(b []byte) error {
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package statefile
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive lock on the file, it is released when the file is closed.
+func lock(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
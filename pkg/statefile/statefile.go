@@ -0,0 +1,123 @@
+// Package statefile keeps the replacements of the pipe mode in a file between invocations, so that separate invocations obfuscate the same
+// originals with the same replacements.
+package statefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+)
+
+// state is the content of a state file. It contains the original values of all replacements.
+type state struct {
+	// ConfigDigest is the digest of the configuration the state was written with, empty for the default obfuscators without a configuration
+	ConfigDigest string `json:"configDigest"`
+	// Obfuscators are the states of the obfuscators in the order of the configuration.
+	Obfuscators []obfuscator.State `json:"obfuscators"`
+}
+
+// File is a locked state file. The lock is held until Close, invocations that share a state file only hold it while they load and save
+// their states, see Update.
+type File struct {
+	path string
+	lock *os.File
+}
+
+// Open locks the state file at path, it waits for the invocation that holds the lock. The lock is taken on a separate file next to the
+// state file with the suffix .lock, as the state file is replaced on every save.
+func Open(path string) (*File, error) {
+	lockFile, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock of state file: %w", err)
+	}
+	err = lock(lockFile)
+	if err != nil {
+		_ = lockFile.Close()
+		return nil, fmt.Errorf("failed to lock state file %s: %w", path, err)
+	}
+	return &File{path: path, lock: lockFile}, nil
+}
+
+// Load returns the states of the obfuscators, or nil if the state file does not exist yet. The state must have been written with the
+// configuration of the given digest.
+func (f *File) Load(configDigest string) ([]obfuscator.State, error) {
+	contents, err := os.ReadFile(f.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	s := &state{}
+	err = json.Unmarshal(contents, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state file at %s: %w", f.path, err)
+	}
+	if s.ConfigDigest != configDigest {
+		return nil, fmt.Errorf("the state file at %s was written with a different config", f.path)
+	}
+	return s.Obfuscators, nil
+}
+
+// Save replaces the state file with the states of the obfuscators. The state file is replaced only once the new one was written
+// completely, an interrupted save keeps the previous state.
+func (f *File) Save(configDigest string, obfuscators []obfuscator.State) error {
+	contents, err := json.Marshal(state{ConfigDigest: configDigest, Obfuscators: obfuscators})
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+	_, err = tmpFile.Write(contents)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	err = os.Rename(tmpFile.Name(), f.path)
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// Close releases the lock of the state file.
+func (f *File) Close() error {
+	return f.lock.Close()
+}
+
+// Update locks the state file at path while update runs, it receives the loaded states or nil if the state file does not exist yet. The
+// states returned by update are saved, nothing is saved when it returns nil.
+func Update(path string, configDigest string, update func(states []obfuscator.State) ([]obfuscator.State, error)) error {
+	f, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	states, err := f.Load(configDigest)
+	if err != nil {
+		return err
+	}
+	states, err = update(states)
+	if err != nil || states == nil {
+		return err
+	}
+	return f.Save(configDigest, states)
+}
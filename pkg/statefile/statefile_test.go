@@ -0,0 +1,117 @@
+package statefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	states := []obfuscator.State{{
+		Replacements: []obfuscator.Replacement{{Canonical: "10.0.0.1", ReplacedWith: "x-ipv4-0000000001-x", Counter: map[string]uint{"10.0.0.1": 1}}},
+		Counters:     map[string]int64{"x-ipv4-%010d-x": 1},
+	}}
+
+	f, err := Open(path)
+	require.NoError(t, err)
+	loaded, err := f.Load("digest")
+	require.NoError(t, err)
+	assert.Nil(t, loaded)
+	require.NoError(t, f.Save("digest", states))
+	require.NoError(t, f.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "only the state file and its lock remain")
+
+	f, err = Open(path)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+	loaded, err = f.Load("digest")
+	require.NoError(t, err)
+	assert.Equal(t, states, loaded)
+
+	_, err = f.Load("other")
+	assert.EqualError(t, err, "the state file at "+path+" was written with a different config")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+
+	f, err := Open(path)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+	_, err = f.Load("")
+	assert.ErrorContains(t, err, "failed to parse state file at "+path)
+}
+
+func TestOpenWaitsForLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	first, err := Open(path)
+	require.NoError(t, err)
+
+	opened := make(chan *File)
+	go func() {
+		second, err := Open(path)
+		assert.NoError(t, err)
+		opened <- second
+	}()
+
+	select {
+	case <-opened:
+		t.Fatal("the state file was opened while it was locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.NoError(t, first.Close())
+	second := <-opened
+	require.NotNil(t, second)
+	require.NoError(t, second.Close())
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	states := []obfuscator.State{{Counters: map[string]int64{"x-ipv4-%010d-x": 1}}}
+
+	var loaded []obfuscator.State
+	require.NoError(t, Update(path, "digest", func(s []obfuscator.State) ([]obfuscator.State, error) {
+		loaded = s
+		return nil, nil
+	}))
+	assert.Nil(t, loaded)
+	assert.NoFileExists(t, path, "nothing is saved without states")
+
+	require.NoError(t, Update(path, "digest", func([]obfuscator.State) ([]obfuscator.State, error) {
+		return states, nil
+	}))
+	err := Update(path, "digest", func(s []obfuscator.State) ([]obfuscator.State, error) {
+		loaded = s
+		return nil, errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, states, loaded)
+
+	err = Update(path, "other", func(s []obfuscator.State) ([]obfuscator.State, error) {
+		return s, nil
+	})
+	assert.EqualError(t, err, "the state file at "+path+" was written with a different config")
+
+	// the lock is released after each update
+	f, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
@@ -0,0 +1,293 @@
+// Package suggester discovers cluster specific names and domains in a must-gather and proposes a starter configuration from them.
+package suggester
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"gopkg.in/yaml.v3"
+	"k8s.io/klog/v2"
+)
+
+const (
+	infrastructureNameReplacement = "obfuscated-infra-id"
+	clusterNameReplacement        = "obfuscated-cluster-name"
+	// shorter cluster names are likely to be found as substrings of unrelated words, for example "ocp" or "test"
+	minClusterNameLength = 5
+)
+
+// resourcePlurals are the file or folder names under which the resources of interest are stored in a must-gather,
+// this avoids parsing every single (potentially huge) yaml file in the input.
+var resourcePlurals = map[string]struct{}{
+	"dnses":                      {},
+	"ingresses":                  {},
+	"proxies":                    {},
+	"infrastructures":            {},
+	"imagecontentsourcepolicies": {},
+	"imagedigestmirrorsets":      {},
+	"imagetagmirrorsets":         {},
+}
+
+// noProxyDefaults are the entries OpenShift always adds to the noProxy list, they are not specific to a cluster.
+var noProxyDefaults = map[string]struct{}{
+	"localhost":     {},
+	"svc":           {},
+	"cluster.local": {},
+}
+
+// ClusterDetails contains the cluster specific information discovered in a must-gather.
+type ClusterDetails struct {
+	// Domains maps every discovered domain to a description of where it was found.
+	Domains map[string]string
+	// InfrastructureName is the infrastructure ID of the cluster, usually the cluster name with a random suffix.
+	InfrastructureName string
+	// ClusterName is the name given to the cluster on installation.
+	ClusterName string
+}
+
+// DiscoverClusterDetails walks the must-gather at inputPath and collects domains and names from the DNS, Ingress, Proxy, Infrastructure,
+// ImageContentSourcePolicy, ImageDigestMirrorSet and ImageTagMirrorSet resources.
+func DiscoverClusterDetails(inputPath string) (*ClusterDetails, error) {
+	details := &ClusterDetails{Domains: map[string]string{}}
+	err := filepath.WalkDir(inputPath, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if dirEntry.IsDir() || !isCandidate(inputPath, path) {
+			return nil
+		}
+
+		objects, err := kube.ReadObjectsFromPath(path)
+		if err != nil {
+			if errors.Is(err, kube.NoKubernetesResourceError) {
+				return nil
+			}
+			return err
+		}
+		for _, o := range objects {
+			details.collect(o)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover cluster details in %s: %w", inputPath, err)
+	}
+
+	return details, nil
+}
+
+func isCandidate(inputPath string, path string) bool {
+	relPath, err := filepath.Rel(inputPath, path)
+	if err != nil {
+		relPath = path
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if _, ok := resourcePlurals[strings.TrimSuffix(segment, filepath.Ext(segment))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ClusterDetails) collect(o kube.Object) {
+	source := fmt.Sprintf("%s/%s", o.Kind(), o.Name())
+	switch o.Kind() {
+	case "DNS":
+		c.addDomain(o.NestedString("spec", "baseDomain"), source+" spec.baseDomain")
+	case "Ingress":
+		// the networking.k8s.io Ingress shares the kind, but has no spec.domain
+		c.addDomain(o.NestedString("spec", "domain"), source+" spec.domain")
+		c.addDomain(o.NestedString("spec", "appsDomain"), source+" spec.appsDomain")
+	case "Proxy":
+		for _, section := range []string{"spec", "status"} {
+			for _, field := range []string{"httpProxy", "httpsProxy"} {
+				c.addDomain(o.NestedURLHost(section, field), fmt.Sprintf("%s %s.%s", source, section, field))
+			}
+			for _, entry := range strings.Split(o.NestedString(section, "noProxy"), ",") {
+				entry = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(entry), "*"), ".")
+				if _, ok := noProxyDefaults[entry]; ok {
+					continue
+				}
+				c.addDomain(entry, fmt.Sprintf("%s %s.noProxy", source, section))
+			}
+		}
+	case "Infrastructure":
+		c.addDomain(o.NestedURLHost("status", "apiServerURL"), source+" status.apiServerURL")
+		c.addDomain(o.NestedURLHost("status", "apiServerInternalURI"), source+" status.apiServerInternalURI")
+		c.addDomain(o.NestedString("status", "etcdDiscoveryDomain"), source+" status.etcdDiscoveryDomain")
+		if name := o.NestedString("status", "infrastructureName"); name != "" {
+			c.InfrastructureName = name
+			// the infrastructure name is generated from the cluster name, truncated and suffixed with a dash and five random characters
+			if i := strings.LastIndex(name, "-"); i > 0 && len(name)-i == 6 {
+				c.ClusterName = name[:i]
+			}
+		}
+	case "ImageContentSourcePolicy":
+		for _, m := range o.NestedObjects("spec", "repositoryDigestMirrors") {
+			for _, mirror := range m.NestedStringSlice("mirrors") {
+				c.addDomain(registryHostOf(mirror), source+" spec.repositoryDigestMirrors")
+			}
+		}
+	case "ImageDigestMirrorSet":
+		for _, m := range o.NestedObjects("spec", "imageDigestMirrors") {
+			for _, mirror := range m.NestedStringSlice("mirrors") {
+				c.addDomain(registryHostOf(mirror), source+" spec.imageDigestMirrors")
+			}
+		}
+	case "ImageTagMirrorSet":
+		for _, m := range o.NestedObjects("spec", "imageTagMirrors") {
+			for _, mirror := range m.NestedStringSlice("mirrors") {
+				c.addDomain(registryHostOf(mirror), source+" spec.imageTagMirrors")
+			}
+		}
+	}
+}
+
+// addDomain records the domain if it looks like a fully qualified domain name. IPs are skipped, since they are covered by the IP obfuscator.
+func (c *ClusterDetails) addDomain(domain string, source string) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if domain == "" || !strings.Contains(domain, ".") || net.ParseIP(domain) != nil {
+		return
+	}
+	if _, _, err := net.ParseCIDR(domain); err == nil {
+		return
+	}
+	if _, ok := c.Domains[domain]; ok {
+		return
+	}
+	klog.V(2).Infof("discovered domain %s in %s", domain, source)
+	c.Domains[domain] = source
+}
+
+// registryHostOf returns the host of an image repository reference like "mirror.example.com:5000/ocp/release".
+func registryHostOf(repository string) string {
+	host := strings.SplitN(repository, "/", 2)[0]
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// SuggestConfig builds a starter configuration that obfuscates all discovered domains and names along with IPs and MACs, and omits
+// the resources that are most likely to contain credentials. Each discovered domain is annotated with the place it was found.
+func (c *ClusterDetails) SuggestConfig() ([]byte, error) {
+	config := schema.SchemaJson{Config: schema.SchemaJsonConfig{
+		Obfuscate: []schema.Obfuscate{
+			{Type: schema.ObfuscateTypeIP, ReplacementType: schema.ObfuscateReplacementTypeConsistent, Target: schema.ObfuscateTargetAll},
+			{Type: schema.ObfuscateTypeMAC, ReplacementType: schema.ObfuscateReplacementTypeConsistent, Target: schema.ObfuscateTargetAll},
+		},
+		Omit: []schema.Omit{
+			{Type: schema.OmitTypeKubernetes, KubernetesResource: &schema.OmitKubernetesResource{Kind: pString("Secret")}},
+			{Type: schema.OmitTypeKubernetes, KubernetesResource: &schema.OmitKubernetesResource{Kind: pString("ConfigMap")}},
+		},
+	}}
+
+	// the infrastructure name contains the cluster name, so it needs to be replaced first
+	var exactReplacements []schema.ObfuscateExactReplacementsElem
+	if c.InfrastructureName != "" {
+		exactReplacements = append(exactReplacements, schema.ObfuscateExactReplacementsElem{Original: c.InfrastructureName, Replacement: infrastructureNameReplacement})
+	}
+	if len(c.ClusterName) >= minClusterNameLength {
+		exactReplacements = append(exactReplacements, schema.ObfuscateExactReplacementsElem{Original: c.ClusterName, Replacement: clusterNameReplacement})
+	} else if c.ClusterName != "" {
+		klog.Warningf("cluster name '%s' is too short to be replaced safely, please add it manually if needed", c.ClusterName)
+	}
+
+	domains := c.sortedDomains()
+	// the cluster name is part of most domains, replacing the domains before the names keeps them recognizable as domains
+	if len(domains) > 0 {
+		config.Config.Obfuscate = append(config.Config.Obfuscate, schema.Obfuscate{
+			Type:            schema.ObfuscateTypeDomain,
+			ReplacementType: schema.ObfuscateReplacementTypeConsistent,
+			Target:          schema.ObfuscateTargetAll,
+			DomainNames:     domains,
+		})
+	}
+	if len(exactReplacements) > 0 {
+		config.Config.Obfuscate = append(config.Config.Obfuscate, schema.Obfuscate{
+			Type:              schema.ObfuscateTypeExact,
+			ReplacementType:   schema.ObfuscateReplacementTypeStatic,
+			Target:            schema.ObfuscateTargetAll,
+			ExactReplacements: exactReplacements,
+		})
+	}
+
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode suggested config: %w", err)
+	}
+	c.annotateDomains(&node)
+
+	bytes, err := yaml.Marshal(&node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal suggested config: %w", err)
+	}
+	return bytes, nil
+}
+
+// annotateDomains adds a comment with the source to each entry in the domainNames list of the encoded configuration.
+func (c *ClusterDetails) annotateDomains(root *yaml.Node) {
+	configNode := mappingValue(root, "config")
+	obfuscate := mappingValue(configNode, "obfuscate")
+	if obfuscate == nil {
+		return
+	}
+	for _, o := range obfuscate.Content {
+		domainNames := mappingValue(o, "domainNames")
+		if domainNames == nil {
+			continue
+		}
+		for _, d := range domainNames.Content {
+			d.LineComment = "found in " + c.Domains[d.Value]
+		}
+	}
+}
+
+// sortedDomains returns the domains sorted by their reversed labels, this groups subdomains below their parent domain.
+func (c *ClusterDetails) sortedDomains() []string {
+	domains := make([]string, 0, len(c.Domains))
+	for d := range c.Domains {
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return reverseLabels(domains[i]) < reverseLabels(domains[j])
+	})
+	return domains
+}
+
+func reverseLabels(domain string) string {
+	labels := strings.Split(domain, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func pString(s string) *string {
+	return &s
+}
@@ -0,0 +1,78 @@
+package suggester
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverClusterDetails(t *testing.T) {
+	details, err := DiscoverClusterDetails("testfiles/mg")
+	require.NoError(t, err)
+
+	assert.Equal(t, "mycluster-x7k2p", details.InfrastructureName)
+	assert.Equal(t, "mycluster", details.ClusterName)
+	assert.Equal(t, map[string]string{
+		"mycluster.example.com":         "DNS/cluster spec.baseDomain",
+		"apps.mycluster.example.com":    "Ingress/cluster spec.domain",
+		"api.mycluster.example.com":     "Infrastructure/cluster status.apiServerURL",
+		"api-int.mycluster.example.com": "Infrastructure/cluster status.apiServerInternalURI",
+		"proxy.corp.internal":           "Proxy/cluster spec.httpProxy",
+		"corp.internal":                 "Proxy/cluster spec.noProxy",
+		"registry.corp.internal":        "Proxy/cluster spec.noProxy",
+		"mirror.corp.internal":          "ImageContentSourcePolicy/mirror spec.repositoryDigestMirrors",
+	}, details.Domains)
+}
+
+func TestSuggestConfig(t *testing.T) {
+	details, err := DiscoverClusterDetails("testfiles/mg")
+	require.NoError(t, err)
+
+	config, err := details.SuggestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, `config:
+    obfuscate:
+        - replacementType: Consistent
+          target: All
+          type: IP
+        - replacementType: Consistent
+          target: All
+          type: MAC
+        - domainNames:
+            - mycluster.example.com # found in DNS/cluster spec.baseDomain
+            - api.mycluster.example.com # found in Infrastructure/cluster status.apiServerURL
+            - api-int.mycluster.example.com # found in Infrastructure/cluster status.apiServerInternalURI
+            - apps.mycluster.example.com # found in Ingress/cluster spec.domain
+            - corp.internal # found in Proxy/cluster spec.noProxy
+            - mirror.corp.internal # found in ImageContentSourcePolicy/mirror spec.repositoryDigestMirrors
+            - proxy.corp.internal # found in Proxy/cluster spec.httpProxy
+            - registry.corp.internal # found in Proxy/cluster spec.noProxy
+          replacementType: Consistent
+          target: All
+          type: Domain
+        - exactReplacements:
+            - original: mycluster-x7k2p
+              replacement: obfuscated-infra-id
+            - original: mycluster
+              replacement: obfuscated-cluster-name
+          replacementType: Static
+          target: All
+          type: Exact
+    omit:
+        - kubernetesResource:
+            kind: Secret
+          type: Kubernetes
+        - kubernetesResource:
+            kind: ConfigMap
+          type: Kubernetes
+`, string(config))
+}
+
+func TestSuggestConfigNothingDiscovered(t *testing.T) {
+	details := &ClusterDetails{Domains: map[string]string{}, ClusterName: "ocp"}
+	config, err := details.SuggestConfig()
+	require.NoError(t, err)
+	assert.NotContains(t, string(config), "Domain")
+	assert.NotContains(t, string(config), "Exact")
+}
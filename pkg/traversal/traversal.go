@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"k8s.io/klog/v2"
 )
 
@@ -18,16 +19,41 @@ type FileWalker struct {
 	workerCount   int
 	workers       []QueueProcessor
 	workerFactory func(int) QueueProcessor
+	progress      *progress.Tracker
+}
+
+// WithProgress lets the walker add all files to the tracker before the workers start, and the workers report each file they process.
+func (w *FileWalker) WithProgress(tracker *progress.Tracker) *FileWalker {
+	w.progress = tracker
+	return w
 }
 
 // Traverse should be called to start processing the must-gather directory. This method will exit the CLI if an error is encountered.
+// The files are queued in lexical order, so a single worker always processes them in the same order.
 func (w *FileWalker) Traverse() {
+	err := w.Walk()
+	var e *fileProcessingError
+	switch {
+	case err == nil:
+	case errors.As(err, &e):
+		klog.Exitf("failed to process %s due to %v", e.path, e.cause)
+	default:
+		klog.Exitf("failed to traverse the directory structure due to: %v", err)
+	}
+}
+
+// Walk processes the must-gather directory like Traverse, but returns the first error instead of exiting. The remaining files are still
+// processed after an error, the further errors are only logged.
+func (w *FileWalker) Walk() error {
 	wg := sync.WaitGroup{}
 	errorCh := make(chan error, w.workerCount)
 	queue := make(chan workerInput, w.workerCount)
 	w.workers = make([]QueueProcessor, w.workerCount)
 	for i := 0; i < w.workerCount; i++ {
 		w.workers[i] = w.workerFactory(i + 1)
+		if r, ok := w.workers[i].(progressReporter); ok && w.progress != nil {
+			r.reportProgressTo(w.progress)
+		}
 		wg.Add(1)
 		go func(i int, queue chan workerInput, errorCh chan error) {
 			w.workers[i].ProcessQueue(queue, errorCh)
@@ -35,21 +61,42 @@ func (w *FileWalker) Traverse() {
 		}(i, queue, errorCh)
 	}
 
+	var firstErr error
 	errorWg := sync.WaitGroup{}
 	errorWg.Add(1)
 	go func(errorCh <-chan error) {
 		for err := range errorCh {
-			var e *fileProcessingError
-			switch {
-			case errors.As(err, &e):
-				klog.Exitf("failed to process %s due to %v", e.path, e.cause)
-			default:
-				klog.Exitf("unexpected error: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			} else {
+				klog.Errorf("%v", err)
 			}
 		}
 		errorWg.Done()
 	}(errorCh)
 
+	files, err := w.listFiles()
+	if err == nil {
+		for _, file := range files {
+			queue <- file
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	// once all the workers have exited close the error channel and wait for the collecting goroutine to complete.
+	close(errorCh)
+	errorWg.Wait()
+	if err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// listFiles returns the paths of all files relative to the input path, the files are added to the progress tracker if there is one.
+func (w *FileWalker) listFiles() ([]workerInput, error) {
+	var files []workerInput
 	err := filepath.WalkDir(w.inputPath, func(path string, dirEntry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -59,25 +106,21 @@ func (w *FileWalker) Traverse() {
 			// the rest of the logic expects the path to be relative to the input dir root, if it fails we assume it is already relative
 			relPath, err := filepath.Rel(w.inputPath, path)
 			if err != nil {
-				queue <- workerInput(path)
-			} else {
-				queue <- workerInput(relPath)
+				relPath = path
+			}
+			files = append(files, workerInput(relPath))
+			if w.progress != nil {
+				var size int64
+				if info, err := dirEntry.Info(); err == nil {
+					size = info.Size()
+				}
+				w.progress.Add(relPath, size)
 			}
 		}
 
 		return nil
 	})
-
-	if err != nil {
-		klog.Exitf("failed to traverse the directory structure due to: %v", err)
-	}
-
-	close(queue)
-	wg.Wait()
-
-	// once all the workers have exited close the error channel and wait for the exit goroutine to complete.
-	close(errorCh)
-	errorWg.Wait()
+	return files, err
 }
 
 func NewParallelFileWalker(inputPath string, workerCount int, workerFactory func(id int) QueueProcessor) *FileWalker {
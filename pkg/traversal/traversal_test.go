@@ -1,9 +1,12 @@
 package traversal
 
 import (
+	"errors"
+	"io/fs"
 	"path/filepath"
 	"testing"
 
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -70,3 +73,45 @@ func TestFileWalkerAbsolutePathing(t *testing.T) {
 		"pods/pod2/manifests.yaml",
 	}, queueProc.paths)
 }
+
+func TestFileWalkerProgress(t *testing.T) {
+	inputDir := "testfiles/test1/mg"
+	var totalBytes int64
+	require.NoError(t, filepath.WalkDir(inputDir, func(_ string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if !d.IsDir() {
+			info, err := d.Info()
+			require.NoError(t, err)
+			totalBytes += info.Size()
+		}
+		return nil
+	}))
+
+	tracker := progress.NewTracker("clean")
+	NewParallelFileWalker(inputDir, 2, func(id int) QueueProcessor {
+		return NewWorker(id, noOpCleaner{})
+	}).WithProgress(tracker).Traverse()
+
+	snapshot := tracker.Snapshot()
+	assert.Equal(t, 6, snapshot.Files)
+	assert.Equal(t, 6, snapshot.TotalFiles)
+	assert.Equal(t, totalBytes, snapshot.Bytes)
+	assert.Equal(t, totalBytes, snapshot.TotalBytes)
+	assert.Empty(t, snapshot.InFlight)
+}
+
+func TestFileWalkerWalkError(t *testing.T) {
+	desiredErr := errors.New("fail")
+	err := NewParallelFileWalker("testfiles/test1/mg", 2, func(id int) QueueProcessor {
+		return NewWorker(id, noOpCleaner{desiredError: &desiredErr})
+	}).Walk()
+
+	var e *fileProcessingError
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, desiredErr, e.cause)
+
+	err = NewParallelFileWalker("testfiles/does-not-exist", 2, func(id int) QueueProcessor {
+		return NewWorker(id, noOpCleaner{})
+	}).Walk()
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
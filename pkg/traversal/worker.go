@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/openshift/must-gather-clean/pkg/cleaner"
+	"github.com/openshift/must-gather-clean/pkg/progress"
 	"k8s.io/klog/v2"
 )
 
@@ -27,15 +28,28 @@ type QueueProcessor interface {
 	ProcessQueue(queue chan workerInput, errorCh chan error)
 }
 
+// progressReporter is implemented by queue processors that report the start and the end of each file to a progress tracker.
+type progressReporter interface {
+	reportProgressTo(tracker *progress.Tracker)
+}
+
 type Worker struct {
-	id      int
-	cleaner cleaner.Processor
+	id       int
+	cleaner  cleaner.Processor
+	progress *progress.Tracker
+}
+
+func (w *Worker) reportProgressTo(tracker *progress.Tracker) {
+	w.progress = tracker
 }
 
 func (w *Worker) ProcessQueue(queue chan workerInput, errorCh chan error) {
 	for wf := range queue {
 		path := string(wf)
 		klog.V(3).Infof("[Worker %02d] Processing %s\n", w.id, path)
+		if w.progress != nil {
+			w.progress.Start(path)
+		}
 
 		err := w.cleaner.Process(path)
 		if err != nil {
@@ -45,6 +59,9 @@ func (w *Worker) ProcessQueue(queue chan workerInput, errorCh chan error) {
 			}
 		}
 
+		if w.progress != nil {
+			w.progress.Finish(path)
+		}
 		klog.V(3).Infof("[Worker %02d] Finished processing %s\n", w.id, path)
 	}
 }
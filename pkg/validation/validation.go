@@ -0,0 +1,811 @@
+// Package validation contains the semantic validation of a configuration file, which goes beyond what can be expressed by the JSON schema.
+package validation
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/must-gather-clean/pkg/kube"
+	"github.com/openshift/must-gather-clean/pkg/obfuscator"
+	"github.com/openshift/must-gather-clean/pkg/omitter"
+	"github.com/openshift/must-gather-clean/pkg/schema"
+	"gopkg.in/yaml.v3"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a single finding of the validation, pointing to the line and column of the configuration file where it was found.
+// Line and Column are zero when the issue can't be attributed to a specific location.
+type Issue struct {
+	Severity Severity
+	// Field is the path to the offending property, for example "config.obfuscate[1].regex"
+	Field   string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s: %s", i.Line, i.Column, i.Severity, i.Field, i.Message)
+}
+
+// Result contains all issues found while validating a configuration, in order of their appearance.
+type Result struct {
+	Issues []Issue
+}
+
+// HasErrors returns true if at least one issue has SeverityError.
+func (r *Result) HasErrors() bool {
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	knownObfuscateProperties = []string{"type", "domainNames", "exactReplacements", "keepHostnameStructure", "regex", "replacement", "replacementType", "target"}
+	knownOmitProperties      = []string{"type", "include", "kubernetesResource", "pattern", "pathRegex", "timeRange", "maxSize", "fileTypes", "containsRegex"}
+	knownInclude             = []string{"namespaces", "patterns"}
+	knownKubernetesResource  = []string{"apiVersion", "kind", "namespaces", "names", "labelSelector", "annotations"}
+	knownTimeRange           = []string{"since", "until"}
+	knownConfigProperties    = []string{"obfuscate", "omit", "randSeed"}
+
+	obfuscateTypes = []schema.ObfuscateType{
+		schema.ObfuscateTypeAzureResources,
+		schema.ObfuscateTypeClusterIdentity,
+		schema.ObfuscateTypeDomain,
+		schema.ObfuscateTypeExact,
+		schema.ObfuscateTypeHostname,
+		schema.ObfuscateTypeIP,
+		schema.ObfuscateTypeKeywords,
+		schema.ObfuscateTypeMAC,
+		schema.ObfuscateTypeRegex,
+	}
+	obfuscateTargets = []schema.ObfuscateTarget{
+		schema.ObfuscateTargetAll,
+		schema.ObfuscateTargetFileContents,
+		schema.ObfuscateTargetFilePath,
+	}
+	replacementTypes = []schema.ObfuscateReplacementType{
+		schema.ObfuscateReplacementTypeConsistent,
+		schema.ObfuscateReplacementTypeStatic,
+	}
+	omitTypes = []schema.OmitType{
+		schema.OmitTypeKubernetes,
+		schema.OmitTypeFile,
+		schema.OmitTypeSymbolicLink,
+		schema.OmitTypeTimeRange,
+		schema.OmitTypeInclude,
+		schema.OmitTypePathRegex,
+		schema.OmitTypeMaxSize,
+		schema.OmitTypeFileType,
+		schema.OmitTypeContainsRegex,
+	}
+	fileTypes = []schema.OmitFileTypesElem{
+		schema.OmitFileTypesElemELF,
+		schema.OmitFileTypesElemCore,
+		schema.OmitFileTypesElemPcap,
+		schema.OmitFileTypesElemImage,
+	}
+)
+
+// ValidateConfigFromPath reads the configuration at the given path and validates it semantically.
+// An error is only returned when the file can't be read or isn't valid YAML/JSON, all other findings are part of the Result.
+func ValidateConfigFromPath(path string) (*Result, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config at %s: %w", path, err)
+	}
+
+	result, err := ValidateConfig(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config at %s: %w", path, err)
+	}
+
+	// as a last resort we also try to read the config exactly like the CLI would, this catches everything our checks might have missed
+	if !result.HasErrors() {
+		if _, err := schema.ReadConfigFromPath(path); err != nil {
+			result.Issues = append(result.Issues, Issue{Severity: SeverityError, Field: "config", Message: err.Error()})
+		}
+	}
+
+	return result, nil
+}
+
+// ValidateConfig validates the given YAML or JSON configuration. Since JSON is a subset of YAML, both are parsed the same way
+// which also gives us the line and column information for every property.
+func ValidateConfig(input []byte) (*Result, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(input, &document); err != nil {
+		return nil, err
+	}
+
+	v := &configValidator{}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		v.errorf(&document, "", "configuration is empty")
+		return &Result{Issues: v.issues}, nil
+	}
+
+	v.validateRoot(document.Content[0])
+	return &Result{Issues: v.issues}, nil
+}
+
+type configValidator struct {
+	issues []Issue
+}
+
+type obfuscateEntry struct {
+	field string
+	node  *yaml.Node
+	value schema.Obfuscate
+}
+
+type omitEntry struct {
+	field string
+	node  *yaml.Node
+	value schema.Omit
+}
+
+func (v *configValidator) validateRoot(root *yaml.Node) {
+	if !v.expectKind(root, "", yaml.MappingNode) {
+		return
+	}
+
+	_, config := lookup(root, "config")
+	if config == nil {
+		v.errorf(root, "config", "property is required")
+		return
+	}
+	if !v.expectKind(config, "config", yaml.MappingNode) {
+		return
+	}
+	v.warnUnknownProperties(config, "config", knownConfigProperties)
+
+	if _, randSeed := lookup(config, "randSeed"); randSeed != nil {
+		var seed int
+		if err := randSeed.Decode(&seed); err != nil {
+			v.errorf(randSeed, "config.randSeed", "must be an integer")
+		}
+	}
+
+	_, obfuscate := lookup(config, "obfuscate")
+	if obfuscate == nil {
+		v.errorf(config, "config.obfuscate", "property is required and must contain at least one obfuscator")
+	} else if v.expectKind(obfuscate, "config.obfuscate", yaml.SequenceNode) {
+		if len(obfuscate.Content) == 0 {
+			v.errorf(obfuscate, "config.obfuscate", "must contain at least one obfuscator")
+		}
+		var entries []obfuscateEntry
+		for i, item := range obfuscate.Content {
+			field := fmt.Sprintf("config.obfuscate[%d]", i)
+			if entry, ok := v.validateObfuscate(item, field); ok {
+				entries = append(entries, entry)
+			}
+		}
+		v.validateObfuscateOverlaps(entries)
+	}
+
+	_, omit := lookup(config, "omit")
+	if omit != nil && v.expectKind(omit, "config.omit", yaml.SequenceNode) {
+		var entries []omitEntry
+		for i, item := range omit.Content {
+			field := fmt.Sprintf("config.omit[%d]", i)
+			if entry, ok := v.validateOmit(item, field); ok {
+				entries = append(entries, entry)
+			}
+		}
+		v.validateOmitOverlaps(entries)
+	}
+}
+
+// validateObfuscate checks a single obfuscator definition. The returned bool indicates whether the entry was sound enough to be
+// considered for the overlap checks across all obfuscators.
+func (v *configValidator) validateObfuscate(node *yaml.Node, field string) (obfuscateEntry, bool) {
+	entry := obfuscateEntry{field: field, node: node}
+	if !v.expectKind(node, field, yaml.MappingNode) {
+		return entry, false
+	}
+	v.warnUnknownProperties(node, field, knownObfuscateProperties)
+
+	if err := node.Decode(&entry.value); err != nil {
+		v.errorf(node, field, "%v", err)
+		return entry, false
+	}
+	// defaults are usually set by the generated UnmarshalJSON, we have to mimic them here
+	if entry.value.ReplacementType == "" {
+		entry.value.ReplacementType = schema.ObfuscateReplacementTypeStatic
+	}
+	if entry.value.Target == "" {
+		entry.value.Target = schema.ObfuscateTargetFileContents
+	}
+
+	typeKey, typeNode := lookup(node, "type")
+	if typeNode == nil {
+		v.errorf(node, field+".type", "property is required")
+		return entry, false
+	}
+	if !contains(obfuscateTypes, entry.value.Type) {
+		v.errorf(typeNode, field+".type", "invalid value %q (expected one of %v)", entry.value.Type, obfuscateTypes)
+		return entry, false
+	}
+	sound := true
+	if _, n := lookup(node, "target"); n != nil && !contains(obfuscateTargets, entry.value.Target) {
+		v.errorf(n, field+".target", "invalid value %q (expected one of %v)", entry.value.Target, obfuscateTargets)
+		sound = false
+	}
+	if _, n := lookup(node, "replacementType"); n != nil && !contains(replacementTypes, entry.value.ReplacementType) {
+		v.errorf(n, field+".replacementType", "invalid value %q (expected one of %v)", entry.value.ReplacementType, replacementTypes)
+		sound = false
+	}
+
+	for _, p := range []struct {
+		property     string
+		expectedType schema.ObfuscateType
+	}{
+		{"domainNames", schema.ObfuscateTypeDomain},
+		{"exactReplacements", schema.ObfuscateTypeExact},
+		{"keepHostnameStructure", schema.ObfuscateTypeHostname},
+		{"regex", schema.ObfuscateTypeRegex},
+	} {
+		if k, _ := lookup(node, p.property); k != nil && entry.value.Type != p.expectedType {
+			v.warnf(k, field+"."+p.property, "property is only used by type %s and will be ignored for type %s", p.expectedType, entry.value.Type)
+		}
+	}
+
+	switch entry.value.Type {
+	case schema.ObfuscateTypeRegex:
+		_, regexNode := lookup(node, "regex")
+		if regexNode == nil || entry.value.Regex == nil {
+			v.errorf(typeKey, field+".regex", "type Regex requires the 'regex' property")
+			return entry, false
+		}
+		re, err := regexp.Compile(*entry.value.Regex)
+		if err != nil {
+			v.errorf(regexNode, field+".regex", "invalid regular expression: %v", err)
+			return entry, false
+		}
+		if re.MatchString("") {
+			v.warnf(regexNode, field+".regex", "regular expression %q matches the empty string", re.String())
+		}
+	case schema.ObfuscateTypeDomain:
+		_, domainsNode := lookup(node, "domainNames")
+		if domainsNode == nil || len(entry.value.DomainNames) == 0 {
+			v.errorf(typeKey, field+".domainNames", "type Domain requires at least one entry in 'domainNames'")
+			return entry, false
+		}
+		seen := map[string]int{}
+		for i, d := range entry.value.DomainNames {
+			domainField := fmt.Sprintf("%s.domainNames[%d]", field, i)
+			if strings.TrimSpace(d) == "" {
+				v.errorf(domainsNode.Content[i], domainField, "domain name must not be empty")
+				sound = false
+				continue
+			}
+			if _, err := obfuscator.NewDomainObfuscator([]string{d}, schema.ObfuscateReplacementTypeStatic, obfuscator.NewSimpleTracker()); err != nil {
+				v.errorf(domainsNode.Content[i], domainField, "%v", err)
+				sound = false
+				continue
+			}
+			if j, ok := seen[d]; ok {
+				v.warnf(domainsNode.Content[i], domainField, "domain %q is already defined in %s.domainNames[%d]", d, field, j)
+			}
+			seen[d] = i
+		}
+	case schema.ObfuscateTypeExact:
+		_, exactNode := lookup(node, "exactReplacements")
+		if exactNode == nil || len(entry.value.ExactReplacements) == 0 {
+			v.errorf(typeKey, field+".exactReplacements", "type Exact requires at least one entry in 'exactReplacements'")
+			return entry, false
+		}
+		for i, e := range entry.value.ExactReplacements {
+			elemField := fmt.Sprintf("%s.exactReplacements[%d]", field, i)
+			elemNode := exactNode.Content[i]
+			if k, _ := lookup(elemNode, "original"); k == nil {
+				v.errorf(elemNode, elemField+".original", "property is required")
+				sound = false
+			} else if e.Original == "" {
+				v.errorf(elemNode, elemField+".original", "must not be empty")
+				sound = false
+			}
+			if k, _ := lookup(elemNode, "replacement"); k == nil {
+				v.errorf(elemNode, elemField+".replacement", "property is required")
+				sound = false
+			}
+		}
+	case schema.ObfuscateTypeKeywords:
+		_, replacementNode := lookup(node, "replacement")
+		if replacementNode == nil || len(entry.value.Replacement) == 0 {
+			v.errorf(typeKey, field+".replacement", "type Keywords requires at least one keyword in 'replacement'")
+			return entry, false
+		}
+		if k, _ := lookup(node, "replacementType"); k != nil {
+			v.warnf(k, field+".replacementType", "property has no effect on type Keywords, the replacement is always taken from 'replacement'")
+		}
+		if k, _ := lookup(replacementNode, ""); k != nil {
+			v.errorf(k, field+".replacement", "keyword must not be empty")
+			sound = false
+		}
+	}
+
+	return entry, sound
+}
+
+// validateObfuscateOverlaps warns about obfuscators whose matches always overlap with those of an earlier obfuscator. Where matches overlap,
+// the obfuscator that is defined first wins, so the later one never replaces them.
+func (v *configValidator) validateObfuscateOverlaps(entries []obfuscateEntry) {
+	for j, later := range entries {
+		for _, earlier := range entries[:j] {
+			if !targetsOverlap(earlier.value.Target, later.value.Target) {
+				continue
+			}
+			v.checkObfuscatePair(earlier, later)
+		}
+	}
+}
+
+func (v *configValidator) checkObfuscatePair(earlier, later obfuscateEntry) {
+	switch later.value.Type {
+	case schema.ObfuscateTypeIP, schema.ObfuscateTypeMAC, schema.ObfuscateTypeAzureResources, schema.ObfuscateTypeClusterIdentity, schema.ObfuscateTypeHostname:
+		if earlier.value.Type == later.value.Type {
+			v.warnf(later.node, later.field, "type %s never replaces anything on the overlapping targets, %s is defined first and wins its matches", later.value.Type, earlier.field)
+		}
+	case schema.ObfuscateTypeRegex:
+		if earlier.value.Type == later.value.Type && *earlier.value.Regex == *later.value.Regex {
+			_, n := lookup(later.node, "regex")
+			v.warnf(n, later.field+".regex", "regular expression is already defined in %s, which is defined first and wins its matches", earlier.field)
+		}
+	case schema.ObfuscateTypeDomain:
+		if earlier.value.Type != later.value.Type {
+			return
+		}
+		_, domainsNode := lookup(later.node, "domainNames")
+		for i, d := range later.value.DomainNames {
+			for _, e := range earlier.value.DomainNames {
+				if d == e {
+					v.warnf(domainsNode.Content[i], fmt.Sprintf("%s.domainNames[%d]", later.field, i), "domain %q is also matched by %s, which is defined first and wins the overlapping match", d, earlier.field)
+				}
+			}
+		}
+	case schema.ObfuscateTypeKeywords:
+		_, replacementNode := lookup(later.node, "replacement")
+		keywords := make([]string, 0, len(later.value.Replacement))
+		for keyword := range later.value.Replacement {
+			keywords = append(keywords, keyword)
+		}
+		sort.Strings(keywords)
+		for _, keyword := range keywords {
+			if by, ok := shadowingLiteral(earlier, keyword); ok {
+				k, _ := lookup(replacementNode, keyword)
+				v.warnf(k, later.field+".replacement", "keyword %q contains %q of %s, which is defined first and wins the overlapping match", keyword, by, earlier.field)
+			}
+		}
+	case schema.ObfuscateTypeExact:
+		_, exactNode := lookup(later.node, "exactReplacements")
+		for i, e := range later.value.ExactReplacements {
+			if by, ok := shadowingLiteral(earlier, e.Original); ok {
+				v.warnf(exactNode.Content[i], fmt.Sprintf("%s.exactReplacements[%d]", later.field, i), "original %q contains %q of %s, which is defined first and wins the overlapping match", e.Original, by, earlier.field)
+			}
+		}
+	}
+}
+
+// shadowingLiteral returns the literal string of a Keywords or Exact obfuscator which is contained in the given input. Any such literal
+// overlaps every match of the input and wins it, so the input is never replaced by a later obfuscator.
+func shadowingLiteral(earlier obfuscateEntry, input string) (string, bool) {
+	var literals []string
+	switch earlier.value.Type {
+	case schema.ObfuscateTypeKeywords:
+		for k := range earlier.value.Replacement {
+			literals = append(literals, k)
+		}
+	case schema.ObfuscateTypeExact:
+		for _, e := range earlier.value.ExactReplacements {
+			literals = append(literals, e.Original)
+		}
+	}
+
+	// prefer the longest literal to make the message deterministic across map iterations
+	var found string
+	for _, l := range literals {
+		if l != "" && strings.Contains(input, l) && (len(l) > len(found) || (len(l) == len(found) && l < found)) {
+			found = l
+		}
+	}
+	return found, found != ""
+}
+
+func (v *configValidator) validateOmit(node *yaml.Node, field string) (omitEntry, bool) {
+	entry := omitEntry{field: field, node: node}
+	if !v.expectKind(node, field, yaml.MappingNode) {
+		return entry, false
+	}
+	v.warnUnknownProperties(node, field, knownOmitProperties)
+
+	if err := node.Decode(&entry.value); err != nil {
+		v.errorf(node, field, "%v", err)
+		return entry, false
+	}
+
+	typeKey, typeNode := lookup(node, "type")
+	if typeNode == nil {
+		v.errorf(node, field+".type", "property is required")
+		return entry, false
+	}
+	if !contains(omitTypes, entry.value.Type) {
+		v.errorf(typeNode, field+".type", "invalid value %q (expected one of %v)", entry.value.Type, omitTypes)
+		return entry, false
+	}
+
+	if k, _ := lookup(node, "pattern"); k != nil && entry.value.Type != schema.OmitTypeFile {
+		v.warnf(k, field+".pattern", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeFile, entry.value.Type)
+	}
+	if k, _ := lookup(node, "pathRegex"); k != nil && entry.value.Type != schema.OmitTypePathRegex {
+		v.warnf(k, field+".pathRegex", "property is only used by type %s and will be ignored for type %s", schema.OmitTypePathRegex, entry.value.Type)
+	}
+	if k, _ := lookup(node, "kubernetesResource"); k != nil && entry.value.Type != schema.OmitTypeKubernetes {
+		v.warnf(k, field+".kubernetesResource", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeKubernetes, entry.value.Type)
+	}
+	if k, _ := lookup(node, "include"); k != nil && entry.value.Type != schema.OmitTypeInclude {
+		v.warnf(k, field+".include", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeInclude, entry.value.Type)
+	}
+	if k, _ := lookup(node, "timeRange"); k != nil && entry.value.Type != schema.OmitTypeTimeRange {
+		v.warnf(k, field+".timeRange", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeTimeRange, entry.value.Type)
+	}
+	if k, _ := lookup(node, "maxSize"); k != nil && entry.value.Type != schema.OmitTypeMaxSize {
+		v.warnf(k, field+".maxSize", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeMaxSize, entry.value.Type)
+	}
+	if k, _ := lookup(node, "fileTypes"); k != nil && entry.value.Type != schema.OmitTypeFileType {
+		v.warnf(k, field+".fileTypes", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeFileType, entry.value.Type)
+	}
+	if k, _ := lookup(node, "containsRegex"); k != nil && entry.value.Type != schema.OmitTypeContainsRegex {
+		v.warnf(k, field+".containsRegex", "property is only used by type %s and will be ignored for type %s", schema.OmitTypeContainsRegex, entry.value.Type)
+	}
+
+	switch entry.value.Type {
+	case schema.OmitTypeFile:
+		_, patternNode := lookup(node, "pattern")
+		if patternNode == nil || entry.value.Pattern == nil || strings.TrimPrefix(*entry.value.Pattern, "!") == "" {
+			v.errorf(typeKey, field+".pattern", "type File requires a non-empty 'pattern'")
+			return entry, false
+		}
+		if err := omitter.ValidateGlob(strings.TrimPrefix(*entry.value.Pattern, "!")); err != nil {
+			v.errorf(patternNode, field+".pattern", "invalid glob pattern %q: %v", *entry.value.Pattern, err)
+			return entry, false
+		}
+		if strings.HasPrefix(strings.TrimPrefix(*entry.value.Pattern, "!"), "/") {
+			v.warnf(patternNode, field+".pattern", "pattern %q is absolute, but patterns are matched against paths relative to the must-gather root", *entry.value.Pattern)
+		}
+	case schema.OmitTypePathRegex:
+		_, regexNode := lookup(node, "pathRegex")
+		if regexNode == nil || entry.value.PathRegex == nil || strings.TrimPrefix(*entry.value.PathRegex, "!") == "" {
+			v.errorf(typeKey, field+".pathRegex", "type PathRegex requires a non-empty 'pathRegex'")
+			return entry, false
+		}
+		if _, err := regexp.Compile(strings.TrimPrefix(*entry.value.PathRegex, "!")); err != nil {
+			v.errorf(regexNode, field+".pathRegex", "invalid regular expression: %v", err)
+			return entry, false
+		}
+	case schema.OmitTypeMaxSize:
+		_, sizeNode := lookup(node, "maxSize")
+		if sizeNode == nil || entry.value.MaxSize == nil {
+			v.errorf(typeKey, field+".maxSize", "type MaxSize requires the 'maxSize' property")
+			return entry, false
+		}
+		if _, err := omitter.ParseSize(*entry.value.MaxSize); err != nil {
+			v.errorf(sizeNode, field+".maxSize", "%v", err)
+			return entry, false
+		}
+	case schema.OmitTypeFileType:
+		_, typesNode := lookup(node, "fileTypes")
+		if typesNode == nil || len(entry.value.FileTypes) == 0 {
+			v.errorf(typeKey, field+".fileTypes", "type FileType requires at least one of %v in 'fileTypes'", fileTypes)
+			return entry, false
+		}
+		sound := true
+		for i, t := range entry.value.FileTypes {
+			if !contains(fileTypes, t) {
+				v.errorf(typesNode.Content[i], fmt.Sprintf("%s.fileTypes[%d]", field, i), "invalid value %q (expected one of %v)", t, fileTypes)
+				sound = false
+			}
+		}
+		if !sound {
+			return entry, false
+		}
+	case schema.OmitTypeContainsRegex:
+		_, regexNode := lookup(node, "containsRegex")
+		if regexNode == nil || entry.value.ContainsRegex == nil || *entry.value.ContainsRegex == "" {
+			v.errorf(typeKey, field+".containsRegex", "type ContainsRegex requires a non-empty 'containsRegex'")
+			return entry, false
+		}
+		re, err := regexp.Compile(*entry.value.ContainsRegex)
+		if err != nil {
+			v.errorf(regexNode, field+".containsRegex", "invalid regular expression: %v", err)
+			return entry, false
+		}
+		if re.MatchString("") {
+			v.warnf(regexNode, field+".containsRegex", "regular expression %q matches the empty string and omits every file", re.String())
+		}
+	case schema.OmitTypeKubernetes:
+		_, resourceNode := lookup(node, "kubernetesResource")
+		if resourceNode == nil || entry.value.KubernetesResource == nil {
+			v.errorf(typeKey, field+".kubernetesResource", "type Kubernetes requires the 'kubernetesResource' property")
+			return entry, false
+		}
+		v.warnUnknownProperties(resourceNode, field+".kubernetesResource", knownKubernetesResource)
+		kind := entry.value.KubernetesResource.Kind
+		if kind == nil || *kind == "" {
+			v.errorf(resourceNode, field+".kubernetesResource.kind", "property is required")
+			return entry, false
+		}
+		if !v.validateKubernetesResourceMetadata(resourceNode, field+".kubernetesResource", entry.value.KubernetesResource) {
+			return entry, false
+		}
+	case schema.OmitTypeInclude:
+		_, includeNode := lookup(node, "include")
+		if includeNode == nil || entry.value.Include == nil {
+			v.errorf(typeKey, field+".include", "type Include requires the 'include' property")
+			return entry, false
+		}
+		v.warnUnknownProperties(includeNode, field+".include", knownInclude)
+		if !v.validateInclude(includeNode, field+".include", entry.value.Include) {
+			return entry, false
+		}
+	case schema.OmitTypeTimeRange:
+		_, rangeNode := lookup(node, "timeRange")
+		if rangeNode == nil || entry.value.TimeRange == nil {
+			v.errorf(typeKey, field+".timeRange", "type TimeRange requires the 'timeRange' property")
+			return entry, false
+		}
+		v.warnUnknownProperties(rangeNode, field+".timeRange", knownTimeRange)
+		if !v.validateTimeRange(rangeNode, field+".timeRange", entry.value.TimeRange) {
+			return entry, false
+		}
+	}
+
+	return entry, true
+}
+
+// validateOmitOverlaps warns about omitters that are fully covered by an earlier omitter, the first omitter to match always wins.
+func (v *configValidator) validateOmitOverlaps(entries []omitEntry) {
+	for j, later := range entries {
+		for _, earlier := range entries[:j] {
+			if earlier.value.Type != later.value.Type {
+				continue
+			}
+			switch later.value.Type {
+			case schema.OmitTypeSymbolicLink:
+				v.warnf(later.node, later.field, "symbolic links are already omitted by %s", earlier.field)
+			case schema.OmitTypeFile:
+				if *earlier.value.Pattern == *later.value.Pattern {
+					_, n := lookup(later.node, "pattern")
+					v.warnf(n, later.field+".pattern", "pattern is already defined in %s", earlier.field)
+				}
+			case schema.OmitTypePathRegex:
+				if *earlier.value.PathRegex == *later.value.PathRegex {
+					_, n := lookup(later.node, "pathRegex")
+					v.warnf(n, later.field+".pathRegex", "regex is already defined in %s", earlier.field)
+				}
+			case schema.OmitTypeKubernetes:
+				if kubernetesResourceCovers(earlier.value.KubernetesResource, later.value.KubernetesResource) {
+					k, _ := lookup(later.node, "kubernetesResource")
+					v.warnf(k, later.field+".kubernetesResource", "resource is already omitted by %s", earlier.field)
+				}
+			}
+		}
+	}
+}
+
+// validateKubernetesResourceMetadata verifies the name and annotation patterns as well as the label selector.
+func (v *configValidator) validateKubernetesResourceMetadata(node *yaml.Node, field string, resource *schema.OmitKubernetesResource) bool {
+	sound := true
+	if _, namesNode := lookup(node, "names"); namesNode != nil {
+		for i, n := range resource.Names {
+			if _, err := path.Match(n, ""); err != nil {
+				v.errorf(namesNode.Content[i], fmt.Sprintf("%s.names[%d]", field, i), "invalid glob pattern %q: %v", n, err)
+				sound = false
+			}
+		}
+	}
+	if _, selectorNode := lookup(node, "labelSelector"); selectorNode != nil && resource.LabelSelector != nil {
+		if _, err := kube.ParseLabelSelector(*resource.LabelSelector); err != nil {
+			v.errorf(selectorNode, field+".labelSelector", "%v", err)
+			sound = false
+		}
+	}
+	if _, annotationsNode := lookup(node, "annotations"); annotationsNode != nil {
+		for i := 0; i+1 < len(annotationsNode.Content); i += 2 {
+			key, value := annotationsNode.Content[i].Value, annotationsNode.Content[i+1].Value
+			if _, err := path.Match(value, ""); err != nil {
+				v.errorf(annotationsNode.Content[i+1], field+".annotations."+key, "invalid glob pattern %q: %v", value, err)
+				sound = false
+			}
+		}
+	}
+	return sound
+}
+
+// validateInclude verifies the namespace and file patterns, at least one of them is required.
+func (v *configValidator) validateInclude(node *yaml.Node, field string, include *schema.OmitInclude) bool {
+	if len(include.Namespaces) == 0 && len(include.Patterns) == 0 {
+		v.errorf(node, field, "at least one of 'namespaces' and 'patterns' is required")
+		return false
+	}
+
+	sound := true
+	if _, namespacesNode := lookup(node, "namespaces"); namespacesNode != nil {
+		for i, ns := range include.Namespaces {
+			if _, err := path.Match(ns, ""); err != nil {
+				v.errorf(namespacesNode.Content[i], fmt.Sprintf("%s.namespaces[%d]", field, i), "invalid glob pattern %q: %v", ns, err)
+				sound = false
+			}
+		}
+	}
+	if _, patternsNode := lookup(node, "patterns"); patternsNode != nil {
+		for i, p := range include.Patterns {
+			if err := omitter.ValidateGlob(p); err != nil || p == "" {
+				v.errorf(patternsNode.Content[i], fmt.Sprintf("%s.patterns[%d]", field, i), "invalid glob pattern %q", p)
+				sound = false
+			}
+		}
+	}
+	return sound
+}
+
+// validateTimeRange verifies that at least one of since and until is a valid RFC3339 timestamp and that they are in order.
+func (v *configValidator) validateTimeRange(node *yaml.Node, field string, timeRange *schema.OmitTimeRange) bool {
+	var times []time.Time
+	for _, bound := range []struct {
+		name  string
+		value *string
+	}{{"since", timeRange.Since}, {"until", timeRange.Until}} {
+		_, valueNode := lookup(node, bound.name)
+		if valueNode == nil || bound.value == nil || *bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, *bound.value)
+		if err != nil {
+			v.errorf(valueNode, field+"."+bound.name, "invalid RFC3339 timestamp %q", *bound.value)
+			return false
+		}
+		times = append(times, t)
+	}
+
+	switch {
+	case len(times) == 0:
+		v.errorf(node, field, "at least one of 'since' and 'until' is required")
+		return false
+	case len(times) == 2 && times[0].After(times[1]):
+		_, untilNode := lookup(node, "until")
+		v.errorf(untilNode, field+".until", "until %q is before since %q", *timeRange.Until, *timeRange.Since)
+		return false
+	}
+	return true
+}
+
+// kubernetesResourceCovers returns true when every resource that matches b would also be matched by a.
+func kubernetesResourceCovers(a, b *schema.OmitKubernetesResource) bool {
+	if *a.Kind != *b.Kind {
+		return false
+	}
+	if !metadataFilterCovers(a, b) {
+		return false
+	}
+	if a.ApiVersion != nil && *a.ApiVersion != "" && (b.ApiVersion == nil || *a.ApiVersion != *b.ApiVersion) {
+		return false
+	}
+	if len(a.Namespaces) == 0 {
+		return true
+	}
+	if len(b.Namespaces) == 0 {
+		return false
+	}
+	for _, bn := range b.Namespaces {
+		if !contains(a.Namespaces, bn) {
+			return false
+		}
+	}
+	return true
+}
+
+func targetsOverlap(a, b schema.ObfuscateTarget) bool {
+	return a == b || a == schema.ObfuscateTargetAll || b == schema.ObfuscateTargetAll
+}
+
+func (v *configValidator) expectKind(node *yaml.Node, field string, kind yaml.Kind) bool {
+	if node.Kind == kind {
+		return true
+	}
+	expected := "an object"
+	if kind == yaml.SequenceNode {
+		expected = "a list"
+	}
+	v.errorf(node, field, "must be %s", expected)
+	return false
+}
+
+func (v *configValidator) warnUnknownProperties(node *yaml.Node, field string, known []string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !contains(known, key.Value) {
+			v.warnf(key, field+"."+key.Value, "unknown property will be ignored (expected one of %v)", known)
+		}
+	}
+}
+
+func (v *configValidator) errorf(node *yaml.Node, field string, format string, args ...interface{}) {
+	v.add(SeverityError, node, field, format, args...)
+}
+
+func (v *configValidator) warnf(node *yaml.Node, field string, format string, args ...interface{}) {
+	v.add(SeverityWarning, node, field, format, args...)
+}
+
+func (v *configValidator) add(severity Severity, node *yaml.Node, field string, format string, args ...interface{}) {
+	issue := Issue{Severity: severity, Field: field, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		issue.Line = node.Line
+		issue.Column = node.Column
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// lookup returns the key and value node of the given key in a mapping node, or nil if it does not exist.
+func lookup(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func contains[T comparable](list []T, item T) bool {
+	for _, l := range list {
+		if l == item {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataFilterCovers conservatively compares the name, label and annotation filters. Glob patterns and selectors are only
+// considered covering when a does not filter or filters exactly the same way as b.
+func metadataFilterCovers(a, b *schema.OmitKubernetesResource) bool {
+	if len(a.Names) > 0 {
+		if len(b.Names) == 0 {
+			return false
+		}
+		for _, bn := range b.Names {
+			if !contains(a.Names, bn) {
+				return false
+			}
+		}
+	}
+	if a.LabelSelector != nil && *a.LabelSelector != "" && (b.LabelSelector == nil || *a.LabelSelector != *b.LabelSelector) {
+		return false
+	}
+	for key, pattern := range a.Annotations {
+		if bPattern, ok := b.Annotations[key]; !ok || (pattern != "*" && pattern != bPattern) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,464 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected []Issue
+	}{
+		{
+			name: "valid config",
+			config: `config:
+  obfuscate:
+    - type: IP
+      replacementType: Consistent
+      target: All
+    - type: Domain
+      domainNames: ["rhcloud.com", "dev.rhcloud.com"]
+  omit:
+    - type: File
+      pattern: "*.log"
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+`,
+		},
+		{
+			name:     "missing config",
+			config:   `something: else`,
+			expected: []Issue{{Severity: SeverityError, Field: "config", Line: 1, Column: 1, Message: "property is required"}},
+		},
+		{
+			name: "missing obfuscate",
+			config: `config:
+  omit: []
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.obfuscate", Line: 2, Column: 3, Message: "property is required and must contain at least one obfuscator"}},
+		},
+		{
+			name: "invalid type",
+			config: `config:
+  obfuscate:
+    - type: Email
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.obfuscate[0].type", Line: 3, Column: 13,
+				Message: "invalid value \"Email\" (expected one of [AzureResources ClusterIdentity Domain Exact Hostname IP Keywords MAC Regex])"}},
+		},
+		{
+			name: "regex without regex",
+			config: `config:
+  obfuscate:
+    - type: Regex
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.obfuscate[0].regex", Line: 3, Column: 7, Message: "type Regex requires the 'regex' property"}},
+		},
+		{
+			name: "invalid regex",
+			config: `config:
+  obfuscate:
+    - type: Regex
+      regex: "a(b"
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.obfuscate[0].regex", Line: 4, Column: 14, Message: "invalid regular expression: error parsing regexp: missing closing ): `a(b`"}},
+		},
+		{
+			name: "regex matching empty string",
+			config: `config:
+  obfuscate:
+    - type: Regex
+      regex: "[0-9]*"
+`,
+			expected: []Issue{{Severity: SeverityWarning, Field: "config.obfuscate[0].regex", Line: 4, Column: 14, Message: "regular expression \"[0-9]*\" matches the empty string"}},
+		},
+		{
+			name: "domain without domainNames and typo",
+			config: `config:
+  obfuscate:
+    - type: Domain
+      domainName: ["rhcloud.com"]
+`,
+			expected: []Issue{
+				{Severity: SeverityWarning, Field: "config.obfuscate[0].domainName", Line: 4, Column: 7,
+					Message: "unknown property will be ignored (expected one of [type domainNames exactReplacements keepHostnameStructure regex replacement replacementType target])"},
+				{Severity: SeverityError, Field: "config.obfuscate[0].domainNames", Line: 3, Column: 7, Message: "type Domain requires at least one entry in 'domainNames'"},
+			},
+		},
+		{
+			name: "duplicated and empty domains",
+			config: `config:
+  obfuscate:
+    - type: Domain
+      domainNames:
+        - rhcloud.com
+        - ""
+        - rhcloud.com
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.obfuscate[0].domainNames[1]", Line: 6, Column: 11, Message: "domain name must not be empty"},
+				{Severity: SeverityWarning, Field: "config.obfuscate[0].domainNames[2]", Line: 7, Column: 11, Message: "domain \"rhcloud.com\" is already defined in config.obfuscate[0].domainNames[0]"},
+			},
+		},
+		{
+			name: "property of another type",
+			config: `config:
+  obfuscate:
+    - type: IP
+      regex: "abc"
+`,
+			expected: []Issue{{Severity: SeverityWarning, Field: "config.obfuscate[0].regex", Line: 4, Column: 7, Message: "property is only used by type Regex and will be ignored for type IP"}},
+		},
+		{
+			name: "exact with empty original",
+			config: `config:
+  obfuscate:
+    - type: Exact
+      exactReplacements:
+        - original: ""
+          replacement: abc
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.obfuscate[0].exactReplacements[0].original", Line: 5, Column: 11, Message: "must not be empty"}},
+		},
+		{
+			name: "shadowed keywords",
+			config: `config:
+  obfuscate:
+    - type: Keywords
+      replacement:
+        secret: public
+    - type: Keywords
+      target: All
+      replacement:
+        topsecret: unknown
+        other: thing
+`,
+			expected: []Issue{{Severity: SeverityWarning, Field: "config.obfuscate[1].replacement", Line: 9, Column: 9, Message: "keyword \"topsecret\" contains \"secret\" of config.obfuscate[0], which is defined first and wins the overlapping match"}},
+		},
+		{
+			name: "keywords on different targets do not shadow",
+			config: `config:
+  obfuscate:
+    - type: Keywords
+      target: FilePath
+      replacement:
+        secret: public
+    - type: Keywords
+      replacement:
+        topsecret: unknown
+`,
+		},
+		{
+			name: "duplicated ip obfuscator",
+			config: `config:
+  obfuscate:
+    - type: IP
+    - type: IP
+      replacementType: Consistent
+`,
+			expected: []Issue{{Severity: SeverityWarning, Field: "config.obfuscate[1]", Line: 4, Column: 7, Message: "type IP never replaces anything on the overlapping targets, config.obfuscate[0] is defined first and wins its matches"}},
+		},
+		{
+			name: "kubernetes omission without resource",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: Kubernetes
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.omit[0].kubernetesResource", Line: 5, Column: 7, Message: "type Kubernetes requires the 'kubernetesResource' property"}},
+		},
+		{
+			name: "kubernetes omission without kind",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: Kubernetes
+      kubernetesResource:
+        namespaces: ["kube-system"]
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.omit[0].kubernetesResource.kind", Line: 7, Column: 9, Message: "property is required"}},
+		},
+		{
+			name: "include rules",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: Include
+      include:
+        namespaces: ["openshift-*", "customer-app"]
+        patterns: ["cluster-scoped-resources/*/nodes/*"]
+    - type: Include
+      include:
+        namespaces: ["[a-"]
+    - type: Include
+      include: {}
+    - type: Include
+      pattern: "*.log"
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[1].include.namespaces[0]", Line: 11, Column: 22, Message: "invalid glob pattern \"[a-\": syntax error in pattern"},
+				{Severity: SeverityError, Field: "config.omit[2].include", Line: 13, Column: 16, Message: "at least one of 'namespaces' and 'patterns' is required"},
+				{Severity: SeverityWarning, Field: "config.omit[3].pattern", Line: 15, Column: 7, Message: "property is only used by type File and will be ignored for type Include"},
+				{Severity: SeverityError, Field: "config.omit[3].include", Line: 14, Column: 7, Message: "type Include requires the 'include' property"},
+			},
+		},
+		{
+			name: "time range omission",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: TimeRange
+      timeRange:
+        since: "2023-04-12T10:00:00Z"
+        until: "2023-04-12T12:00:00+02:00"
+`,
+			expected: nil,
+		},
+		{
+			name: "time range omission without bounds",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: TimeRange
+      timeRange:
+        since: ""
+    - type: TimeRange
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[0].timeRange", Line: 7, Column: 9, Message: "at least one of 'since' and 'until' is required"},
+				{Severity: SeverityError, Field: "config.omit[1].timeRange", Line: 8, Column: 7, Message: "type TimeRange requires the 'timeRange' property"},
+			},
+		},
+		{
+			name: "invalid time range",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: TimeRange
+      timeRange:
+        since: "2023-04-12 10:00"
+    - type: TimeRange
+      timeRange:
+        since: "2023-04-12T10:00:00Z"
+        until: "2023-04-12T09:00:00Z"
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[0].timeRange.since", Line: 7, Column: 16, Message: "invalid RFC3339 timestamp \"2023-04-12 10:00\""},
+				{Severity: SeverityError, Field: "config.omit[1].timeRange.until", Line: 11, Column: 16, Message: "until \"2023-04-12T09:00:00Z\" is before since \"2023-04-12T10:00:00Z\""},
+			},
+		},
+		{
+			name: "invalid glob",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: File
+      pattern: "[a-"
+`,
+			expected: []Issue{{Severity: SeverityError, Field: "config.omit[0].pattern", Line: 6, Column: 16, Message: "invalid glob pattern \"[a-\": syntax error in pattern"}},
+		},
+		{
+			name: "glob with a malformed segment",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: File
+      pattern: "**/[a/b]"
+    - type: Include
+      include:
+        patterns: ["**/[a/b]"]
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[0].pattern", Line: 6, Column: 16, Message: "invalid glob pattern \"**/[a/b]\": syntax error in pattern"},
+				{Severity: SeverityError, Field: "config.omit[1].include.patterns[0]", Line: 9, Column: 20, Message: "invalid glob pattern \"**/[a/b]\""},
+			},
+		},
+		{
+			name: "negated globs and path regex",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: File
+      pattern: "namespaces/*/pods/**"
+    - type: File
+      pattern: "!namespaces/openshift-etcd/**"
+    - type: File
+      pattern: "!"
+    - type: PathRegex
+      pathRegex: "!^namespaces/[^/]+/pods/"
+    - type: PathRegex
+      pathRegex: "a(b"
+    - type: PathRegex
+      pathRegex: "!^namespaces/[^/]+/pods/"
+      pattern: "*.log"
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[2].pattern", Line: 9, Column: 7, Message: "type File requires a non-empty 'pattern'"},
+				{Severity: SeverityError, Field: "config.omit[4].pathRegex", Line: 14, Column: 18, Message: "invalid regular expression: error parsing regexp: missing closing ): `a(b`"},
+				{Severity: SeverityWarning, Field: "config.omit[5].pattern", Line: 17, Column: 7, Message: "property is only used by type File and will be ignored for type PathRegex"},
+				{Severity: SeverityWarning, Field: "config.omit[5].pathRegex", Line: 16, Column: 18, Message: "regex is already defined in config.omit[3]"},
+			},
+		},
+		{
+			name: "size, file type and content omissions",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: MaxSize
+      maxSize: "10MB"
+    - type: MaxSize
+      maxSize: "10 parsecs"
+    - type: FileType
+      fileTypes: ["ELF", "Core", "Executable"]
+    - type: FileType
+    - type: ContainsRegex
+      containsRegex: "BEGIN .*PRIVATE KEY"
+    - type: ContainsRegex
+      containsRegex: ".*"
+      maxSize: "1GiB"
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[1].maxSize", Line: 8, Column: 16, Message: "invalid unit 'parsecs' of size '10 parsecs', expected one of B, KB, MB, GB, KiB, MiB or GiB"},
+				{Severity: SeverityError, Field: "config.omit[2].fileTypes[2]", Line: 10, Column: 34, Message: "invalid value \"Executable\" (expected one of [ELF Core Pcap Image])"},
+				{Severity: SeverityError, Field: "config.omit[3].fileTypes", Line: 11, Column: 7, Message: "type FileType requires at least one of [ELF Core Pcap Image] in 'fileTypes'"},
+				{Severity: SeverityWarning, Field: "config.omit[5].maxSize", Line: 16, Column: 7, Message: "property is only used by type MaxSize and will be ignored for type ContainsRegex"},
+				{Severity: SeverityWarning, Field: "config.omit[5].containsRegex", Line: 15, Column: 22, Message: "regular expression \".*\" matches the empty string and omits every file"},
+			},
+		},
+		{
+			name: "shadowed omissions",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: SymbolicLink
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+        namespaces: ["kube-system", "default"]
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+        apiVersion: v1
+        namespaces: ["kube-system"]
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+    - type: SymbolicLink
+`,
+			expected: []Issue{
+				{Severity: SeverityWarning, Field: "config.omit[2].kubernetesResource", Line: 11, Column: 7, Message: "resource is already omitted by config.omit[1]"},
+				{Severity: SeverityWarning, Field: "config.omit[4]", Line: 18, Column: 7, Message: "symbolic links are already omitted by config.omit[0]"},
+			},
+		},
+		{
+			name: "invalid metadata filters",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+        names: ["customer-*", "[a-"]
+        labelSelector: "tier in (a b)"
+        annotations:
+          owner: "[a-"
+`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.omit[0].kubernetesResource.names[1]", Line: 8, Column: 31, Message: "invalid glob pattern \"[a-\": syntax error in pattern"},
+				{Severity: SeverityError, Field: "config.omit[0].kubernetesResource.labelSelector", Line: 9, Column: 24, Message: "invalid label selector 'tier in (a b)': invalid label value 'a b' for key 'tier'"},
+				{Severity: SeverityError, Field: "config.omit[0].kubernetesResource.annotations.owner", Line: 11, Column: 18, Message: "invalid glob pattern \"[a-\": syntax error in pattern"},
+			},
+		},
+		{
+			name: "metadata filters shadowing",
+			config: `config:
+  obfuscate:
+    - type: IP
+  omit:
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+        labelSelector: customer-data=true
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+        labelSelector: customer-data=true
+        names: ["db"]
+    - type: Kubernetes
+      kubernetesResource:
+        kind: Secret
+        labelSelector: customer-data
+`,
+			expected: []Issue{
+				{Severity: SeverityWarning, Field: "config.omit[1].kubernetesResource", Line: 10, Column: 7, Message: "resource is already omitted by config.omit[0]"},
+			},
+		},
+		{
+			name:   "json config",
+			config: `{"config": {"obfuscate": [{"type": "Regex"}]}}`,
+			expected: []Issue{
+				{Severity: SeverityError, Field: "config.obfuscate[0].regex", Line: 1, Column: 28, Message: "type Regex requires the 'regex' property"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ValidateConfig([]byte(tc.config))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result.Issues)
+		})
+	}
+}
+
+func TestValidateConfigInvalidYaml(t *testing.T) {
+	_, err := ValidateConfig([]byte("config: [a"))
+	require.Error(t, err)
+}
+
+func TestValidateConfigFromPathExamples(t *testing.T) {
+	glob, err := filepath.Glob("../../examples/*.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, glob)
+
+	for _, example := range glob {
+		result, err := ValidateConfigFromPath(example)
+		require.NoError(t, err)
+		assert.Emptyf(t, result.Issues, "expected no issues in %s", example)
+	}
+}
+
+func TestValidateConfigFromPathFallsBackToSchema(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "validation-*")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	// unsupported extensions are only caught by the schema reader
+	path := filepath.Join(tmpDir, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("config:\n  obfuscate:\n    - type: IP\n"), 0600))
+
+	result, err := ValidateConfigFromPath(path)
+	require.NoError(t, err)
+	require.Len(t, result.Issues, 1)
+	assert.True(t, result.HasErrors())
+	assert.Equal(t, 0, result.Issues[0].Line)
+}
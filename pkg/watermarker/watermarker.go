@@ -14,14 +14,24 @@ type WaterMarker interface {
 	WriteWaterMarkFile(path string) error
 }
 
-type SimpleWaterMarker struct{}
+type SimpleWaterMarker struct {
+	now func() time.Time
+}
 
 func NewSimpleWaterMarker() *SimpleWaterMarker {
-	return &SimpleWaterMarker{}
+	return &SimpleWaterMarker{now: time.Now}
+}
+
+// NewDeterministicWaterMarker creates a watermarker that always writes the Unix epoch as the timestamp, so that the watermark of the
+// same input is byte-identical across runs.
+func NewDeterministicWaterMarker() *SimpleWaterMarker {
+	return &SimpleWaterMarker{now: func() time.Time {
+		return time.Unix(0, 0)
+	}}
 }
 
 func (s *SimpleWaterMarker) WriteWaterMarkFile(path string) error {
-	timestampUTC := time.Now().UTC().String()
+	timestampUTC := s.now().UTC().String()
 	version := version.GetVersion().Version
 	contents := fmt.Sprintf("%s\n%s\n", timestampUTC, version)
 	err := os.WriteFile(filepath.Join(path, "watermark.txt"), []byte(contents), 0644)
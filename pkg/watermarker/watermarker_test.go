@@ -25,3 +25,12 @@ func TestSimpleWaterMarkingHappyPath(t *testing.T) {
 	require.NoError(t, err)
 	require.Contains(t, string(data), version.GetVersion().Version)
 }
+
+func TestDeterministicWaterMark(t *testing.T) {
+	tmpInputDir := t.TempDir()
+	require.NoError(t, NewDeterministicWaterMarker().WriteWaterMarkFile(tmpInputDir))
+
+	data, err := os.ReadFile(filepath.Join(tmpInputDir, "watermark.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "1970-01-01 00:00:00 +0000 UTC\n"+version.GetVersion().Version+"\n", string(data))
+}